@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ServiceExportValid means the exported Service is valid and exported to the hub.
+	ServiceExportValid ServiceExportConditionType = "Valid"
+	// ServiceExportConflict means the Service is exported by several clusters with conflicting
+	// properties, the properties of the oldest export are used by the ServiceImport.
+	ServiceExportConflict ServiceExportConditionType = "Conflict"
+)
+
+// ServiceExportConditionType is the type of a ServiceExport condition.
+type ServiceExportConditionType string
+
+// ServiceExportCondition is a condition of a ServiceExport.
+type ServiceExportCondition struct {
+	Type ServiceExportConditionType `json:"type"`
+	// Status is one of True, False and Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// +optional
+	Reason *string `json:"reason,omitempty"`
+	// +optional
+	Message *string `json:"message,omitempty"`
+}
+
+// ServiceExportStatus defines the observed state of ServiceExport
+type ServiceExportStatus struct {
+	// Conditions of the export.
+	// +optional
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +listType=map
+	// +listMapKey=type
+	Conditions []ServiceExportCondition `json:"conditions,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName={svcex}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// ServiceExport declares that the Service with the same name and namespace
+// as this export should be consumable from the other clusters.
+type ServiceExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +optional
+	Status ServiceExportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceExportList contains a list of ServiceExport
+type ServiceExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceExport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceExport{}, &ServiceExportList{})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelServiceName is the label of the EndpointSlices of a ServiceImport with the name of the service.
+	LabelServiceName = "multicluster.kubernetes.io/service-name"
+	// LabelSourceCluster is the label of the EndpointSlices of a ServiceImport with the cluster they come from.
+	LabelSourceCluster = "multicluster.kubernetes.io/source-cluster"
+)
+
+// ServiceImportType designates the type of a ServiceImport.
+type ServiceImportType string
+
+const (
+	// ClusterSetIP means the service is reached through a cluster set VIP.
+	ClusterSetIP ServiceImportType = "ClusterSetIP"
+	// Headless means the service is reached through the addresses of its endpoints.
+	Headless ServiceImportType = "Headless"
+)
+
+// ServiceImportSpec describes an imported service and the information necessary to consume it.
+type ServiceImportSpec struct {
+	// +listType=atomic
+	Ports []ServicePort `json:"ports"`
+	// IPs are the cluster set VIPs of the service in the consuming cluster.
+	// +kubebuilder:validation:MaxItems:=1
+	// +optional
+	IPs []string `json:"ips,omitempty"`
+	// Type defines the type of this service.
+	// +kubebuilder:validation:Enum=ClusterSetIP;Headless
+	Type ServiceImportType `json:"type"`
+	// SessionAffinity is ClientIP or None, it is None by default.
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+	// SessionAffinityConfig contains the configurations of session affinity.
+	// +optional
+	SessionAffinityConfig *corev1.SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+}
+
+// ServicePort represents the port on which the service is exposed.
+type ServicePort struct {
+	// Name of the port within the service, it must be a DNS_LABEL.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Protocol of the port, TCP, UDP or SCTP. Default is TCP.
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	// AppProtocol is the application protocol of the port.
+	// +optional
+	AppProtocol *string `json:"appProtocol,omitempty"`
+	// Port exposed by the service.
+	Port int32 `json:"port"`
+}
+
+// ServiceImportStatus describes derived state of an imported service.
+type ServiceImportStatus struct {
+	// Clusters are the clusters exporting the service.
+	// +optional
+	// +patchStrategy=merge
+	// +patchMergeKey=cluster
+	// +listType=map
+	// +listMapKey=cluster
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+}
+
+// ClusterStatus contains the service status in a cluster.
+type ClusterStatus struct {
+	// Cluster is the name of the exporting cluster.
+	Cluster string `json:"cluster"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName={svcim}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="TYPE",type=string,JSONPath=".spec.type"
+// +kubebuilder:printcolumn:name="IP",type=string,JSONPath=".spec.ips"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// ServiceImport describes a service imported from the clusters exporting it.
+// The ServiceImport in the hub aggregates the exports of all the members, and
+// the ServiceImports in the members are derived from it.
+type ServiceImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceImportSpec `json:"spec,omitempty"`
+	// +optional
+	Status ServiceImportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceImportList contains a list of ServiceImport
+type ServiceImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceImport{}, &ServiceImportList{})
+}
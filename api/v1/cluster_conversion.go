@@ -0,0 +1,35 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ConversionDataAnnotation is the annotation used by older versions to keep
+// the fields of v1 that they cannot represent, so that converting back is lossless.
+const ConversionDataAnnotation = "sumengzs.cn/conversion-data"
+
+// Hub marks v1 as the version all the other versions convert to and from.
+func (*Cluster) Hub() {}
+
+// SetupWebhookWithManager registers the conversion webhook of Cluster with the manager.
+func (c *Cluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		Complete()
+}
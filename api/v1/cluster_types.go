@@ -0,0 +1,325 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterSpec defines the desired state of Cluster
+type ClusterSpec struct {
+	// Provider of the cluster, this field is just for description
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// Enabled indicates whether the cluster pool should connect to the cluster.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	Enabled bool `json:"enabled"`
+	// Connection used to connect to cluster api server.
+	Connection ConnectionSpec `json:"connection"`
+	// Region represents the region of the member cluster locate in.
+	// +optional
+	Region Region `json:"region,omitempty"`
+	// Taints attached to the cluster. Workloads that do not tolerate the taints
+	// are not placed to the cluster (NoSchedule) or are evicted from it (NoExecute).
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+	// RateLimit limits the requests to the cluster api server of all the clients of the cluster pool,
+	// the default rate limit of the cluster pool is used if it is not set.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// RateLimit is the token bucket rate limit of the requests to a cluster api server.
+type RateLimit struct {
+	// QPS is the maximum queries per second to the cluster api server.
+	// +kubebuilder:validation:Minimum=1
+	QPS int32 `json:"qps"`
+	// Burst is the maximum burst of the queries to the cluster api server, it defaults to the QPS.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
+}
+
+// ConnectionType is the way used to connect to the cluster api server.
+// +kubebuilder:validation:Enum=Secret;KubeConfig;Token
+type ConnectionType string
+
+const (
+	// ConnectionTypeSecret connects with the token and CABundle saved in a Secret.
+	ConnectionTypeSecret ConnectionType = "Secret"
+	// ConnectionTypeKubeConfig connects with a kubeconfig file.
+	ConnectionTypeKubeConfig ConnectionType = "KubeConfig"
+	// ConnectionTypeToken connects with the token and CABundle declared in the spec.
+	ConnectionTypeToken ConnectionType = "Token"
+)
+
+// ConnectionSpec is a discriminated union, only the member named by Type is used.
+// +union
+type ConnectionSpec struct {
+	// Type of the connection, one of Secret, KubeConfig or Token.
+	// +unionDiscriminator
+	Type ConnectionType `json:"type"`
+	// Secret references the Secret saving token and CABundle, used when Type is Secret.
+	// The data definition of Secret must meet the following conditions:
+	// - secret.data.token
+	// - secret.data.caBundle
+	// +optional
+	Secret *SecretRef `json:"secret,omitempty"`
+	// KubeConfig is the kubeconfig used to connect, used when Type is KubeConfig.
+	// +optional
+	KubeConfig *KubeConfigRef `json:"kubeConfig,omitempty"`
+	// Token declares the token and CABundle connected to the cluster, used when Type is Token.
+	// It is not safe and not recommended.
+	// +optional
+	Token *TokenRef `json:"token,omitempty"`
+	// InsecureSkipTLSVerification indicates that the cluster pool should not confirm the validity of the serving
+	// certificate of the cluster it is connecting to. This will make the HTTPS connection between the cluster pool
+	// and the member cluster insecure.
+	// Defaults to false.
+	// +optional
+	InsecureSkipTLSVerification bool `json:"insecureSkipTLSVerification,omitempty"`
+	// Kubernetes API Server endpoint.
+	// hostname:port, IP or IP:port.
+	// Example: https://10.10.0.1:6443
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// ProxyURL is the proxy URL for the cluster.
+	// If not empty, the multi-cluster control plane will use this proxy to talk to the cluster.
+	// More details please refer to: https://github.com/kubernetes/client-go/issues/351
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+	// ProxyHeader is the HTTP header required by proxy server.
+	// The key in the key-value pair is HTTP header key and value is the associated header payloads.
+	// For the header with multiple values, the values should be separated by comma(e.g. 'k1': 'v1,v2,v3').
+	// +optional
+	ProxyHeader map[string]string `json:"proxyHeader,omitempty"`
+}
+
+// KubeConfigRef holds the kubeconfig used to connect to the cluster.
+type KubeConfigRef struct {
+	// Data is the content of the kubeconfig.
+	// It is the RSA encrypted and base64 encoded kubeconfig if DecryptionSecret is set,
+	// otherwise it is the plain kubeconfig.
+	Data []byte `json:"data"`
+	// DecryptionSecret references the Secret saving the RSA private key used to decrypt Data.
+	// The private key is read from secret.data.privateKey.
+	// +optional
+	DecryptionSecret *SecretRef `json:"decryptionSecret,omitempty"`
+}
+
+type SecretRef struct {
+	// Namespace is the namespace for the resource being referenced.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of resource being referenced.
+	Name string `json:"name"`
+}
+
+const (
+	// SecretTokenKey is the name of secret token key.
+	SecretTokenKey = "token"
+	// SecretCADataKey is the name of secret caBundle key.
+	SecretCADataKey = "caBundle"
+)
+
+type TokenRef struct {
+	// CABundle contains the certificate authority information.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// Token contain the token authority information.
+	// +optional
+	Token string `json:"token,omitempty"`
+}
+
+type Region struct {
+	// Zone represents the zone of the member cluster locate in.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+	// Country represents the country of the member cluster locate in.
+	// +optional
+	Country string `json:"country,omitempty"`
+	// Province represents the province of the member cluster locate in.
+	// +optional
+	Province string `json:"province,omitempty"`
+	// City represents the city of the member cluster locate in.
+	// +optional
+	City string `json:"city,omitempty"`
+}
+
+const (
+	// TaintClusterUnreachable will be added when the cluster has been unreachable for a grace period,
+	// with the NoSchedule effect first and the NoExecute effect after a longer one,
+	// and removed when the cluster becomes reachable.
+	TaintClusterUnreachable = "sumengzs.cn/unreachable"
+	// TaintClusterNotReady will be added when the cluster has been not ready for a grace period,
+	// with the NoSchedule effect first and the NoExecute effect after a longer one,
+	// and removed when the cluster becomes ready.
+	TaintClusterNotReady = "sumengzs.cn/not-ready"
+)
+
+const (
+	// NodePoolLabel is the default label used to group nodes into node pools.
+	NodePoolLabel = "sumengzs.cn/node-pool"
+)
+
+const (
+	// ClusterConditionReady means the cluster is healthy and ready to accept workloads.
+	ClusterConditionReady = "Ready"
+)
+
+// ClusterStatus defines the observed state of Cluster
+type ClusterStatus struct {
+	// ObservedGeneration is the most recent generation observed by the cluster pool.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Version represents version of the member cluster.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// APIEnablements represents the list of APIs installed in the member cluster.
+	// +optional
+	APIEnablements []APIEnablement `json:"apiEnablements,omitempty"`
+
+	// Conditions is an array of current cluster conditions.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// NodeSummary represents the summary of nodes status in the member cluster.
+	// +optional
+	NodeSummary *NodeSummary `json:"nodeSummary,omitempty"`
+
+	// ResourceSummary represents the summary of resources in the member cluster.
+	// +optional
+	ResourceSummary *ResourceSummary `json:"resourceSummary,omitempty"`
+}
+
+// APIEnablement is a list of API resource, it is used to expose the name of the
+// resources supported in a specific group and version.
+type APIEnablement struct {
+	// GroupVersion is the group and version this APIEnablement is for.
+	GroupVersion string `json:"groupVersion,omitempty"`
+
+	// Resources is a list of APIResource.
+	// +optional
+	Resources []APIResource `json:"resources,omitempty"`
+}
+
+// APIResource specifies the name and kind names for the resource.
+type APIResource struct {
+	// Name is the plural name of the resource.
+	// +required
+	Name string `json:"name,omitempty"`
+
+	// Kind is the kind for the resource (e.g. 'Deployment' is the kind for resource 'deployments')
+	// +required
+	Kind string `json:"kind,omitempty"`
+}
+
+// NodeSummary represents the summary of nodes status in a specific cluster.
+type NodeSummary struct {
+	// TotalNum is the total number of nodes in the cluster.
+	// +optional
+	TotalNum int32 `json:"total,omitempty"`
+
+	// ReadyNum is the number of ready nodes in the cluster.
+	// +optional
+	ReadyNum int32 `json:"ready,omitempty"`
+
+	// Capacity is the sum of the capacity of all nodes in the cluster.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+
+	// Allocatable is the sum of the allocatable resources of all ready nodes in the cluster.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+}
+
+// ResourceSummary represents the summary of resources in the member cluster.
+type ResourceSummary struct {
+	ResourceUsage `json:",inline"`
+
+	// NodePools is the resource summary of every node pool in the cluster.
+	// Nodes are grouped into pools by the value of the node pool label.
+	// +optional
+	NodePools []NodePoolSummary `json:"nodePools,omitempty"`
+}
+
+// NodePoolSummary represents the summary of resources of a node pool.
+type NodePoolSummary struct {
+	// Name is the value of the node pool label shared by the nodes in the pool.
+	Name string `json:"name"`
+
+	ResourceUsage `json:",inline"`
+}
+
+// ResourceUsage represents the usage of resources of a group of nodes.
+type ResourceUsage struct {
+	// Allocatable represents the resources of all ready and schedulable nodes that can be used by pods.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+
+	// Allocated represents the resources requested by all the pods scheduled to the nodes.
+	// +optional
+	Allocated corev1.ResourceList `json:"allocated,omitempty"`
+
+	// Available represents the resources that can still be allocated, it is Allocatable minus Allocated.
+	// +optional
+	Available corev1.ResourceList `json:"available,omitempty"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:scope=Cluster,shortName={mc,mcl},categories={multicluster}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="ENDPOINT",type="string",priority=1,JSONPath=".spec.connection.endpoint",description="The cluster endpoint"
+// +kubebuilder:printcolumn:name="PROVIDER",type="string",JSONPath=".spec.provider",description="The cluster provider"
+// +kubebuilder:printcolumn:name="ZONE",type="string",JSONPath=".spec.region.zone",description="The zone of the cluster located in"
+// +kubebuilder:printcolumn:name="ENABLED",type="boolean",priority=1,JSONPath=".spec.enabled",description="Whether the cluster is enabled"
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.version",description="The cluster version"
+// +kubebuilder:printcolumn:name="READY-NODES",type="integer",JSONPath=".status.nodeSummary.ready",description="The ready number of node"
+// +kubebuilder:printcolumn:name="TOTAL-NODES",type="integer",JSONPath=".status.nodeSummary.total",description="The total number of node"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="The ready condition of the cluster"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// Cluster is the Schema for the clusters API
+type Cluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterSpec `json:"spec,omitempty"`
+	// +optional
+	Status ClusterStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterList contains a list of Cluster
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
+}
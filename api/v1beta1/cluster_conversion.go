@@ -0,0 +1,290 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	v1 "github.com/sumengzs/multi-cluster/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var _ conversion.Convertible = &Cluster{}
+
+// ConversionDataAnnotation is the annotation used by v1 to keep the fields of v1beta1 that it cannot represent,
+// so that converting back is lossless.
+const ConversionDataAnnotation = "sumengzs.cn/v1beta1-conversion-data"
+
+// spokeOnlyData contains the connect ways of v1beta1 not used as they have lower priorities,
+// which can not be represented in the union of v1. It is saved in the ConversionDataAnnotation
+// annotation when converting to v1, and restored when converting back to v1beta1.
+type spokeOnlyData struct {
+	Config *ConfigRef `json:"config,omitempty"`
+	Token  *TokenRef  `json:"token,omitempty"`
+}
+
+// hubOnlyData contains the fields of v1 that can not be represented in v1beta1.
+// It is saved in the v1.ConversionDataAnnotation annotation when converting from v1,
+// and restored when converting back to v1.
+type hubOnlyData struct {
+	ConnectionType     v1.ConnectionType `json:"connectionType,omitempty"`
+	ObservedGeneration int64             `json:"observedGeneration,omitempty"`
+}
+
+func (d *hubOnlyData) empty() bool {
+	return len(d.ConnectionType) == 0 && d.ObservedGeneration == 0
+}
+
+// ConvertTo converts this Cluster to the hub version (v1).
+func (c *Cluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1.Cluster)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub type %T", dstRaw)
+	}
+
+	dst.ObjectMeta = *c.ObjectMeta.DeepCopy()
+	dst.Spec.Provider = c.Spec.Provider
+	dst.Spec.Enabled = !c.Spec.Disabled
+	dst.Spec.Region = v1.Region(c.Spec.Region)
+	dst.Spec.Connection = convertConnectToV1(&c.Spec.Connect)
+	dst.Spec.Taints = c.Spec.Taints
+	dst.Spec.RateLimit = nil
+	if c.Spec.RateLimit != nil {
+		dst.Spec.RateLimit = &v1.RateLimit{QPS: c.Spec.RateLimit.QPS, Burst: c.Spec.RateLimit.Burst}
+	}
+
+	dst.Status.Version = c.Status.Version
+	dst.Status.APIEnablements = nil
+	for _, enablement := range c.Status.APIEnablements {
+		in := v1.APIEnablement{GroupVersion: enablement.GroupVersion}
+		for _, resource := range enablement.Resources {
+			in.Resources = append(in.Resources, v1.APIResource(resource))
+		}
+		dst.Status.APIEnablements = append(dst.Status.APIEnablements, in)
+	}
+	dst.Status.Conditions = c.Status.Conditions
+	dst.Status.NodeSummary = nil
+	if c.Status.NodeSummary != nil {
+		dst.Status.NodeSummary = &v1.NodeSummary{
+			TotalNum:    c.Status.NodeSummary.TotalNum,
+			ReadyNum:    c.Status.NodeSummary.ReadyNum,
+			Capacity:    c.Status.NodeSummary.Capacity,
+			Allocatable: c.Status.NodeSummary.Allocatable,
+		}
+	}
+	dst.Status.ResourceSummary = nil
+	if c.Status.ResourceSummary != nil {
+		dst.Status.ResourceSummary = &v1.ResourceSummary{ResourceUsage: v1.ResourceUsage(c.Status.ResourceSummary.ResourceUsage)}
+		for _, pool := range c.Status.ResourceSummary.NodePools {
+			dst.Status.ResourceSummary.NodePools = append(dst.Status.ResourceSummary.NodePools,
+				v1.NodePoolSummary{Name: pool.Name, ResourceUsage: v1.ResourceUsage(pool.ResourceUsage)})
+		}
+	}
+
+	if err := saveSpokeOnlyData(c, dst); err != nil {
+		return err
+	}
+
+	data, ok := dst.Annotations[v1.ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(dst.Annotations, v1.ConversionDataAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+	restored := &hubOnlyData{}
+	if err := json.Unmarshal([]byte(data), restored); err != nil {
+		return fmt.Errorf("failed to unmarshal conversion data: %s", err)
+	}
+	if len(dst.Spec.Connection.Type) == 0 {
+		dst.Spec.Connection.Type = restored.ConnectionType
+	}
+	dst.Status.ObservedGeneration = restored.ObservedGeneration
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1) to this version.
+func (c *Cluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1.Cluster)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub type %T", srcRaw)
+	}
+
+	c.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	c.Spec.Provider = src.Spec.Provider
+	c.Spec.Disabled = !src.Spec.Enabled
+	c.Spec.Region = Region(src.Spec.Region)
+	c.Spec.Connect = convertConnectFromV1(&src.Spec.Connection)
+	c.Spec.Taints = src.Spec.Taints
+	c.Spec.RateLimit = nil
+	if src.Spec.RateLimit != nil {
+		c.Spec.RateLimit = &RateLimit{QPS: src.Spec.RateLimit.QPS, Burst: src.Spec.RateLimit.Burst}
+	}
+
+	c.Status.Version = src.Status.Version
+	c.Status.APIEnablements = nil
+	for _, enablement := range src.Status.APIEnablements {
+		out := APIEnablement{GroupVersion: enablement.GroupVersion}
+		for _, resource := range enablement.Resources {
+			out.Resources = append(out.Resources, APIResource(resource))
+		}
+		c.Status.APIEnablements = append(c.Status.APIEnablements, out)
+	}
+	c.Status.Conditions = src.Status.Conditions
+	c.Status.NodeSummary = nil
+	if src.Status.NodeSummary != nil {
+		c.Status.NodeSummary = &NodeSummary{
+			TotalNum:    src.Status.NodeSummary.TotalNum,
+			ReadyNum:    src.Status.NodeSummary.ReadyNum,
+			Capacity:    src.Status.NodeSummary.Capacity,
+			Allocatable: src.Status.NodeSummary.Allocatable,
+		}
+	}
+	c.Status.ResourceSummary = nil
+	if src.Status.ResourceSummary != nil {
+		c.Status.ResourceSummary = &ResourceSummary{ResourceUsage: ResourceUsage(src.Status.ResourceSummary.ResourceUsage)}
+		for _, pool := range src.Status.ResourceSummary.NodePools {
+			c.Status.ResourceSummary.NodePools = append(c.Status.ResourceSummary.NodePools,
+				NodePoolSummary{Name: pool.Name, ResourceUsage: ResourceUsage(pool.ResourceUsage)})
+		}
+	}
+
+	if err := c.restoreSpokeOnlyData(); err != nil {
+		return err
+	}
+
+	lost := &hubOnlyData{ObservedGeneration: src.Status.ObservedGeneration}
+	if c.Spec.Connect.Secret == nil && c.Spec.Connect.Config == nil && c.Spec.Connect.Token == nil {
+		lost.ConnectionType = src.Spec.Connection.Type
+	}
+	if lost.empty() {
+		return nil
+	}
+	data, err := json.Marshal(lost)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversion data: %s", err)
+	}
+	if c.Annotations == nil {
+		c.Annotations = map[string]string{}
+	}
+	c.Annotations[v1.ConversionDataAnnotation] = string(data)
+	return nil
+}
+
+// saveSpokeOnlyData saves the connect ways of the cluster dropped by convertConnectToV1 in the annotation of dst.
+func saveSpokeOnlyData(c *Cluster, dst *v1.Cluster) error {
+	lost := &spokeOnlyData{}
+	switch {
+	case c.Spec.Connect.Secret != nil:
+		lost.Config, lost.Token = c.Spec.Connect.Config, c.Spec.Connect.Token
+	case c.Spec.Connect.Config != nil:
+		lost.Token = c.Spec.Connect.Token
+	}
+	if lost.Config == nil && lost.Token == nil {
+		return nil
+	}
+	data, err := json.Marshal(lost)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversion data: %s", err)
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[ConversionDataAnnotation] = string(data)
+	return nil
+}
+
+// restoreSpokeOnlyData restores the connect ways saved by saveSpokeOnlyData. A connect way is only restored
+// if it has a lower priority than the one converted from v1, so the connect way used is never changed,
+// even if the connection of v1 is changed after the data was saved.
+func (c *Cluster) restoreSpokeOnlyData() error {
+	data, ok := c.Annotations[ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(c.Annotations, ConversionDataAnnotation)
+	if len(c.Annotations) == 0 {
+		c.Annotations = nil
+	}
+	restored := &spokeOnlyData{}
+	if err := json.Unmarshal([]byte(data), restored); err != nil {
+		return fmt.Errorf("failed to unmarshal conversion data: %s", err)
+	}
+	connect := &c.Spec.Connect
+	if connect.Secret != nil && connect.Config == nil {
+		connect.Config = restored.Config
+	}
+	if (connect.Secret != nil || connect.Config != nil) && connect.Token == nil {
+		connect.Token = restored.Token
+	}
+	return nil
+}
+
+// convertConnectToV1 keeps the connect way with the highest priority, which is the one actually used
+// by v1beta1, the others are saved by saveSpokeOnlyData.
+func convertConnectToV1(in *ConnectConfig) v1.ConnectionSpec {
+	out := v1.ConnectionSpec{
+		InsecureSkipTLSVerification: in.InsecureSkipTLSVerification,
+		Endpoint:                    in.Endpoint,
+		ProxyURL:                    in.ProxyURL,
+		ProxyHeader:                 in.ProxyHeader,
+	}
+	switch {
+	case in.Secret != nil:
+		out.Type = v1.ConnectionTypeSecret
+		out.Secret = &v1.SecretRef{Namespace: in.Secret.Namespace, Name: in.Secret.Name}
+	case in.Config != nil:
+		out.Type = v1.ConnectionTypeKubeConfig
+		out.KubeConfig = &v1.KubeConfigRef{Data: in.Config.Config}
+		if in.Config.Secret != nil {
+			out.KubeConfig.DecryptionSecret = &v1.SecretRef{Namespace: in.Config.Secret.Namespace, Name: in.Config.Secret.Name}
+		}
+	case in.Token != nil:
+		out.Type = v1.ConnectionTypeToken
+		out.Token = &v1.TokenRef{CABundle: in.Token.CABundle, Token: in.Token.Token}
+	}
+	return out
+}
+
+// convertConnectFromV1 only converts the union member selected by the discriminator.
+func convertConnectFromV1(in *v1.ConnectionSpec) ConnectConfig {
+	out := ConnectConfig{
+		InsecureSkipTLSVerification: in.InsecureSkipTLSVerification,
+		Endpoint:                    in.Endpoint,
+		ProxyURL:                    in.ProxyURL,
+		ProxyHeader:                 in.ProxyHeader,
+	}
+	switch in.Type {
+	case v1.ConnectionTypeSecret:
+		if in.Secret != nil {
+			out.Secret = &SecretRef{Namespace: in.Secret.Namespace, Name: in.Secret.Name}
+		}
+	case v1.ConnectionTypeKubeConfig:
+		if in.KubeConfig != nil {
+			out.Config = &ConfigRef{Config: in.KubeConfig.Data}
+			if in.KubeConfig.DecryptionSecret != nil {
+				out.Config.Secret = &SecretRef{Namespace: in.KubeConfig.DecryptionSecret.Namespace, Name: in.KubeConfig.DecryptionSecret.Name}
+			}
+		}
+	case v1.ConnectionTypeToken:
+		if in.Token != nil {
+			out.Token = &TokenRef{CABundle: in.Token.CABundle, Token: in.Token.Token}
+		}
+	}
+	return out
+}
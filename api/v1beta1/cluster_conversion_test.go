@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	fuzz "github.com/google/gofuzz"
+	v1 "github.com/sumengzs/multi-cluster/api/v1"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"math/rand"
+	"testing"
+)
+
+const fuzzIterations = 1000
+
+func conversionFuzzerFuncs(_ runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(q *resource.Quantity, c fuzz.Continue) {
+			*q = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+		},
+		// v1 only uses the union member selected by the discriminator.
+		func(in *v1.ConnectionSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(in)
+			switch c.Intn(4) {
+			case 0:
+				in.Type = v1.ConnectionTypeSecret
+				in.KubeConfig, in.Token = nil, nil
+			case 1:
+				in.Type = v1.ConnectionTypeKubeConfig
+				in.Secret, in.Token = nil, nil
+			case 2:
+				in.Type = v1.ConnectionTypeToken
+				in.Secret, in.KubeConfig = nil, nil
+			default:
+				in.Secret, in.KubeConfig, in.Token = nil, nil, nil
+			}
+		},
+	}
+}
+
+func newFuzzer(t *testing.T) *fuzz.Fuzzer {
+	seed := rand.Int63()
+	t.Logf("fuzz seed: %d", seed)
+	funcs := fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, conversionFuzzerFuncs)
+	return fuzzer.FuzzerFor(funcs, rand.NewSource(seed), runtimeserializer.NewCodecFactory(runtime.NewScheme()))
+}
+
+func TestCluster_SpokeHubSpoke(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		spoke := &Cluster{}
+		f.Fuzz(spoke)
+
+		hub := &v1.Cluster{}
+		if err := spoke.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("ConvertTo() error = %v", err)
+		}
+		got := &Cluster{}
+		if err := got.ConvertFrom(hub); err != nil {
+			t.Fatalf("ConvertFrom() error = %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(spoke, got) {
+			t.Fatalf("v1beta1 -> v1 -> v1beta1 is not lossless:\n%s", diff.ObjectReflectDiff(spoke, got))
+		}
+	}
+}
+
+func TestCluster_HubSpokeHub(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		hub := &v1.Cluster{}
+		f.Fuzz(hub)
+		delete(hub.Annotations, v1.ConversionDataAnnotation)
+		delete(hub.Annotations, ConversionDataAnnotation)
+
+		spoke := &Cluster{}
+		if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+			t.Fatalf("ConvertFrom() error = %v", err)
+		}
+		got := &v1.Cluster{}
+		if err := spoke.ConvertTo(got); err != nil {
+			t.Fatalf("ConvertTo() error = %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(hub, got) {
+			t.Fatalf("v1 -> v1beta1 -> v1 is not lossless:\n%s", diff.ObjectReflectDiff(hub, got))
+		}
+	}
+}
+
+func TestCluster_ConvertConnect(t *testing.T) {
+	spoke := &Cluster{Spec: ClusterSpec{Connect: ConnectConfig{
+		Secret: &SecretRef{Namespace: "default", Name: "secret"},
+		Config: &ConfigRef{Config: []byte("config")},
+		Token:  &TokenRef{Token: "old"},
+	}}}
+	hub := &v1.Cluster{}
+	if err := spoke.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if hub.Spec.Connection.Type != v1.ConnectionTypeSecret || hub.Spec.Connection.Token != nil {
+		t.Fatalf("connection = %+v, want the secret only", hub.Spec.Connection)
+	}
+
+	// the connect ways saved are not restored over the connection changed in v1
+	hub.Spec.Connection = v1.ConnectionSpec{Type: v1.ConnectionTypeToken, Token: &v1.TokenRef{Token: "new"}}
+	got := &Cluster{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	want := ConnectConfig{Token: &TokenRef{Token: "new"}}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Connect, want) {
+		t.Errorf("connect = %+v, want %+v", got.Spec.Connect, want)
+	}
+	if _, ok := got.Annotations[ConversionDataAnnotation]; ok {
+		t.Errorf("conversion data annotation is kept")
+	}
+}
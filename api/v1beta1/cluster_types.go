@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -37,6 +38,25 @@ type ClusterSpec struct {
 	// Region represents the region of the member cluster locate in.
 	// +optional
 	Region Region `json:"region,omitempty"`
+	// Taints attached to the cluster. Workloads that do not tolerate the taints
+	// are not placed to the cluster (NoSchedule) or are evicted from it (NoExecute).
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+	// RateLimit limits the requests to the cluster api server of all the clients of the cluster pool,
+	// the default rate limit of the cluster pool is used if it is not set.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// RateLimit is the token bucket rate limit of the requests to a cluster api server.
+type RateLimit struct {
+	// QPS is the maximum queries per second to the cluster api server.
+	// +kubebuilder:validation:Minimum=1
+	QPS int32 `json:"qps"`
+	// Burst is the maximum burst of the queries to the cluster api server, it defaults to the QPS.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
 }
 
 type ConnectConfig struct {
@@ -129,6 +149,74 @@ type Region struct {
 	City string `json:"city,omitempty"`
 }
 
+const (
+	// TaintClusterUnreachable will be added when the cluster has been unreachable for a grace period,
+	// with the NoSchedule effect first and the NoExecute effect after a longer one,
+	// and removed when the cluster becomes reachable.
+	TaintClusterUnreachable = "sumengzs.cn/unreachable"
+	// TaintClusterNotReady will be added when the cluster has been not ready for a grace period,
+	// with the NoSchedule effect first and the NoExecute effect after a longer one,
+	// and removed when the cluster becomes ready.
+	TaintClusterNotReady = "sumengzs.cn/not-ready"
+)
+
+const (
+	// NodePoolLabel is the default label used to group nodes into node pools.
+	NodePoolLabel = "sumengzs.cn/node-pool"
+)
+
+const (
+	// ClusterConditionReady means the cluster is healthy and ready to accept workloads.
+	ClusterConditionReady = "Ready"
+	// ClusterConditionConnected means the connection config of the cluster is built,
+	// and its API server is reachable and accepts the credentials.
+	ClusterConditionConnected = "Connected"
+	// ClusterConditionRunning means the cluster is started in the pool.
+	ClusterConditionRunning = "Running"
+)
+
+// The reasons of the cluster conditions and of the events on the clusters.
+const (
+	// ReasonConnected means the API server of the cluster is reachable and accepts the credentials.
+	ReasonConnected = "Connected"
+	// ReasonInvalidConfig means the connection config of the cluster is invalid.
+	ReasonInvalidConfig = "InvalidConfig"
+	// ReasonSecretNotFound means a secret referenced by the connection config is not found.
+	ReasonSecretNotFound = "SecretNotFound"
+	// ReasonSecretUnavailable means a secret referenced by the connection config can not be read.
+	ReasonSecretUnavailable = "SecretUnavailable"
+	// ReasonDecryptFailed means the encrypted config of the cluster can not be decrypted.
+	ReasonDecryptFailed = "DecryptFailed"
+	// ReasonEndpointUnreachable means the API server of the cluster is unreachable.
+	ReasonEndpointUnreachable = "EndpointUnreachable"
+	// ReasonCredentialsRejected means the API server of the cluster rejects the credentials.
+	ReasonCredentialsRejected = "CredentialsRejected"
+
+	// ReasonClusterUnreachable means the status of the nodes and the resources can not be collected from the cluster.
+	ReasonClusterUnreachable = "ClusterUnreachable"
+	// ReasonClusterNotReady means the cluster is reachable but has no ready node.
+	ReasonClusterNotReady = "ClusterNotReady"
+	// ReasonClusterReady means the cluster is healthy and ready to accept workloads.
+	ReasonClusterReady = "ClusterReady"
+
+	// ReasonClusterAdded means the cluster is added to the pool.
+	ReasonClusterAdded = "ClusterAdded"
+	// ReasonClusterAddFailed means the cluster can not be added to the pool.
+	ReasonClusterAddFailed = "ClusterAddFailed"
+	// ReasonClusterRemoved means the cluster is removed from the pool.
+	ReasonClusterRemoved = "ClusterRemoved"
+	// ReasonClusterStarted means the cluster is started.
+	ReasonClusterStarted = "ClusterStarted"
+	// ReasonClusterStopped means the cluster is stopped.
+	ReasonClusterStopped = "ClusterStopped"
+	// ReasonClusterDisabled means the cluster is disabled.
+	ReasonClusterDisabled = "ClusterDisabled"
+	// ReasonCircuitOpen means the requests to the cluster fail fast, as its API server failed them consecutively.
+	ReasonCircuitOpen = "CircuitOpen"
+	// ReasonCircuitClosed means the requests to the cluster are sent again, as its API server recovered.
+	ReasonCircuitClosed = "CircuitClosed"
+)
+
 // ClusterStatus defines the observed state of Cluster
 type ClusterStatus struct {
 	// Version represents version of the member cluster.
@@ -146,6 +234,10 @@ type ClusterStatus struct {
 	// NodeSummary represents the summary of nodes status in the member cluster.
 	// +optional
 	NodeSummary *NodeSummary `json:"nodeSummary,omitempty"`
+
+	// ResourceSummary represents the summary of resources in the member cluster.
+	// +optional
+	ResourceSummary *ResourceSummary `json:"resourceSummary,omitempty"`
 }
 
 // APIEnablement is a list of API resource, it is used to expose the name of the
@@ -179,19 +271,62 @@ type NodeSummary struct {
 	// ReadyNum is the number of ready nodes in the cluster.
 	// +optional
 	ReadyNum int32 `json:"ready,omitempty"`
+
+	// Capacity is the sum of the capacity of all nodes in the cluster.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+
+	// Allocatable is the sum of the allocatable resources of all ready nodes in the cluster.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+}
+
+// ResourceSummary represents the summary of resources in the member cluster.
+type ResourceSummary struct {
+	ResourceUsage `json:",inline"`
+
+	// NodePools is the resource summary of every node pool in the cluster.
+	// Nodes are grouped into pools by the value of the node pool label.
+	// +optional
+	NodePools []NodePoolSummary `json:"nodePools,omitempty"`
+}
+
+// NodePoolSummary represents the summary of resources of a node pool.
+type NodePoolSummary struct {
+	// Name is the value of the node pool label shared by the nodes in the pool.
+	Name string `json:"name"`
+
+	ResourceUsage `json:",inline"`
+}
+
+// ResourceUsage represents the usage of resources of a group of nodes.
+type ResourceUsage struct {
+	// Allocatable represents the resources of all ready and schedulable nodes that can be used by pods.
+	// +optional
+	Allocatable corev1.ResourceList `json:"allocatable,omitempty"`
+
+	// Allocated represents the resources requested by all the pods scheduled to the nodes.
+	// +optional
+	Allocated corev1.ResourceList `json:"allocated,omitempty"`
+
+	// Available represents the resources that can still be allocated, it is Allocatable minus Allocated.
+	// +optional
+	Available corev1.ResourceList `json:"available,omitempty"`
 }
 
 // +genclient:nonNamespaced
 // +k8s:openapi-gen=true
 // +kubebuilder:object:root=true
-// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:resource:scope=Cluster,shortName={mc,mcl},categories={multicluster}
 // +kubebuilder:subresource:status
-// +kubebuilder:printcolumn:name="ENDPOINT",type="string",priority=1,JSONPath=".spec.endpoint",description="The cluster endpoint"
-// +kubebuilder:printcolumn:name="ENABLE",type="boolean",priority=1,JSONPath=".spec.enabled",description="The cluster enable status"
-// +kubebuilder:printcolumn:name="PROVIDER",type="string",priority=1,JSONPath=".spec.provider",description="The cluster provider"
+// +kubebuilder:printcolumn:name="ENDPOINT",type="string",priority=1,JSONPath=".spec.connect.endpoint",description="The cluster endpoint"
+// +kubebuilder:printcolumn:name="PROVIDER",type="string",JSONPath=".spec.provider",description="The cluster provider"
+// +kubebuilder:printcolumn:name="ZONE",type="string",JSONPath=".spec.region.zone",description="The zone of the cluster located in"
+// +kubebuilder:printcolumn:name="DISABLED",type="boolean",priority=1,JSONPath=".spec.disabled",description="Whether the cluster is disabled"
 // +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status.version",description="The cluster version"
-// +kubebuilder:printcolumn:name="TOTAL",type="integer",JSONPath=".status.nodeSummary.total",description="The total number of node"
-// +kubebuilder:printcolumn:name="READY",type="integer",JSONPath=".status.nodeSummary.ready",description="The ready number of node"
+// +kubebuilder:printcolumn:name="READY-NODES",type="integer",JSONPath=".status.nodeSummary.ready",description="The ready number of node"
+// +kubebuilder:printcolumn:name="TOTAL-NODES",type="integer",JSONPath=".status.nodeSummary.total",description="The total number of node"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="The ready condition of the cluster"
 // +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
 
 // Cluster is the Schema for the clusters API
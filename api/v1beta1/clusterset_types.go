@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterSetSpec defines the desired state of ClusterSet
+type ClusterSetSpec struct {
+	// ClusterSelector selects the clusters by their labels.
+	// An empty selector selects all the clusters, a nil selector selects none.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// ClusterNames is the explicit list of the clusters in the set.
+	// The clusters selected by ClusterSelector and listed in ClusterNames are all members of the set.
+	// +optional
+	ClusterNames []string `json:"clusterNames,omitempty"`
+}
+
+// ClusterSetStatus defines the observed state of ClusterSet
+type ClusterSetStatus struct {
+	// ObservedGeneration is the most recent generation observed by the cluster set controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Clusters is the sorted name list of the clusters resolved as members of the set.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// TotalNum is the total number of clusters in the set.
+	// +optional
+	TotalNum int32 `json:"total,omitempty"`
+
+	// ReadyNum is the number of ready clusters in the set.
+	// +optional
+	ReadyNum int32 `json:"ready,omitempty"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName={mcs},categories={multicluster}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="READY",type="integer",JSONPath=".status.ready",description="The ready number of cluster"
+// +kubebuilder:printcolumn:name="TOTAL",type="integer",JSONPath=".status.total",description="The total number of cluster"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// ClusterSet is the Schema for the clustersets API
+type ClusterSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterSetSpec `json:"spec,omitempty"`
+	// +optional
+	Status ClusterSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterSetList contains a list of ClusterSet
+type ClusterSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterSet{}, &ClusterSetList{})
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RBACSyncState is the sync state of a federated RBAC object in a member cluster.
+type RBACSyncState struct {
+	// Cluster is the name of the member cluster.
+	Cluster string `json:"cluster"`
+	// Synced is true if the object in the cluster is consistent with the federated object.
+	Synced bool `json:"synced"`
+	// Message is the reason why the object is not synced.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName={frs},categories={multicluster}
+// +kubebuilder:printcolumn:name="KIND",type=string,JSONPath=".source.kind"
+// +kubebuilder:printcolumn:name="NAMESPACE",type=string,JSONPath=".source.namespace"
+// +kubebuilder:printcolumn:name="SOURCE",type=string,JSONPath=".source.name"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// FederatedRBACStatus is the Schema for the federatedrbacstatuses API, it reports the sync state
+// of a federated RBAC object of the hub in the member clusters it is propagated to. It is kept
+// apart from the RBAC object, because updating an RBAC object requires to hold its permissions.
+type FederatedRBACStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Source is the federated RBAC object in the hub.
+	Source ObjectReference `json:"source"`
+	// Clusters are the sync states of the object in the member clusters it is propagated to.
+	// +optional
+	Clusters []RBACSyncState `json:"clusters,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// FederatedRBACStatusList contains a list of FederatedRBACStatus
+type FederatedRBACStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FederatedRBACStatus `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FederatedRBACStatus{}, &FederatedRBACStatusList{})
+}
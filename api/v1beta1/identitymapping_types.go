@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IdentityMappingSpec defines the desired state of IdentityMapping
+type IdentityMappingSpec struct {
+	// TargetCluster selects the clusters the mapping applies to.
+	// An empty affinity targets all the clusters.
+	// +optional
+	TargetCluster ClusterAffinity `json:"targetCluster,omitempty"`
+	// Rules map the hub identities to the identities in the target clusters,
+	// the first rule matching the hub identity applies.
+	// +kubebuilder:validation:MinItems=1
+	Rules []IdentityMappingRule `json:"rules"`
+}
+
+// IdentityMappingRule maps the hub users and groups it matches to an identity in the member clusters.
+type IdentityMappingRule struct {
+	// Users are the names of the hub users the rule matches.
+	// +optional
+	Users []string `json:"users,omitempty"`
+	// Groups are the hub groups the rule matches, a user in any of the groups is matched.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// Identity is the identity impersonated in the member clusters for the matched users.
+	Identity Identity `json:"identity"`
+}
+
+// Identity is an identity impersonated in a member cluster.
+type Identity struct {
+	// User is the name of the impersonated user, the name of the hub user is kept if it is empty.
+	// +optional
+	User string `json:"user,omitempty"`
+	// Groups are the impersonated groups. The groups of the hub user, except the system groups
+	// such as system:masters, are kept only if both the user and the groups are empty, a remapped
+	// user is impersonated with exactly these groups, even if it is empty.
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName={im},categories={multicluster}
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// IdentityMapping is the Schema for the identitymappings API, it maps the identities of the hub
+// users to the identities impersonated for them in the member clusters. The mappings apply in the
+// order of their names, and the hub users matched by no mapping are refused by the proxy. A rule with
+// an empty identity impersonates the hub users it matches as they are.
+type IdentityMapping struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IdentityMappingSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// IdentityMappingList contains a list of IdentityMapping
+type IdentityMappingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IdentityMapping `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IdentityMapping{}, &IdentityMappingList{})
+}
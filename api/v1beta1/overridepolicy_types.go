@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OverridePolicySpec defines the desired state of OverridePolicy
+type OverridePolicySpec struct {
+	// ResourceSelectors selects the propagated resources in the namespace of the policy to override.
+	// +kubebuilder:validation:MinItems=1
+	ResourceSelectors []ResourceSelector `json:"resourceSelectors"`
+	// OverrideRules are applied in order to the resources propagated to the target clusters.
+	// +optional
+	OverrideRules []OverrideRule `json:"overrideRules,omitempty"`
+}
+
+// OverrideRule overrides the resources propagated to the clusters it targets.
+type OverrideRule struct {
+	// TargetCluster selects the clusters the rule applies to.
+	// An empty affinity targets all the clusters.
+	// +optional
+	TargetCluster ClusterAffinity `json:"targetCluster,omitempty"`
+	// Overriders are applied in the order of field paths, json patches and strategic merge.
+	Overriders Overriders `json:"overriders"`
+}
+
+// Overriders is the set of overrides applied to a resource.
+type Overriders struct {
+	// FieldPaths sets the values of fields, e.g. spec.replicas.
+	// +optional
+	FieldPaths []FieldPathOverrider `json:"fieldPaths,omitempty"`
+	// JSONPatch is a list of RFC 6902 json patch operations.
+	// +optional
+	JSONPatch []JSONPatchOperation `json:"jsonPatch,omitempty"`
+	// StrategicMerge is a strategic merge patch, it falls back to a json merge patch
+	// for the kinds without a registered Go type, e.g. custom resources.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	StrategicMerge *apiextensionsv1.JSON `json:"strategicMerge,omitempty"`
+}
+
+// FieldPathOverrider sets the value of a field.
+type FieldPathOverrider struct {
+	// Path of the field separated by dots, the elements of lists are indexed
+	// by numbers, e.g. spec.template.spec.containers.0.image.
+	// Missing maps on the path are created.
+	Path string `json:"path"`
+	// Value of the field.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Value apiextensionsv1.JSON `json:"value"`
+}
+
+// JSONPatchOperation is a json patch operation.
+type JSONPatchOperation struct {
+	// Op is the operation of the patch.
+	// +kubebuilder:validation:Enum=add;remove;replace;move;copy;test
+	Op string `json:"op"`
+	// Path is the json pointer of the target field, e.g. /spec/replicas.
+	Path string `json:"path"`
+	// From is the json pointer of the source field, used by move and copy.
+	// +optional
+	From string `json:"from,omitempty"`
+	// Value of the operation, used by add, replace and test.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Value *apiextensionsv1.JSON `json:"value,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName={op},categories={multicluster}
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// OverridePolicy is the Schema for the overridepolicies API
+type OverridePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec OverridePolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OverridePolicyList contains a list of OverridePolicy
+type OverridePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OverridePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OverridePolicy{}, &OverridePolicyList{})
+}
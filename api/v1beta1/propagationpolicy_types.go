@@ -0,0 +1,226 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PropagationPolicySpec defines the desired state of PropagationPolicy
+type PropagationPolicySpec struct {
+	// ResourceSelectors selects the resources in the namespace of the policy to propagate, only the namespaced
+	// kinds can be selected.
+	// +kubebuilder:validation:MinItems=1
+	ResourceSelectors []ResourceSelector `json:"resourceSelectors"`
+	// Placement selects the member clusters the resources are propagated to.
+	// +optional
+	Placement Placement `json:"placement,omitempty"`
+	// DeletionPolicy decides what happens to the resources in a member cluster when the
+	// cluster leaves the placement, or the resources are no longer propagated.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy is the way the propagated resources are removed from a member cluster.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the resources from the member cluster.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the resources in the member cluster, and removes the
+	// ownership label and annotation, so they are no longer managed by the hub.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// ResourceSelector selects resources of a kind by name or labels.
+type ResourceSelector struct {
+	// APIVersion of the resources, e.g. apps/v1.
+	APIVersion string `json:"apiVersion"`
+	// Kind of the resources, e.g. Deployment.
+	Kind string `json:"kind"`
+	// Name of the resource. If it is empty, LabelSelector is used to select the resources.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// LabelSelector selects the resources by their labels, it is ignored if Name is not empty.
+	// A nil selector selects all the resources of the kind.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// Placement selects the member clusters the resources are propagated to.
+type Placement struct {
+	ClusterAffinity `json:",inline"`
+	// ClusterTolerations tolerates the taints of the clusters.
+	// Clusters with NoSchedule or NoExecute taints not tolerated are not selected for new placements,
+	// the clusters already bound are only evicted by the NoExecute taints not tolerated, or tolerated
+	// for the tolerationSeconds which have elapsed since the taints were added.
+	// +optional
+	ClusterTolerations []corev1.Toleration `json:"clusterTolerations,omitempty"`
+	// SpreadConstraints spread the resources to the groups of clusters located in the same region,
+	// the constraints are applied in order to the selected clusters.
+	// +optional
+	SpreadConstraints []SpreadConstraint `json:"spreadConstraints,omitempty"`
+	// ReplicaScheduling decides how the replicas of the workloads are scheduled to the selected clusters.
+	// The replicas are duplicated if it is nil.
+	// +optional
+	ReplicaScheduling *ReplicaSchedulingStrategy `json:"replicaScheduling,omitempty"`
+}
+
+// SpreadField is a field of Region used to group the clusters.
+type SpreadField string
+
+const (
+	SpreadByZone     SpreadField = "zone"
+	SpreadByCountry  SpreadField = "country"
+	SpreadByProvince SpreadField = "province"
+	SpreadByCity     SpreadField = "city"
+)
+
+// SpreadConstraint groups the clusters by a field of their regions and selects the groups
+// with the most weight. The clusters without the field are not selected.
+type SpreadConstraint struct {
+	// SpreadByField is the field of Region used to group the clusters.
+	// +kubebuilder:validation:Enum=zone;country;province;city
+	SpreadByField SpreadField `json:"spreadByField"`
+	// MinGroups is the minimum number of groups, the scheduling fails if there are fewer groups.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinGroups int32 `json:"minGroups,omitempty"`
+	// MaxGroups is the maximum number of groups selected, zero means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxGroups int32 `json:"maxGroups,omitempty"`
+}
+
+// ReplicaSchedulingType is the way the replicas are scheduled.
+type ReplicaSchedulingType string
+
+const (
+	// ReplicaSchedulingDuplicated propagates the same replicas to every cluster.
+	ReplicaSchedulingDuplicated ReplicaSchedulingType = "Duplicated"
+	// ReplicaSchedulingDivided divides the replicas among the clusters.
+	ReplicaSchedulingDivided ReplicaSchedulingType = "Divided"
+)
+
+// ReplicaDivisionPreference is the way the replicas are divided.
+type ReplicaDivisionPreference string
+
+const (
+	// ReplicaDivisionWeighted divides the replicas by the static weights of the clusters.
+	ReplicaDivisionWeighted ReplicaDivisionPreference = "Weighted"
+	// ReplicaDivisionAvailableCapacity divides the replicas by the number of replicas
+	// the available resources of the clusters can hold.
+	ReplicaDivisionAvailableCapacity ReplicaDivisionPreference = "AvailableCapacity"
+)
+
+// ReplicaSchedulingStrategy decides how the replicas are scheduled to the clusters.
+type ReplicaSchedulingStrategy struct {
+	// Type is the way the replicas are scheduled.
+	// +kubebuilder:validation:Enum=Duplicated;Divided
+	// +kubebuilder:default=Duplicated
+	// +optional
+	Type ReplicaSchedulingType `json:"type,omitempty"`
+	// DivisionPreference is the way the replicas are divided, it is used if Type is Divided.
+	// +kubebuilder:validation:Enum=Weighted;AvailableCapacity
+	// +kubebuilder:default=Weighted
+	// +optional
+	DivisionPreference ReplicaDivisionPreference `json:"divisionPreference,omitempty"`
+	// StaticWeights are the weights of the clusters used by the Weighted preference.
+	// The weight of a cluster is the weight of the first matching rule, the clusters
+	// matching no rule are not scheduled. All the clusters weigh the same if it is empty.
+	// +optional
+	StaticWeights []StaticClusterWeight `json:"staticWeights,omitempty"`
+}
+
+// StaticClusterWeight is the weight of the clusters matching the affinity.
+type StaticClusterWeight struct {
+	// TargetCluster selects the clusters of the weight.
+	TargetCluster ClusterAffinity `json:"targetCluster"`
+	// Weight of the clusters.
+	// +kubebuilder:validation:Minimum=1
+	Weight int64 `json:"weight"`
+}
+
+// ClusterAffinity selects the member clusters, a cluster is selected if it matches all the non-empty rules.
+type ClusterAffinity struct {
+	// ClusterNames is the list of the selected clusters.
+	// +optional
+	ClusterNames []string `json:"clusterNames,omitempty"`
+	// ClusterSelector selects the clusters by their labels.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// Regions selects the clusters located in any of the regions.
+	// An empty field of a region matches any value.
+	// +optional
+	Regions []Region `json:"regions,omitempty"`
+}
+
+// PropagationPolicyStatus defines the observed state of PropagationPolicy
+type PropagationPolicyStatus struct {
+	// ObservedGeneration is the most recent generation observed by the propagation controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Clusters is the apply status of the resources in every selected cluster.
+	// +optional
+	Clusters []ClusterApplyStatus `json:"clusters,omitempty"`
+}
+
+// ClusterApplyStatus represents the apply status of the resources in a cluster.
+type ClusterApplyStatus struct {
+	// Name of the cluster.
+	Name string `json:"name"`
+
+	// Applied is true if all the resources are applied to the cluster successfully.
+	Applied bool `json:"applied"`
+
+	// Message is the reason why the resources are failed to apply.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName={pp},categories={multicluster}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// PropagationPolicy is the Schema for the propagationpolicies API
+type PropagationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PropagationPolicySpec `json:"spec,omitempty"`
+	// +optional
+	Status PropagationPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PropagationPolicyList contains a list of PropagationPolicy
+type PropagationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PropagationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PropagationPolicy{}, &PropagationPolicyList{})
+}
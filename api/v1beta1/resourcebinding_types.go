@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PropagationPolicyLabel is the label of the ResourceBindings with the name of their PropagationPolicy.
+	PropagationPolicyLabel = "sumengzs.cn/propagation-policy"
+	// ManagedByLabel is the label of the resources propagated to the member clusters,
+	// its value is the field manager of the propagation.
+	ManagedByLabel = "sumengzs.cn/managed-by"
+	// ResourceBindingAnnotation is the annotation of the resources propagated to the member
+	// clusters, its value is the namespace/name of the ResourceBinding of the resource in the hub.
+	ResourceBindingAnnotation = "sumengzs.cn/resource-binding"
+	// ResourceBindingFinalizer holds the ResourceBinding until the resource is removed
+	// from all the clusters it is applied to.
+	ResourceBindingFinalizer = "sumengzs.cn/resource-binding"
+)
+
+const (
+	// ResourceBindingConditionScheduled means the resource is scheduled to the clusters.
+	ResourceBindingConditionScheduled = "Scheduled"
+	// ResourceBindingConditionReady means the resource is ready in all the clusters it is scheduled to.
+	ResourceBindingConditionReady = "Ready"
+)
+
+// ResourceBindingSpec defines the desired state of ResourceBinding
+type ResourceBindingSpec struct {
+	// Resource is the propagated resource in the hub.
+	Resource ObjectReference `json:"resource"`
+	// Replicas of the resource in the hub, it is zero if the resource has no replicas.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// Clusters are the clusters the resource is scheduled to.
+	// +optional
+	Clusters []TargetCluster `json:"clusters,omitempty"`
+	// DeletionPolicy decides what happens to the resource in the clusters it is applied
+	// to but no longer scheduled to, or when the binding is deleted.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ObjectReference references a resource in the hub.
+type ObjectReference struct {
+	// APIVersion of the resource.
+	APIVersion string `json:"apiVersion"`
+	// Kind of the resource.
+	Kind string `json:"kind"`
+	// Namespace of the resource.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the resource.
+	Name string `json:"name"`
+}
+
+// TargetCluster is a cluster the resource is scheduled to.
+type TargetCluster struct {
+	// Name of the cluster.
+	Name string `json:"name"`
+	// Replicas of the resource in the cluster.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+}
+
+// ResourceBindingStatus defines the observed state of ResourceBinding
+type ResourceBindingStatus struct {
+	// Conditions of the binding, e.g. Scheduled and Ready.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// AggregatedStatus is the status of the resource in every cluster it is scheduled to.
+	// +optional
+	AggregatedStatus []AggregatedStatusItem `json:"aggregatedStatus,omitempty"`
+
+	// AppliedClusters are the clusters the resource has been applied to and not removed from yet.
+	// +optional
+	AppliedClusters []string `json:"appliedClusters,omitempty"`
+}
+
+// AggregatedStatusItem is the status of the resource in a cluster.
+type AggregatedStatusItem struct {
+	// ClusterName is the name of the cluster.
+	ClusterName string `json:"clusterName"`
+
+	// Ready is true if the resource is rolled out and healthy in the cluster.
+	Ready bool `json:"ready"`
+
+	// Status is the status of the resource reflected by the interpreter of its kind.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Status *apiextensionsv1.JSON `json:"status,omitempty"`
+
+	// Message is the reason why the resource is not ready.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName={rb},categories={multicluster}
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="KIND",type=string,JSONPath=".spec.resource.kind"
+// +kubebuilder:printcolumn:name="RESOURCE",type=string,JSONPath=".spec.resource.name"
+// +kubebuilder:printcolumn:name="REPLICAS",type=integer,JSONPath=".spec.replicas"
+// +kubebuilder:printcolumn:name="SCHEDULED",type=string,JSONPath=".status.conditions[?(@.type==\"Scheduled\")].status"
+// +kubebuilder:printcolumn:name="READY",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=".metadata.creationTimestamp"
+
+// ResourceBinding is the Schema for the resourcebindings API, it records the clusters
+// a propagated resource is scheduled to and the status of the resource in them.
+type ResourceBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResourceBindingSpec `json:"spec,omitempty"`
+	// +optional
+	Status ResourceBindingStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ResourceBindingList contains a list of ResourceBinding
+type ResourceBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourceBinding{}, &ResourceBindingList{})
+}
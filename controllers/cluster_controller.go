@@ -18,11 +18,23 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/mapper"
+	"github.com/sumengzs/multi-cluster/pkg/metrics"
 	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/status"
+	"github.com/sumengzs/multi-cluster/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,32 +44,312 @@ import (
 	"github.com/sumengzs/multi-cluster/api/v1beta1"
 )
 
+// cacheSyncTimeout is the time to wait for the cache of a member cluster to be synced
+// when collecting its status, the cache is reported not synced after it.
+const cacheSyncTimeout = time.Second
+
 // ClusterController reconciles a Cluster object
 type ClusterController struct {
 	client.Client
 	Pool   pool.Interface
 	Scheme *runtime.Scheme
+	// NodePoolLabel is the node label used to group the resource summary into node pools.
+	NodePoolLabel string
+	// StatusSyncPeriod is the period to collect the status of the member clusters.
+	StatusSyncPeriod time.Duration
+	// Recorder records the lifecycle transitions of the member clusters as events on them.
+	Recorder record.EventRecorder
+	// CacheOptions configures the caches of the member clusters.
+	CacheOptions cluster.CacheOptions
+	// BreakerOptions configures the circuit breakers of the clients of the member clusters.
+	BreakerOptions cluster.BreakerOptions
+	// DiscoveryOptions configures the persisted discovery of the REST mappers of the member clusters.
+	DiscoveryOptions mapper.DiscoveryOptions
+	// RateLimit is the default rate limit of the requests to each member cluster, shared by all its clients.
+	// The clusters are unlimited by the pool if its QPS is zero and they have no rate limit of their own.
+	RateLimit v1beta1.RateLimit
+	// TaintGracePeriod is how long the cluster must stay unhealthy before the NoSchedule unreachable
+	// or not-ready taint is added, so transient failures do not block the placements.
+	TaintGracePeriod time.Duration
+	// EvictionGracePeriod is how long the cluster must stay unhealthy before the NoExecute unreachable
+	// or not-ready taint is added, evicting the workloads which do not tolerate it by tolerationSeconds.
+	EvictionGracePeriod time.Duration
 }
 
 //+kubebuilder:rbac:groups=sumengzs.cn,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=sumengzs.cn,resources=clusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=sumengzs.cn,resources=clusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Cluster object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It builds the member cluster into the pool, reporting the build failures in the Connected condition,
+// disables or enables the member cluster when spec.disabled is toggled, applies the rate limit of the member cluster,
+// collects the node and resource summary of the member cluster from its cache,
+// taints the cluster according to its health and the circuit of its clients after the grace periods, and requeues the cluster every StatusSyncPeriod
+// to keep the status up to date.
+// The transitions of the conditions are recorded as events on the cluster.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.11.0/pkg/reconcile
 func (r *ClusterController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = log.FromContext(ctx)
 
-	// TODO(user): your logic here
+	obj := &v1beta1.Cluster{}
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	clu := r.Pool.Cluster(req.Name)
+	if clu == nil {
+		var err error
+		if clu, err = r.build(obj.Name); err != nil {
+			r.setCondition(obj, metav1.Condition{
+				Type:    v1beta1.ClusterConditionConnected,
+				Status:  metav1.ConditionFalse,
+				Reason:  cluster.Reason(err),
+				Message: err.Error(),
+			})
+			if err := r.Status().Update(ctx, obj); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: r.StatusSyncPeriod}, nil
+		}
+	}
+	if err := r.toggle(obj, clu); err != nil {
+		return ctrl.Result{}, err
+	}
+	// the clients of the cluster share the limiter of the pool, so the rate limit changed or removed applies at once
+	qps, burst, _ := cluster.EffectiveRateLimit(&r.RateLimit, obj.Spec.RateLimit)
+	r.Pool.RateLimiter(obj.Name, qps, burst)
+	r.setCondition(obj, runningCondition(clu.Status()))
+	if clu.Status() < cluster.Started {
+		if err := r.Status().Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.StatusSyncPeriod}, nil
+	}
+	_, connectErr := clu.Discovery().ServerVersion()
+	r.setCondition(obj, connectedCondition(connectErr))
+
+	nodeSummary, resourceSummary, err := status.Summary(ctx, clu, r.NodePoolLabel)
+	if errors.Is(connectErr, cluster.ErrClusterUnavailable) {
+		// the cache may still serve the summary, but the clients fail fast until the circuit is closed
+		err = connectErr
+	}
+	reachable := err == nil
+	ready := reachable && nodeSummary.ReadyNum > 0
+	if !reachable {
+		klog.Errorf("error collecting summary of cluster %s: %v", clu.Name(), err)
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, cacheSyncTimeout)
+	synced := clu.Cache().WaitForCacheSync(syncCtx)
+	cancel()
+	var totalNodes, readyNodes int32
+	if reachable {
+		totalNodes, readyNodes = nodeSummary.TotalNum, nodeSummary.ReadyNum
+	}
+	metrics.RecordHealth(clu.Name(), ready, synced, totalNodes, readyNodes)
+
+	if reachable {
+		obj.Status.NodeSummary = nodeSummary
+		obj.Status.ResourceSummary = resourceSummary
+	}
+	r.setCondition(obj, readyCondition(reachable, ready, err))
+
+	var unhealthy time.Duration
+	if condition := meta.FindStatusCondition(obj.Status.Conditions, v1beta1.ClusterConditionReady); condition.Status != metav1.ConditionTrue {
+		unhealthy = time.Since(condition.LastTransitionTime.Time)
+	}
+	if taints, changed := r.healthTaints(obj.Spec.Taints, reachable, ready, unhealthy); changed {
+		// patch a copy, the update would overwrite the conditions set above with the ones stored
+		tainted := obj.DeepCopy()
+		tainted.Spec.Taints = taints
+		if err := r.Patch(ctx, tainted, client.MergeFrom(obj)); err != nil {
+			return ctrl.Result{}, err
+		}
+		obj.Spec.Taints = tainted.Spec.Taints
+		obj.ResourceVersion = tainted.ResourceVersion
+	}
+
+	if err := r.Status().Update(ctx, obj); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.StatusSyncPeriod}, nil
+}
+
+// toggle disables the member cluster or enables it again according to the spec,
+// the cluster stays in the pool, and is restarted when it is enabled.
+func (r *ClusterController) toggle(obj *v1beta1.Cluster, clu cluster.Interface) error {
+	switch {
+	case obj.Spec.Disabled && clu.Status() != cluster.Disabled:
+		return r.Pool.Disable(clu.Name())
+	case !obj.Spec.Disabled && clu.Status() == cluster.Disabled:
+		return r.Pool.Enable(clu.Name())
+	}
+	return nil
+}
+
+// build builds the member cluster and adds it to the pool.
+func (r *ClusterController) build(name string) (cluster.Interface, error) {
+	clu, err := cluster.
+		By(r.Client).
+		WithScheme(r.Scheme).
+		WithEventRecorder(r.Recorder).
+		WithCacheOptions(r.CacheOptions).
+		WithRateLimit(r.RateLimit, r.Pool).
+		WithDiscoveryOptions(r.DiscoveryOptions).
+		Named(name).
+		WithOptions(cluster.WithBreakerOptions(r.BreakerOptions)).
+		Complete()
+	if err != nil {
+		klog.Errorf("error creating cluster %s: %v", name, err)
+		metrics.ClustersFailed.Inc()
+		return nil, err
+	}
+	if err = r.Pool.Add(clu); err != nil {
+		klog.Errorf("error add cluster to pool %s: %v", name, err)
+		return nil, err
+	}
+	return clu, nil
+}
+
+// setCondition sets the condition of the cluster, and records its transition as an event.
+func (r *ClusterController) setCondition(obj *v1beta1.Cluster, condition metav1.Condition) {
+	previous := meta.FindStatusCondition(obj.Status.Conditions, condition.Type)
+	meta.SetStatusCondition(&obj.Status.Conditions, condition)
+	if r.Recorder == nil || previous != nil && previous.Status == condition.Status && previous.Reason == condition.Reason {
+		return
+	}
+	eventType := corev1.EventTypeNormal
+	if condition.Status != metav1.ConditionTrue {
+		eventType = corev1.EventTypeWarning
+	}
+	r.Recorder.Event(obj, eventType, condition.Reason, condition.Message)
+}
 
-	return ctrl.Result{}, nil
+// healthTaints adds the unreachable or not-ready taints according to the health of the cluster,
+// and removes the ones no longer matching. The NoSchedule taint is added once the cluster has been
+// unhealthy for TaintGracePeriod, and the NoExecute taint once it has been unhealthy for EvictionGracePeriod.
+func (r *ClusterController) healthTaints(taints []corev1.Taint, reachable, ready bool, unhealthy time.Duration) ([]corev1.Taint, bool) {
+	var key string
+	switch {
+	case !reachable:
+		key = v1beta1.TaintClusterUnreachable
+	case !ready:
+		key = v1beta1.TaintClusterNotReady
+	}
+	var toAdd, toRemove []corev1.Taint
+	for _, k := range []string{v1beta1.TaintClusterUnreachable, v1beta1.TaintClusterNotReady} {
+		for _, effect := range []corev1.TaintEffect{corev1.TaintEffectNoSchedule, corev1.TaintEffectNoExecute} {
+			taint := corev1.Taint{Key: k, Effect: effect}
+			grace := r.TaintGracePeriod
+			if effect == corev1.TaintEffectNoExecute {
+				grace = r.EvictionGracePeriod
+			}
+			switch {
+			case k != key:
+				toRemove = append(toRemove, taint)
+			case unhealthy >= grace:
+				toAdd = append(toAdd, taint)
+			}
+		}
+	}
+	return utils.AddOrRemoveTaints(taints, toAdd, toRemove)
+}
+
+func runningCondition(code cluster.Code) metav1.Condition {
+	switch {
+	case code >= cluster.Started:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionRunning,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1beta1.ReasonClusterStarted,
+			Message: "cluster is started in the pool",
+		}
+	case code == cluster.Disabled:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionRunning,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonClusterDisabled,
+			Message: "cluster is disabled",
+		}
+	default:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionRunning,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonClusterStopped,
+			Message: "cluster is stopped",
+		}
+	}
+}
+
+func connectedCondition(err error) metav1.Condition {
+	switch {
+	case err == nil:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionConnected,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1beta1.ReasonConnected,
+			Message: "cluster api server is reachable and accepts the credentials",
+		}
+	case errors.Is(err, cluster.ErrClusterUnavailable):
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionConnected,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonCircuitOpen,
+			Message: err.Error(),
+		}
+	case apierrors.IsUnauthorized(err) || apierrors.IsForbidden(err):
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionConnected,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonCredentialsRejected,
+			Message: err.Error(),
+		}
+	default:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionConnected,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonEndpointUnreachable,
+			Message: err.Error(),
+		}
+	}
+}
+
+func readyCondition(reachable, ready bool, err error) metav1.Condition {
+	switch {
+	case errors.Is(err, cluster.ErrClusterUnavailable):
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonCircuitOpen,
+			Message: err.Error(),
+		}
+	case !reachable:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonClusterUnreachable,
+			Message: err.Error(),
+		}
+	case !ready:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1beta1.ReasonClusterNotReady,
+			Message: "cluster has no ready node",
+		}
+	default:
+		return metav1.Condition{
+			Type:    v1beta1.ClusterConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1beta1.ReasonClusterReady,
+			Message: "cluster is healthy and ready to accept workloads",
+		}
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -73,30 +365,19 @@ func (r *ClusterController) Predicate() predicate.Predicate {
 		DeleteFunc: func(event event.DeleteEvent) bool {
 			clu := event.Object.(*v1beta1.Cluster)
 			r.Pool.Remove(clu.Name)
+			if store := r.DiscoveryOptions.Store; store != nil {
+				if err := store.Delete(context.Background(), clu.Name); err != nil {
+					klog.Errorf("error deleting discovery of cluster %s: %v", clu.Name, err)
+				}
+			}
 			return false
 		},
 		UpdateFunc: func(event event.UpdateEvent) bool {
-			return false
+			oldSpec, newSpec := event.ObjectOld.(*v1beta1.Cluster).Spec, event.ObjectNew.(*v1beta1.Cluster).Spec
+			return oldSpec.Disabled != newSpec.Disabled || !reflect.DeepEqual(oldSpec.RateLimit, newSpec.RateLimit)
 		},
 		CreateFunc: func(event event.CreateEvent) bool {
-			clu := event.Object.(*v1beta1.Cluster)
-			cc, err := cluster.
-				By(r.Client).
-				WithScheme(r.Scheme).
-				Named(clu.Name).
-				WithOptions().
-				Complete()
-			if err != nil {
-				klog.Errorf("error creating cluster %s: %v", clu.Name, err)
-				return false
-			}
-			err = r.Pool.Add(cc)
-			if err != nil {
-				klog.Errorf("error add cluster to pool %s: %v", cc.Name(), err)
-				r.Pool.Remove(cc.Name())
-				return false
-			}
-			return false
+			return true
 		},
 	}
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ClusterSetController reconciles a ClusterSet object
+type ClusterSetController struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=clustersets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=clustersets/status,verbs=get;update;patch
+
+// Reconcile resolves the members of the ClusterSet and reports them with
+// the aggregated readiness in the status.
+func (r *ClusterSetController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	set := &v1beta1.ClusterSet{}
+	if err := r.Get(ctx, req.NamespacedName, set); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	list := &v1beta1.ClusterList{}
+	if err := r.List(ctx, list); err != nil {
+		return ctrl.Result{}, err
+	}
+	members, err := utils.ResolveClusterSet(set, list.Items)
+	if err != nil {
+		klog.Errorf("error resolving cluster set %s: %v", set.Name, err)
+		return ctrl.Result{}, nil
+	}
+
+	status := v1beta1.ClusterSetStatus{
+		ObservedGeneration: set.Generation,
+		TotalNum:           int32(len(members)),
+	}
+	for i := range members {
+		status.Clusters = append(status.Clusters, members[i].Name)
+		if utils.IsClusterReady(&members[i]) {
+			status.ReadyNum++
+		}
+	}
+	if equality.Semantic.DeepEqual(set.Status, status) {
+		return ctrl.Result{}, nil
+	}
+	set.Status = status
+	return ctrl.Result{}, r.Status().Update(ctx, set)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterSetController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ClusterSet{}).
+		Watches(&source.Kind{Type: &v1beta1.Cluster{}}, handler.EnqueueRequestsFromMapFunc(r.clusterSets)).
+		Complete(r)
+}
+
+// clusterSets enqueues all the ClusterSets when a cluster changes, because the change
+// of labels or readiness may affect the membership and status of any set.
+func (r *ClusterSetController) clusterSets(_ client.Object) []reconcile.Request {
+	list := &v1beta1.ClusterSetList{}
+	if err := r.List(context.TODO(), list); err != nil {
+		klog.Errorf("error listing cluster sets: %v", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, set := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name}})
+	}
+	return requests
+}
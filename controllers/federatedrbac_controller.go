@@ -0,0 +1,382 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FederatedRBACController propagates the federated RBAC objects of the hub, the ClusterRoles and
+// ClusterRoleBindings labelled federated, and the labelled Roles and RoleBindings in the namespace,
+// to all the member clusters or the members of the ClusterSet in their annotation. It reverts the
+// drift of the propagated objects in the members, and reports their sync state per cluster in the
+// FederatedRBACStatus of every federated object. The objects granting permissions the operator
+// does not hold in a member are never propagated to it, and the existing objects in the members
+// not propagated from the hub are never overwritten. The federated objects are held by a finalizer
+// until they are removed from every member, and the objects left in the members by the hub objects
+// deleted or no longer federated are removed every SyncPeriod.
+type FederatedRBACController struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// Namespace is the namespace in the hub the federated Roles and RoleBindings are propagated from.
+	Namespace string
+	// SyncPeriod is the period to propagate the federated objects again, which propagates them to
+	// the newly started member clusters and retries the failed ones.
+	SyncPeriod time.Duration
+}
+
+// rbacReconciler reconciles the federated RBAC objects of a kind.
+type rbacReconciler struct {
+	*FederatedRBACController
+	kind       string
+	namespaced bool
+	newObject  func() client.Object
+	newList    func() client.ObjectList
+
+	watcher *pool.Watcher
+	queue   *pool.Queue
+}
+
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings;roles;rolebindings,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles/finalizers;clusterrolebindings/finalizers;roles/finalizers;rolebindings/finalizers,verbs=update
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=federatedrbacstatuses,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile propagates the federated object to the member clusters it selects, and removes the
+// object from the other members, or from all of them when it is no longer federated. The federated
+// object is held by the finalizer until it is removed from every member in the pool.
+func (r *rbacReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	obj := r.newObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		obj = nil
+	}
+	federated := obj != nil && r.federated(obj)
+	if federated && !controllerutil.ContainsFinalizer(obj, rbac.Finalizer) {
+		controllerutil.AddFinalizer(obj, rbac.Finalizer)
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var selected map[string]cluster.Interface
+	var desired client.Object
+	var desiredErr error
+	if federated {
+		desired, desiredErr = rbac.Desired(obj)
+		var err error
+		if set := obj.GetAnnotations()[rbac.ClusterSetAnnotation]; len(set) != 0 {
+			selected, err = r.Pool.ClusterSet(ctx, set)
+		} else {
+			selected = r.Pool.Clusters()
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var mu sync.Mutex
+	var states []v1beta1.RBACSyncState
+	report := func(state v1beta1.RBACSyncState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, state)
+	}
+	// removed is whether the object is removed from all the members it is not propagated to
+	removed := true
+	r.Pool.ForEach(ctx, r.Pool.Clusters(), func(ctx context.Context, clu cluster.Interface) error {
+		name := clu.Name()
+		if clu.Status() < cluster.Started {
+			mu.Lock()
+			removed = removed && selected[name] != nil
+			mu.Unlock()
+			if selected[name] != nil {
+				report(v1beta1.RBACSyncState{Cluster: name, Message: "cluster is not started"})
+			}
+			return nil
+		}
+		if err := r.watch(ctx, clu); err != nil {
+			klog.Errorf("error watching %s in cluster %s: %v", r.kind, name, err)
+		}
+		if selected[name] == nil {
+			if err := r.remove(ctx, clu, req.NamespacedName); err != nil {
+				klog.Errorf("error removing %s %s from cluster %s: %v", r.kind, req, name, err)
+				mu.Lock()
+				removed = false
+				mu.Unlock()
+			}
+			return nil
+		}
+		state := v1beta1.RBACSyncState{Cluster: name, Synced: true}
+		err := desiredErr
+		if err == nil {
+			err = r.sync(ctx, clu, desired)
+		}
+		if err != nil {
+			state.Synced, state.Message = false, err.Error()
+		}
+		report(state)
+		return nil
+	})
+
+	status := &v1beta1.FederatedRBACStatus{ObjectMeta: metav1.ObjectMeta{Name: rbac.StatusName(r.kind, req.NamespacedName)}}
+	if !federated {
+		if err := r.Delete(ctx, status); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		if obj == nil || !controllerutil.ContainsFinalizer(obj, rbac.Finalizer) {
+			return ctrl.Result{}, nil
+		}
+		if !removed {
+			return ctrl.Result{RequeueAfter: r.SyncPeriod}, nil
+		}
+		controllerutil.RemoveFinalizer(obj, rbac.Finalizer)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, obj))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Cluster < states[j].Cluster })
+	if err := r.report(ctx, status, req.NamespacedName, states); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.SyncPeriod}, nil
+}
+
+// federated returns true if the object in the hub is federated and is not being deleted.
+func (r *rbacReconciler) federated(obj client.Object) bool {
+	return obj.GetDeletionTimestamp().IsZero() && rbac.IsFederated(obj) &&
+		(!r.namespaced || obj.GetNamespace() == r.Namespace)
+}
+
+// sync makes the object in the member cluster consistent with the desired object.
+func (r *rbacReconciler) sync(ctx context.Context, clu cluster.Interface, desired client.Object) error {
+	current := r.newObject()
+	if err := clu.Cache().Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := rbac.CheckEscalation(ctx, desired, clu.Cache(), rbac.SelfSubjectAccessReviewer(clu.Client())); err != nil {
+			return err
+		}
+		return clu.Client().Create(ctx, desired.DeepCopyObject().(client.Object))
+	}
+	if source, ok := rbac.Source(current); !ok ||
+		source.String() != desired.GetAnnotations()[rbac.SourceAnnotation] {
+		return fmt.Errorf("%s %s exists in the cluster and is not propagated from the hub", r.kind, desired.GetName())
+	}
+	if rbac.InSync(desired, current) {
+		return nil
+	}
+	if err := rbac.CheckEscalation(ctx, desired, clu.Cache(), rbac.SelfSubjectAccessReviewer(clu.Client())); err != nil {
+		return err
+	}
+	current = current.DeepCopyObject().(client.Object)
+	rbac.Copy(desired, current)
+	return clu.Client().Update(ctx, current)
+}
+
+// remove removes the objects propagated from the hub object from the member cluster.
+func (r *rbacReconciler) remove(ctx context.Context, clu cluster.Interface, key types.NamespacedName) error {
+	list := r.newList()
+	if err := clu.Cache().List(ctx, list, client.MatchingLabels{v1beta1.ManagedByLabel: rbac.ManagedBy}); err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range objs {
+		obj := item.(client.Object)
+		if source, ok := rbac.Source(obj); !ok || source != key {
+			continue
+		}
+		if err := clu.Client().Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweep removes the objects from the started member clusters whose objects in the hub no longer exist
+// or are no longer federated, e.g. the objects deleted from the hub while the manager is down.
+func (r *rbacReconciler) sweep(ctx context.Context) {
+	for name, clu := range r.Pool.Clusters() {
+		if clu.Status() < cluster.Started {
+			continue
+		}
+		if err := r.removeOrphans(ctx, clu); err != nil {
+			klog.Errorf("error removing orphan %s from cluster %s: %v", r.kind, name, err)
+		}
+	}
+}
+
+// removeOrphans removes the objects propagated from the hub objects which no longer exist or are no
+// longer federated from the member cluster.
+func (r *rbacReconciler) removeOrphans(ctx context.Context, clu cluster.Interface) error {
+	list := r.newList()
+	if err := clu.Cache().List(ctx, list, client.MatchingLabels{v1beta1.ManagedByLabel: rbac.ManagedBy}); err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range objs {
+		obj := item.(client.Object)
+		source, ok := rbac.Source(obj)
+		if !ok {
+			continue
+		}
+		hub := r.newObject()
+		if err := r.Get(ctx, source, hub); err == nil && r.federated(hub) {
+			continue
+		} else if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		klog.Infof("removing orphan %s %s from cluster %s", r.kind, source, clu.Name())
+		if err := clu.Client().Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// report reports the sync states in the status of the federated object.
+func (r *rbacReconciler) report(ctx context.Context, status *v1beta1.FederatedRBACStatus, key types.NamespacedName,
+	states []v1beta1.RBACSyncState) error {
+	src := v1beta1.ObjectReference{
+		APIVersion: rbacv1.SchemeGroupVersion.String(),
+		Kind:       r.kind,
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(status), status); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		status.Source, status.Clusters = src, states
+		return r.Create(ctx, status)
+	}
+	if equality.Semantic.DeepEqual(status.Source, src) && equality.Semantic.DeepEqual(status.Clusters, states) {
+		return nil
+	}
+	status.Source, status.Clusters = src, states
+	return r.Update(ctx, status)
+}
+
+// watch watches the objects of the kind in the cache of the member cluster, so the
+// federated objects are reconciled when the objects propagated from them drift.
+func (r *rbacReconciler) watch(ctx context.Context, clu cluster.Interface) error {
+	return r.watcher.Watch(ctx, clu, rbacv1.SchemeGroupVersion.WithKind(r.kind), r.newObject(), func(obj metav1.Object) {
+		propagated, ok := obj.(client.Object)
+		if !ok {
+			return
+		}
+		if source, ok := rbac.Source(propagated); ok {
+			r.queue.Add(source)
+		}
+	})
+}
+
+// SetupWithManager sets up the controllers of the federated RBAC objects of every kind with the Manager.
+func (r *FederatedRBACController) SetupWithManager(mgr ctrl.Manager) error {
+	reconcilers := []*rbacReconciler{
+		{
+			kind:      "ClusterRole",
+			newObject: func() client.Object { return &rbacv1.ClusterRole{} },
+			newList:   func() client.ObjectList { return &rbacv1.ClusterRoleList{} },
+		},
+		{
+			kind:      "ClusterRoleBinding",
+			newObject: func() client.Object { return &rbacv1.ClusterRoleBinding{} },
+			newList:   func() client.ObjectList { return &rbacv1.ClusterRoleBindingList{} },
+		},
+		{
+			kind:       "Role",
+			namespaced: true,
+			newObject:  func() client.Object { return &rbacv1.Role{} },
+			newList:    func() client.ObjectList { return &rbacv1.RoleList{} },
+		},
+		{
+			kind:       "RoleBinding",
+			namespaced: true,
+			newObject:  func() client.Object { return &rbacv1.RoleBinding{} },
+			newList:    func() client.ObjectList { return &rbacv1.RoleBindingList{} },
+		},
+	}
+	for _, reconciler := range reconcilers {
+		reconciler.FederatedRBACController = r
+		reconciler.watcher = pool.NewWatcher(r.Pool)
+		reconciler.queue = &pool.Queue{}
+		namespaced := reconciler.namespaced
+		federated := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return rbac.IsFederated(obj) && (!namespaced || obj.GetNamespace() == r.Namespace) ||
+				controllerutil.ContainsFinalizer(obj, rbac.Finalizer)
+		})
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, reconciler.sweep, r.SyncPeriod)
+			return nil
+		})); err != nil {
+			return err
+		}
+		if err := ctrl.NewControllerManagedBy(mgr).
+			Named("federated"+strings.ToLower(reconciler.kind)).
+			For(reconciler.newObject(), builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  federated.Create,
+				DeleteFunc:  federated.Delete,
+				GenericFunc: federated.Generic,
+				// reconcile the objects no longer federated to remove them from the members
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return federated.Update(event.UpdateEvent{ObjectNew: e.ObjectOld}) || federated.Update(e)
+				},
+			})).
+			Watches(reconciler.queue, &handler.EnqueueRequestForObject{}).
+			Complete(reconciler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakePool struct {
+	pool.Interface
+	clusters map[string]cluster.Interface
+}
+
+func (p *fakePool) Clusters() map[string]cluster.Interface {
+	return p.clusters
+}
+
+func (p *fakePool) ForEach(ctx context.Context, clusters map[string]cluster.Interface,
+	fn func(ctx context.Context, clu cluster.Interface) error) map[string]error {
+	errs := make(map[string]error)
+	for name, clu := range clusters {
+		if err := fn(ctx, clu); err != nil {
+			errs[name] = err
+		}
+	}
+	return errs
+}
+
+// fakeMemberCache reads the objects of the member cluster from its client.
+type fakeMemberCache struct {
+	cache.Cache
+	reader client.Reader
+}
+
+func (c *fakeMemberCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.reader.Get(ctx, key, obj)
+}
+
+func (c *fakeMemberCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+type fakeMember struct {
+	cluster.Interface
+	name   string
+	status cluster.Code
+	client client.Client
+}
+
+func (c *fakeMember) Name() string { return c.name }
+
+func (c *fakeMember) Status() cluster.Code { return c.status }
+
+func (c *fakeMember) Client() client.Client { return c.client }
+
+func (c *fakeMember) Cache() cache.Cache { return &fakeMemberCache{reader: c.client} }
+
+func newRBACScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = v1beta1.AddToScheme(scheme)
+	_ = rbacv1.AddToScheme(scheme)
+	return scheme
+}
+
+func TestRBACReconciler_Finalizer(t *testing.T) {
+	scheme := newRBACScheme()
+	now := metav1.Now()
+	hub := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name:              "admins",
+		Labels:            map[string]string{rbac.FederatedLabel: "true"},
+		Finalizers:        []string{rbac.Finalizer},
+		DeletionTimestamp: &now,
+	}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hub).Build()
+	p := &fakePool{clusters: map[string]cluster.Interface{
+		"stopped": &fakeMember{name: "stopped", status: cluster.Stopped},
+	}}
+	r := &rbacReconciler{
+		FederatedRBACController: &FederatedRBACController{Client: c, Pool: p, Scheme: scheme, SyncPeriod: time.Minute},
+		kind:                    "ClusterRoleBinding",
+		newObject:               func() client.Object { return &rbacv1.ClusterRoleBinding{} },
+		newList:                 func() client.ObjectList { return &rbacv1.ClusterRoleBindingList{} },
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: hub.Name}}
+
+	// the binding may still be granted by the member not started
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != r.SyncPeriod {
+		t.Errorf("Reconcile() = %v, want requeue after %v", result, r.SyncPeriod)
+	}
+	got := &rbacv1.ClusterRoleBinding{}
+	if err = c.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatal(err)
+	}
+	if !controllerutil.ContainsFinalizer(got, rbac.Finalizer) {
+		t.Fatalf("finalizer is removed before the binding is removed from the stopped member")
+	}
+
+	p.clusters = nil
+	if _, err = r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	got = &rbacv1.ClusterRoleBinding{}
+	if err = c.Get(context.Background(), req.NamespacedName, got); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	}
+	if controllerutil.ContainsFinalizer(got, rbac.Finalizer) {
+		t.Errorf("finalizer is kept after the binding is removed from all the members")
+	}
+}
+
+func TestRBACReconciler_RemoveOrphans(t *testing.T) {
+	scheme := newRBACScheme()
+	federated := map[string]string{rbac.FederatedLabel: "true"}
+	hub := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "federated", Labels: federated}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "not-federated"}},
+	).Build()
+	propagated := func(name, source string) client.Object {
+		return &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{v1beta1.ManagedByLabel: rbac.ManagedBy},
+			Annotations: map[string]string{rbac.SourceAnnotation: source},
+		}}
+	}
+	member := &fakeMember{name: "member", status: cluster.Ready, client: fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			propagated("federated", "federated"),
+			propagated("not-federated", "not-federated"),
+			propagated("deleted", "deleted"),
+			&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "local"}},
+		).Build()}
+	r := &rbacReconciler{
+		FederatedRBACController: &FederatedRBACController{Client: hub, Scheme: scheme},
+		kind:                    "ClusterRoleBinding",
+		newObject:               func() client.Object { return &rbacv1.ClusterRoleBinding{} },
+		newList:                 func() client.ObjectList { return &rbacv1.ClusterRoleBindingList{} },
+	}
+	if err := r.removeOrphans(context.Background(), member); err != nil {
+		t.Fatalf("removeOrphans() error = %v", err)
+	}
+	list := &rbacv1.ClusterRoleBindingList{}
+	if err := member.client.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range list.Items {
+		got = append(got, item.Name)
+	}
+	sort.Strings(got)
+	if want := []string{"federated", "local"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining = %v, want %v", got, want)
+	}
+}
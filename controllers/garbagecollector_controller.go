@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	"github.com/sumengzs/multi-cluster/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+// GarbageCollector removes the propagated resources from the member clusters they are applied
+// to but explicitly de-scheduled from or evicted from, or from all of them when the ResourceBinding
+// is deleted, by the deletion policy of the binding.
+type GarbageCollector struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// RetryPeriod is the period to remove the resources again from the member
+	// clusters which were unreachable or failed to remove them.
+	RetryPeriod time.Duration
+}
+
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=propagationpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings/finalizers,verbs=update
+
+// Reconcile removes the resource of the binding from the applied clusters it is no longer
+// scheduled to, and releases the binding being deleted after the resource is removed from
+// all the applied clusters. The resource is only removed from a member cluster no longer
+// scheduled when the cluster is de-scheduled by the placement of the policy or evicted by
+// a NoExecute taint, never because of a NoSchedule taint, and only when the cluster is
+// reachable. The clusters deleted from the hub are forgotten, because the resources left
+// in them can never be reached again.
+func (r *GarbageCollector) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	binding := &v1beta1.ResourceBinding{}
+	if err := r.Get(ctx, req.NamespacedName, binding); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	deleting := !binding.DeletionTimestamp.IsZero()
+	var placement *v1beta1.Placement
+	if !deleting {
+		policy := &v1beta1.PropagationPolicy{}
+		key := types.NamespacedName{Namespace: binding.Namespace, Name: binding.Labels[v1beta1.PropagationPolicyLabel]}
+		if err := r.Get(ctx, key, policy); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		} else if err == nil {
+			placement = &policy.Spec.Placement
+		}
+	}
+	var remaining []string
+	for _, name := range binding.Status.AppliedClusters {
+		if !deleting {
+			// the binding is deleted with its policy, wait for it to remove the resource from all the clusters
+			if _, scheduled := boundCluster(binding, name); scheduled || placement == nil {
+				remaining = append(remaining, name)
+				continue
+			}
+		}
+		removed, err := r.remove(ctx, name, binding, placement)
+		if err != nil {
+			klog.Errorf("error removing %s %s/%s from cluster %s: %v", binding.Spec.Resource.Kind,
+				binding.Spec.Resource.Namespace, binding.Spec.Resource.Name, name, err)
+		}
+		if !removed {
+			remaining = append(remaining, name)
+		}
+	}
+
+	if len(remaining) != len(binding.Status.AppliedClusters) {
+		binding.Status.AppliedClusters = remaining
+		if err := r.Status().Update(ctx, binding); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if len(remaining) != 0 {
+		return ctrl.Result{RequeueAfter: r.RetryPeriod}, nil
+	}
+	if deleting && controllerutil.ContainsFinalizer(binding, v1beta1.ResourceBindingFinalizer) {
+		controllerutil.RemoveFinalizer(binding, v1beta1.ResourceBindingFinalizer)
+		return ctrl.Result{}, r.Update(ctx, binding)
+	}
+	return ctrl.Result{}, nil
+}
+
+// remove removes the resource of the binding from the member cluster, and returns false if the
+// cluster is not reachable, or is not de-scheduled by the placement if it is not nil.
+func (r *GarbageCollector) remove(ctx context.Context, name string, binding *v1beta1.ResourceBinding,
+	placement *v1beta1.Placement) (bool, error) {
+	obj := &v1beta1.Cluster{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.Infof("cluster %s is deleted, %s %s/%s left in it is forgotten", name, binding.Spec.Resource.Kind,
+				binding.Spec.Resource.Namespace, binding.Spec.Resource.Name)
+			return true, nil
+		}
+		return false, err
+	}
+	if placement != nil {
+		if ok, err := descheduled(obj, placement, time.Now()); err != nil || !ok {
+			return false, err
+		}
+	}
+	clu := r.Pool.Cluster(name)
+	if clu == nil {
+		return false, nil
+	}
+	for _, taint := range obj.Spec.Taints {
+		if taint.Key == v1beta1.TaintClusterUnreachable {
+			return false, nil
+		}
+	}
+	if err := propagation.Remove(ctx, clu, binding); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// descheduled returns whether the member cluster no longer bound is de-scheduled by the placement.
+// The cluster is de-scheduled when it is disabled, no longer matches the cluster affinity, is evicted
+// by a NoExecute taint, or is not chosen by the scheduler while still selectable. The cluster with
+// a NoSchedule taint not tolerated is never de-scheduled, because the taint only blocks new placements.
+func descheduled(clu *v1beta1.Cluster, placement *v1beta1.Placement, now time.Time) (bool, error) {
+	if clu.Spec.Disabled {
+		return true, nil
+	}
+	matched, err := propagation.MatchCluster(&placement.ClusterAffinity, clu)
+	if err != nil {
+		return false, err
+	}
+	if !matched {
+		return true, nil
+	}
+	if _, evicted := utils.FindEvictingTaint(clu.Spec.Taints, placement.ClusterTolerations, now); evicted {
+		return true, nil
+	}
+	_, untolerated := utils.FindUntoleratedTaint(clu.Spec.Taints, placement.ClusterTolerations, corev1.TaintEffectNoSchedule)
+	return !untolerated, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GarbageCollector) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("garbagecollector").
+		For(&v1beta1.ResourceBinding{}).
+		Complete(r)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestDescheduled(t *testing.T) {
+	now := time.Now()
+	added := metav1.NewTime(now.Add(-time.Minute))
+	notReady := corev1.Taint{Key: v1beta1.TaintClusterNotReady, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &added}
+	unreachable := corev1.Taint{Key: v1beta1.TaintClusterUnreachable, Effect: corev1.TaintEffectNoExecute, TimeAdded: &added}
+	seconds := int64(300)
+	tests := []struct {
+		name      string
+		cluster   v1beta1.Cluster
+		placement v1beta1.Placement
+		want      bool
+	}{
+		{
+			name:    "not chosen by the scheduler",
+			cluster: v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "member"}},
+			want:    true,
+		},
+		{
+			name: "disabled",
+			cluster: v1beta1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "member"},
+				Spec:       v1beta1.ClusterSpec{Disabled: true},
+			},
+			want: true,
+		},
+		{
+			name:    "no longer matched",
+			cluster: v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "member"}},
+			placement: v1beta1.Placement{
+				ClusterAffinity: v1beta1.ClusterAffinity{ClusterNames: []string{"other"}},
+			},
+			want: true,
+		},
+		{
+			name: "no schedule health taint",
+			cluster: v1beta1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "member"},
+				Spec:       v1beta1.ClusterSpec{Taints: []corev1.Taint{notReady}},
+			},
+			want: false,
+		},
+		{
+			name: "no execute health taint",
+			cluster: v1beta1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "member"},
+				Spec:       v1beta1.ClusterSpec{Taints: []corev1.Taint{unreachable}},
+			},
+			want: true,
+		},
+		{
+			name: "no execute health taint tolerated for a while",
+			cluster: v1beta1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "member"},
+				Spec:       v1beta1.ClusterSpec{Taints: []corev1.Taint{notReady, unreachable}},
+			},
+			placement: v1beta1.Placement{
+				ClusterTolerations: []corev1.Toleration{{
+					Key:               v1beta1.TaintClusterUnreachable,
+					Operator:          corev1.TolerationOpExists,
+					Effect:            corev1.TaintEffectNoExecute,
+					TolerationSeconds: &seconds,
+				}},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := descheduled(&tt.cluster, &tt.placement, now)
+			if err != nil {
+				t.Fatalf("descheduled() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("descheduled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGarbageCollector_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1beta1.AddToScheme(scheme)
+	policy := &v1beta1.PropagationPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "policy"}}
+	binding := &v1beta1.ResourceBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "nginx-deployment",
+			Labels:    map[string]string{v1beta1.PropagationPolicyLabel: policy.Name},
+		},
+		Spec: v1beta1.ResourceBindingSpec{
+			Clusters: []v1beta1.TargetCluster{{Name: "bound"}},
+		},
+		Status: v1beta1.ResourceBindingStatus{AppliedClusters: []string{"bound", "deleted", "not-ready"}},
+	}
+	notReady := &v1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "not-ready"},
+		Spec: v1beta1.ClusterSpec{Taints: []corev1.Taint{
+			{Key: v1beta1.TaintClusterNotReady, Effect: corev1.TaintEffectNoSchedule},
+		}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy, binding, notReady).Build()
+	r := &GarbageCollector{Client: c, Scheme: scheme, RetryPeriod: time.Minute}
+
+	key := types.NamespacedName{Namespace: binding.Namespace, Name: binding.Name}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != r.RetryPeriod {
+		t.Errorf("Reconcile() = %v, want requeue after %v", result, r.RetryPeriod)
+	}
+	got := &v1beta1.ResourceBinding{}
+	if err = c.Get(context.Background(), key, got); err != nil {
+		t.Fatal(err)
+	}
+	// the deleted cluster is forgotten, the resource is kept in the cluster tainted NoSchedule
+	if want := []string{"bound", "not-ready"}; !reflect.DeepEqual(got.Status.AppliedClusters, want) {
+		t.Errorf("AppliedClusters = %v, want %v", got.Status.AppliedClusters, want)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	"github.com/sumengzs/multi-cluster/pkg/scheduler"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sort"
+	"time"
+)
+
+// PropagationPolicyController reconciles a PropagationPolicy object
+type PropagationPolicyController struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// SyncPeriod is the period to propagate the resources again,
+	// so the changes of the resources in the hub are applied to the member clusters.
+	SyncPeriod time.Duration
+}
+
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=propagationpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=propagationpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=overridepolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings/finalizers,verbs=update
+//+kubebuilder:rbac:groups=*,resources=*,verbs=get;list;watch
+
+// Reconcile schedules the resources selected by the policy to the selected member
+// clusters and records the results in ResourceBindings, then server-side applies the
+// resources to the clusters they are bound to with the scheduled replicas and the
+// overrides of the cluster, and reports the apply status per cluster.
+func (r *PropagationPolicyController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	policy := &v1beta1.PropagationPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	templates, err := propagation.Templates(ctx, r.Client, r.RESTMapper(), policy.Namespace, policy.Spec.ResourceSelectors)
+	if err != nil {
+		klog.Errorf("error getting resources of propagation policy %s: %v", req, err)
+		return ctrl.Result{}, err
+	}
+	list := &v1beta1.ClusterList{}
+	if err = r.List(ctx, list); err != nil {
+		return ctrl.Result{}, err
+	}
+	current := &v1beta1.ResourceBindingList{}
+	if err = r.List(ctx, current, client.InNamespace(policy.Namespace),
+		client.MatchingLabels{v1beta1.PropagationPolicyLabel: policy.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	selected, err := propagation.SelectClusters(&policy.Spec.Placement, list.Items, boundClusters(current.Items...))
+	if err != nil {
+		klog.Errorf("error selecting clusters of propagation policy %s: %v", req, err)
+		return ctrl.Result{}, nil
+	}
+	bindings := make([]*v1beta1.ResourceBinding, 0, len(templates))
+	for _, template := range templates {
+		binding, err := r.bind(ctx, policy, template, selected)
+		if err != nil {
+			klog.Errorf("error binding %s %s/%s of propagation policy %s: %v", template.GetKind(),
+				template.GetNamespace(), template.GetName(), req, err)
+			return ctrl.Result{}, err
+		}
+		bindings = append(bindings, binding)
+	}
+	if err = r.removeStaleBindings(ctx, policy, bindings); err != nil {
+		return ctrl.Result{}, err
+	}
+	overrides := &v1beta1.OverridePolicyList{}
+	if err = r.List(ctx, overrides, client.InNamespace(policy.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := v1beta1.PropagationPolicyStatus{ObservedGeneration: policy.Generation}
+	for i := range selected {
+		status.Clusters = append(status.Clusters, r.propagate(ctx, &selected[i], templates, bindings, overrides.Items))
+	}
+	if !equality.Semantic.DeepEqual(policy.Status, status) {
+		policy.Status = status
+		if err = r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: r.SyncPeriod}, nil
+}
+
+// bind schedules the template to the clusters and records the result in the ResourceBinding of
+// the template. The clusters tainted NoSchedule are only kept if they are already bound to the
+// template, and the clusters of the binding are kept if the scheduling fails. The scheduled
+// clusters are recorded as applied before the template is applied to them, so the resource
+// is always cleaned up from them by the garbage collector.
+func (r *PropagationPolicyController) bind(ctx context.Context, policy *v1beta1.PropagationPolicy,
+	template *unstructured.Unstructured, clusters []v1beta1.Cluster) (*v1beta1.ResourceBinding, error) {
+	binding := &v1beta1.ResourceBinding{ObjectMeta: metav1.ObjectMeta{
+		Namespace: policy.Namespace,
+		Name:      propagation.BindingName(template.GetName(), template.GetKind()),
+	}}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(binding), binding); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	clusters, err := propagation.SelectClusters(&policy.Spec.Placement, clusters, boundClusters(*binding))
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, requests, _, err := scheduler.Replicas(template)
+	var targets []v1beta1.TargetCluster
+	if err == nil {
+		targets, err = scheduler.Schedule(&policy.Spec.Placement, replicas, requests, clusters, binding.Spec.Clusters)
+	}
+	condition := metav1.Condition{
+		Type:    v1beta1.ResourceBindingConditionScheduled,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Scheduled",
+		Message: "resource is scheduled to the clusters",
+	}
+	if err != nil {
+		klog.Errorf("error scheduling %s %s/%s: %v", template.GetKind(), template.GetNamespace(), template.GetName(), err)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SchedulingFailed"
+		condition.Message = err.Error()
+	}
+
+	if _, err = controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		if binding.Labels == nil {
+			binding.Labels = make(map[string]string)
+		}
+		binding.Labels[v1beta1.PropagationPolicyLabel] = policy.Name
+		binding.Spec.Resource = v1beta1.ObjectReference{
+			APIVersion: template.GetAPIVersion(),
+			Kind:       template.GetKind(),
+			Namespace:  template.GetNamespace(),
+			Name:       template.GetName(),
+		}
+		binding.Spec.Replicas = replicas
+		if condition.Status == metav1.ConditionTrue {
+			binding.Spec.Clusters = targets
+		}
+		binding.Spec.DeletionPolicy = policy.Spec.DeletionPolicy
+		if len(binding.Spec.DeletionPolicy) == 0 {
+			binding.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+		}
+		controllerutil.AddFinalizer(binding, v1beta1.ResourceBindingFinalizer)
+		return controllerutil.SetControllerReference(policy, binding, r.Scheme)
+	}); err != nil {
+		return nil, err
+	}
+
+	status := binding.Status.DeepCopy()
+	condition.ObservedGeneration = binding.Generation
+	meta.SetStatusCondition(&status.Conditions, condition)
+	if binding.DeletionTimestamp.IsZero() {
+		for _, target := range binding.Spec.Clusters {
+			if !containsString(status.AppliedClusters, target.Name) {
+				status.AppliedClusters = append(status.AppliedClusters, target.Name)
+			}
+		}
+		sort.Strings(status.AppliedClusters)
+	}
+	if !equality.Semantic.DeepEqual(&binding.Status, status) {
+		binding.Status = *status
+		if err = r.Status().Update(ctx, binding); err != nil {
+			return nil, err
+		}
+	}
+	return binding, nil
+}
+
+// removeStaleBindings deletes the ResourceBindings of the policy whose resources are no longer selected.
+func (r *PropagationPolicyController) removeStaleBindings(ctx context.Context, policy *v1beta1.PropagationPolicy,
+	bindings []*v1beta1.ResourceBinding) error {
+	list := &v1beta1.ResourceBindingList{}
+	if err := r.List(ctx, list, client.InNamespace(policy.Namespace),
+		client.MatchingLabels{v1beta1.PropagationPolicyLabel: policy.Name}); err != nil {
+		return err
+	}
+	current := make(map[string]bool, len(bindings))
+	for _, binding := range bindings {
+		current[binding.Name] = true
+	}
+	for i := range list.Items {
+		if current[list.Items[i].Name] {
+			continue
+		}
+		if err := r.Delete(ctx, &list.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propagate applies the templates bound to the member cluster, the replicas of the workloads
+// are set to the replicas scheduled to the cluster. The templates of the bindings being deleted
+// are skipped, they are applied again after the bindings are recreated.
+func (r *PropagationPolicyController) propagate(ctx context.Context, member *v1beta1.Cluster, templates []*unstructured.Unstructured,
+	bindings []*v1beta1.ResourceBinding, overrides []v1beta1.OverridePolicy) v1beta1.ClusterApplyStatus {
+	name := member.Name
+	clu := r.Pool.Cluster(name)
+	if clu == nil || clu.Status() < cluster.Started {
+		return v1beta1.ClusterApplyStatus{Name: name, Message: "cluster is not started"}
+	}
+	var errs []error
+	for i, template := range templates {
+		target, bound := boundCluster(bindings[i], name)
+		if !bound || !bindings[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		obj := propagation.Prepare(template)
+		propagation.Stamp(obj, types.NamespacedName{Namespace: bindings[i].Namespace, Name: bindings[i].Name})
+		if _, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+			_ = unstructured.SetNestedField(obj.Object, int64(target.Replicas), "spec", "replicas")
+		}
+		if err := propagation.Override(obj, member, overrides); err != nil {
+			klog.Errorf("error overriding %s %s/%s for cluster %s: %v", template.GetKind(),
+				template.GetNamespace(), template.GetName(), name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if err := propagation.Apply(ctx, clu, obj); err != nil {
+			klog.Errorf("error applying %s %s/%s to cluster %s: %v", template.GetKind(),
+				template.GetNamespace(), template.GetName(), name, err)
+			errs = append(errs, err)
+		}
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return v1beta1.ClusterApplyStatus{Name: name, Message: err.Error()}
+	}
+	return v1beta1.ClusterApplyStatus{Name: name, Applied: true}
+}
+
+// boundClusters returns the names of the clusters the bindings are bound to.
+func boundClusters(bindings ...v1beta1.ResourceBinding) []string {
+	var names []string
+	for i := range bindings {
+		for _, target := range bindings[i].Spec.Clusters {
+			names = append(names, target.Name)
+		}
+	}
+	return names
+}
+
+func boundCluster(binding *v1beta1.ResourceBinding, name string) (v1beta1.TargetCluster, bool) {
+	for _, target := range binding.Spec.Clusters {
+		if target.Name == name {
+			return target, true
+		}
+	}
+	return v1beta1.TargetCluster{}, false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PropagationPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.PropagationPolicy{}).
+		Owns(&v1beta1.ResourceBinding{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &v1beta1.Cluster{}}, handler.EnqueueRequestsFromMapFunc(r.policies)).
+		Watches(&source.Kind{Type: &v1beta1.OverridePolicy{}}, handler.EnqueueRequestsFromMapFunc(r.policies)).
+		Complete(r)
+}
+
+// policies enqueues the PropagationPolicies affected by the object. A change of a cluster
+// may affect the placement of any policy, and a change of an override policy may affect
+// the resources of the policies in the same namespace.
+func (r *PropagationPolicyController) policies(obj client.Object) []reconcile.Request {
+	list := &v1beta1.PropagationPolicyList{}
+	if err := r.List(context.TODO(), list, client.InNamespace(obj.GetNamespace())); err != nil {
+		klog.Errorf("error listing propagation policies: %v", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, policy := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: policy.Namespace, Name: policy.Name},
+		})
+	}
+	return requests
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
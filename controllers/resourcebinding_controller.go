@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	"github.com/sumengzs/multi-cluster/pkg/status"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"time"
+)
+
+// ResourceBindingController aggregates the status of the propagated resources
+// in the member clusters into their ResourceBindings.
+type ResourceBindingController struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// SyncPeriod is the period to collect the status again while the resource is not ready,
+	// otherwise the status is collected when the resource changes in the member clusters.
+	SyncPeriod time.Duration
+
+	watcher *pool.Watcher
+	queue   *pool.Queue
+}
+
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=resourcebindings/status,verbs=get;update;patch
+
+// Reconcile collects the status of the resource in every cluster it is scheduled to
+// through the cache of the member cluster, and reports the status and readiness of
+// the resource per cluster in the status of the binding.
+func (r *ResourceBindingController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	binding := &v1beta1.ResourceBinding{}
+	if err := r.Get(ctx, req.NamespacedName, binding); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	bindingStatus := binding.Status.DeepCopy()
+	bindingStatus.AggregatedStatus = nil
+	condition := metav1.Condition{
+		Type:    v1beta1.ResourceBindingConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Ready",
+		Message: "resource is ready in all the clusters",
+	}
+	if len(binding.Spec.Clusters) == 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NotScheduled"
+		condition.Message = "resource is not scheduled to any cluster"
+	}
+	for _, target := range binding.Spec.Clusters {
+		item := r.collect(ctx, target.Name, &binding.Spec.Resource)
+		if !item.Ready && condition.Status == metav1.ConditionTrue {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "NotReady"
+			condition.Message = fmt.Sprintf("cluster %s: %s", item.ClusterName, item.Message)
+		}
+		bindingStatus.AggregatedStatus = append(bindingStatus.AggregatedStatus, item)
+	}
+	condition.ObservedGeneration = binding.Generation
+	meta.SetStatusCondition(&bindingStatus.Conditions, condition)
+
+	if !equality.Semantic.DeepEqual(&binding.Status, bindingStatus) {
+		binding.Status = *bindingStatus
+		if err := r.Status().Update(ctx, binding); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if condition.Status != metav1.ConditionTrue {
+		return ctrl.Result{RequeueAfter: r.SyncPeriod}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// collect returns the status of the resource in the member cluster.
+func (r *ResourceBindingController) collect(ctx context.Context, name string, ref *v1beta1.ObjectReference) v1beta1.AggregatedStatusItem {
+	item := v1beta1.AggregatedStatusItem{ClusterName: name}
+	clu := r.Pool.Cluster(name)
+	if clu == nil || clu.Status() < cluster.Started {
+		item.Message = "cluster is not started"
+		return item
+	}
+
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := r.watcher.Watch(ctx, clu, gvk, obj, func(obj metav1.Object) { r.enqueue(gvk.Kind, obj) }); err != nil {
+		klog.Errorf("error watching %s in cluster %s: %v", gvk, name, err)
+		item.Message = fmt.Sprintf("failed to watch %s: %s", gvk.Kind, err)
+		return item
+	}
+	if err := clu.Cache().Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			item.Message = "resource is not found in the cluster"
+		} else {
+			item.Message = fmt.Sprintf("failed to get resource: %s", err)
+		}
+		return item
+	}
+
+	reflection, err := status.Interpret(obj)
+	if err != nil {
+		item.Message = fmt.Sprintf("failed to interpret status: %s", err)
+		return item
+	}
+	if len(reflection.Status) != 0 {
+		raw, err := json.Marshal(reflection.Status)
+		if err != nil {
+			item.Message = fmt.Sprintf("failed to marshal status: %s", err)
+			return item
+		}
+		item.Status = &apiextensionsv1.JSON{Raw: raw}
+	}
+	item.Ready, item.Message = reflection.Ready, reflection.Message
+	return item
+}
+
+// enqueue enqueues the binding of the resource changed in a member cluster.
+func (r *ResourceBindingController) enqueue(kind string, obj metav1.Object) {
+	r.queue.Add(types.NamespacedName{Namespace: obj.GetNamespace(), Name: propagation.BindingName(obj.GetName(), kind)})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResourceBindingController) SetupWithManager(mgr ctrl.Manager) error {
+	r.watcher = pool.NewWatcher(r.Pool)
+	r.queue = &pool.Queue{}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ResourceBinding{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(r.queue, &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mcsv1alpha1 "github.com/sumengzs/multi-cluster/api/mcs/v1alpha1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/mcs"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sort"
+	"time"
+)
+
+// ServiceExportController aggregates the Services exported by the member clusters
+// through ServiceExports into the hub, as a ServiceImport and the EndpointSlices of
+// the exporting clusters, which are imported into the consuming clusters by the
+// ServiceImportController.
+type ServiceExportController struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// SyncPeriod is the period to watch the exports in the newly started member clusters.
+	SyncPeriod time.Duration
+
+	watcher *pool.Watcher
+	queue   *pool.Queue
+}
+
+// exported is a Service exported by a member cluster with its EndpointSlices.
+type exported struct {
+	export *mcsv1alpha1.ServiceExport
+	client client.Client
+	mcs.Export
+	slices []discoveryv1.EndpointSlice
+}
+
+//+kubebuilder:rbac:groups=multicluster.x-k8s.io,resources=serviceimports,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=multicluster.x-k8s.io,resources=serviceimports/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=multicluster.x-k8s.io,resources=serviceimports/finalizers,verbs=update
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile collects the service exported by the member clusters, and aggregates it into the
+// ServiceImport of the same namespace and name in the hub, together with the EndpointSlices of
+// the service in every exporting cluster. The EndpointSlices of the clusters not started are
+// kept, so the endpoints do not flap while a member is restarting. The ServiceImport is deleted
+// after no cluster exports the service.
+func (r *ServiceExportController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	var exports []exported
+	unknown := make(map[string]bool)
+	for name, clu := range r.Pool.Clusters() {
+		if clu.Status() < cluster.Started {
+			unknown[name] = true
+			continue
+		}
+		item, err := r.collect(ctx, clu, req.NamespacedName)
+		if err != nil {
+			klog.Errorf("error collecting exported service %s from cluster %s: %v", req, name, err)
+			unknown[name] = true
+			continue
+		}
+		if item != nil {
+			exports = append(exports, *item)
+		}
+	}
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Cluster < exports[j].Cluster })
+
+	imp := &mcsv1alpha1.ServiceImport{ObjectMeta: metav1.ObjectMeta{Namespace: req.Namespace, Name: req.Name}}
+	if len(exports) == 0 {
+		hubSlices, err := r.hubSlices(ctx, req.NamespacedName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		kept := false
+		for i := range hubSlices {
+			if unknown[hubSlices[i].Labels[mcsv1alpha1.LabelSourceCluster]] {
+				kept = true
+				continue
+			}
+			if err := r.Delete(ctx, &hubSlices[i]); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if kept {
+			// the service may still be exported by the clusters not started
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, imp))
+	}
+
+	specExports := make([]mcs.Export, 0, len(exports))
+	for _, item := range exports {
+		specExports = append(specExports, item.Export)
+	}
+	spec, conflicts := mcs.ImportSpec(specExports)
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, imp, func() error {
+		imp.Spec.Ports = spec.Ports
+		imp.Spec.Type = spec.Type
+		imp.Spec.SessionAffinity = spec.SessionAffinity
+		imp.Spec.SessionAffinityConfig = spec.SessionAffinityConfig
+		return nil
+	}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to apply service import: %s", err)
+	}
+	importStatus := mcsv1alpha1.ServiceImportStatus{}
+	for _, item := range exports {
+		importStatus.Clusters = append(importStatus.Clusters, mcsv1alpha1.ClusterStatus{Cluster: item.Cluster})
+	}
+	if !equality.Semantic.DeepEqual(imp.Status, importStatus) {
+		imp.Status = importStatus
+		if err := r.Status().Update(ctx, imp); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.syncSlices(ctx, imp, exports, unknown); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.reportConflicts(ctx, exports, conflicts)
+	return ctrl.Result{}, nil
+}
+
+// collect returns the service exported by the member cluster, or nil if it is not exported.
+func (r *ServiceExportController) collect(ctx context.Context, clu cluster.Interface, key types.NamespacedName) (*exported, error) {
+	if err := r.watch(ctx, clu); err != nil {
+		return nil, err
+	}
+	export := &mcsv1alpha1.ServiceExport{}
+	if err := clu.Cache().Get(ctx, key, export); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !export.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	export = export.DeepCopy()
+
+	svc := &corev1.Service{}
+	if err := clu.Cache().Get(ctx, key, svc); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if mcs.SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionFalse,
+			"ServiceNotFound", "the exported service is not found") {
+			return nil, clu.Client().Status().Update(ctx, export)
+		}
+		return nil, nil
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		if mcs.SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionFalse,
+			"ServiceTypeNotSupported", "the services of type ExternalName can not be exported") {
+			return nil, clu.Client().Status().Update(ctx, export)
+		}
+		return nil, nil
+	}
+
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := clu.Cache().List(ctx, slices, client.InNamespace(key.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: key.Name}); err != nil {
+		return nil, err
+	}
+	return &exported{
+		export: export,
+		client: clu.Client(),
+		Export: mcs.Export{Cluster: clu.Name(), Created: export.CreationTimestamp, Service: svc},
+		slices: slices.Items,
+	}, nil
+}
+
+// syncSlices makes the EndpointSlices of the service import in the hub consistent with the
+// EndpointSlices of the exported services, and keeps the ones of the unknown clusters.
+func (r *ServiceExportController) syncSlices(ctx context.Context, imp *mcsv1alpha1.ServiceImport,
+	exports []exported, unknown map[string]bool) error {
+	desired := make(map[string]*discoveryv1.EndpointSlice)
+	for _, item := range exports {
+		for i := range item.slices {
+			slice := mcs.HubEndpointSlice(item.Cluster, imp.Name, &item.slices[i])
+			desired[slice.Name] = slice
+		}
+	}
+
+	existing, err := r.hubSlices(ctx, types.NamespacedName{Namespace: imp.Namespace, Name: imp.Name})
+	if err != nil {
+		return err
+	}
+	for i := range existing {
+		if _, ok := desired[existing[i].Name]; ok || unknown[existing[i].Labels[mcsv1alpha1.LabelSourceCluster]] {
+			continue
+		}
+		if err := r.Delete(ctx, &existing[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete endpoint slice %s: %s", existing[i].Name, err)
+		}
+	}
+	for _, slice := range desired {
+		obj := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Namespace: slice.Namespace, Name: slice.Name}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+			obj.Labels = slice.Labels
+			obj.AddressType = slice.AddressType
+			obj.Endpoints = slice.Endpoints
+			obj.Ports = slice.Ports
+			return controllerutil.SetControllerReference(imp, obj, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to apply endpoint slice %s: %s", slice.Name, err)
+		}
+	}
+	return nil
+}
+
+// hubSlices returns the EndpointSlices of the service import in the hub.
+func (r *ServiceExportController) hubSlices(ctx context.Context, key types.NamespacedName) ([]discoveryv1.EndpointSlice, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := r.List(ctx, slices, client.InNamespace(key.Namespace), client.MatchingLabels{
+		mcsv1alpha1.LabelServiceName: key.Name,
+		discoveryv1.LabelManagedBy:   mcs.EndpointSliceManagedBy,
+	}); err != nil {
+		return nil, err
+	}
+	return slices.Items, nil
+}
+
+// reportConflicts reports the validity and the conflicts of the exports in their conditions.
+func (r *ServiceExportController) reportConflicts(ctx context.Context, exports []exported, conflicts []string) {
+	for _, item := range exports {
+		changed := mcs.SetExportCondition(item.export, mcsv1alpha1.ServiceExportValid, corev1.ConditionTrue,
+			"Exported", "the service is exported to the hub")
+		if containsString(conflicts, item.Cluster) {
+			changed = mcs.SetExportCondition(item.export, mcsv1alpha1.ServiceExportConflict, corev1.ConditionTrue,
+				"PropertiesConflict", "the properties of the service conflict with the oldest export") || changed
+		} else {
+			changed = mcs.SetExportCondition(item.export, mcsv1alpha1.ServiceExportConflict, corev1.ConditionFalse,
+				"NoConflict", "the properties of the service are consistent with the oldest export") || changed
+		}
+		if !changed {
+			continue
+		}
+		if err := item.client.Status().Update(ctx, item.export); err != nil {
+			klog.Errorf("error updating status of service export %s/%s in cluster %s: %v",
+				item.export.Namespace, item.export.Name, item.Cluster, err)
+		}
+	}
+}
+
+// watch watches the ServiceExports, Services and EndpointSlices in the cache of the
+// member cluster, so the exported service is reconciled when any of them changes.
+func (r *ServiceExportController) watch(ctx context.Context, clu cluster.Interface) error {
+	enqueue := func(obj metav1.Object) { r.enqueue(obj.GetNamespace(), obj.GetName()) }
+	if err := r.watcher.Watch(ctx, clu, mcsv1alpha1.GroupVersion.WithKind("ServiceExport"),
+		&mcsv1alpha1.ServiceExport{}, enqueue); err != nil {
+		return err
+	}
+	if err := r.watcher.Watch(ctx, clu, corev1.SchemeGroupVersion.WithKind("Service"),
+		&corev1.Service{}, enqueue); err != nil {
+		return err
+	}
+	return r.watcher.Watch(ctx, clu, discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"),
+		&discoveryv1.EndpointSlice{}, func(obj metav1.Object) {
+			if name, ok := obj.GetLabels()[discoveryv1.LabelServiceName]; ok {
+				r.enqueue(obj.GetNamespace(), name)
+			}
+		})
+}
+
+// watchMembers watches the started member clusters which are not watched yet.
+func (r *ServiceExportController) watchMembers(ctx context.Context) {
+	for name, clu := range r.Pool.Clusters() {
+		if clu.Status() < cluster.Started {
+			continue
+		}
+		if err := r.watch(ctx, clu); err != nil {
+			klog.Errorf("error watching service exports in cluster %s: %v", name, err)
+		}
+	}
+}
+
+// enqueue enqueues the service changed in a member cluster.
+func (r *ServiceExportController) enqueue(namespace, name string) {
+	r.queue.Add(types.NamespacedName{Namespace: namespace, Name: name})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceExportController) SetupWithManager(mgr ctrl.Manager) error {
+	r.watcher = pool.NewWatcher(r.Pool)
+	r.queue = &pool.Queue{}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		wait.UntilWithContext(ctx, r.watchMembers, r.SyncPeriod)
+		return nil
+	})); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("serviceexport").
+		For(&mcsv1alpha1.ServiceImport{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&discoveryv1.EndpointSlice{}).
+		Watches(r.queue, &handler.EnqueueRequestForObject{}).
+		Complete(r)
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	mcsv1alpha1 "github.com/sumengzs/multi-cluster/api/mcs/v1alpha1"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/mcs"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+// ServiceImportController imports the services aggregated in the hub into the consuming
+// clusters. Every member cluster having the namespace of a ServiceImport in the hub is a
+// consuming cluster, it gets a ServiceImport, a Service derived from the import which
+// allocates the cluster set IP, and the EndpointSlices of all the exporting clusters
+// derived for the Service.
+type ServiceImportController struct {
+	client.Client
+	Pool   pool.Interface
+	Scheme *runtime.Scheme
+	// SyncPeriod is the period to import the services again, which imports them
+	// into the newly started member clusters.
+	SyncPeriod time.Duration
+}
+
+//+kubebuilder:rbac:groups=multicluster.x-k8s.io,resources=serviceimports,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+
+// Reconcile imports the service import in the hub into every started member cluster,
+// or removes it from them when the import is deleted from the hub.
+func (r *ServiceImportController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	_ = log.FromContext(ctx)
+
+	imp := &mcsv1alpha1.ServiceImport{}
+	if err := r.Get(ctx, req.NamespacedName, imp); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		imp = nil
+	}
+	var slices []discoveryv1.EndpointSlice
+	if imp != nil {
+		list := &discoveryv1.EndpointSliceList{}
+		if err := r.List(ctx, list, client.InNamespace(req.Namespace), client.MatchingLabels{
+			mcsv1alpha1.LabelServiceName: req.Name,
+			discoveryv1.LabelManagedBy:   mcs.EndpointSliceManagedBy,
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+		slices = list.Items
+	}
+
+	for name, clu := range r.Pool.Clusters() {
+		if clu.Status() < cluster.Started {
+			continue
+		}
+		var err error
+		if imp == nil {
+			err = r.remove(ctx, clu.Client(), req.NamespacedName)
+		} else {
+			err = r.sync(ctx, clu.Client(), imp, slices)
+		}
+		if err != nil {
+			klog.Errorf("error importing service %s into cluster %s: %v", req, name, err)
+		}
+	}
+	if imp == nil {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: r.SyncPeriod}, nil
+}
+
+// sync imports the service into the member cluster if the cluster has its namespace.
+func (r *ServiceImportController) sync(ctx context.Context, c client.Client, imp *mcsv1alpha1.ServiceImport,
+	slices []discoveryv1.EndpointSlice) error {
+	if err := c.Get(ctx, types.NamespacedName{Name: imp.Namespace}, &corev1.Namespace{}); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: imp.Namespace, Name: mcs.DerivedServiceName(imp.Name)}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, svc, func() error {
+		if !managed(svc) && !svc.CreationTimestamp.IsZero() {
+			return fmt.Errorf("service %s is not derived from the service import", svc.Name)
+		}
+		setManaged(svc, imp.Name)
+		svc.Spec.Ports = mcs.DerivedServicePorts(&imp.Spec)
+		svc.Spec.Selector = nil
+		svc.Spec.SessionAffinity = imp.Spec.SessionAffinity
+		if len(svc.Spec.SessionAffinity) == 0 {
+			svc.Spec.SessionAffinity = corev1.ServiceAffinityNone
+		}
+		svc.Spec.SessionAffinityConfig = imp.Spec.SessionAffinityConfig
+		// the cluster ip is immutable, the type of an import is not expected to change
+		if svc.CreationTimestamp.IsZero() && imp.Spec.Type == mcsv1alpha1.Headless {
+			svc.Spec.ClusterIP = corev1.ClusterIPNone
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to apply derived service: %s", err)
+	}
+
+	memberImp := &mcsv1alpha1.ServiceImport{ObjectMeta: metav1.ObjectMeta{Namespace: imp.Namespace, Name: imp.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, c, memberImp, func() error {
+		if !managed(memberImp) && !memberImp.CreationTimestamp.IsZero() {
+			return fmt.Errorf("service import %s is not imported from the hub", memberImp.Name)
+		}
+		setManaged(memberImp, imp.Name)
+		memberImp.Spec = *imp.Spec.DeepCopy()
+		memberImp.Spec.IPs = nil
+		if imp.Spec.Type == mcsv1alpha1.ClusterSetIP && len(svc.Spec.ClusterIP) != 0 && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+			memberImp.Spec.IPs = []string{svc.Spec.ClusterIP}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to apply service import: %s", err)
+	}
+	if !equality.Semantic.DeepEqual(memberImp.Status, imp.Status) {
+		memberImp.Status = *imp.Status.DeepCopy()
+		if err := c.Status().Update(ctx, memberImp); err != nil {
+			return fmt.Errorf("failed to update service import status: %s", err)
+		}
+	}
+
+	return r.syncSlices(ctx, c, svc, slices)
+}
+
+// syncSlices makes the EndpointSlices of the derived service in the member cluster
+// consistent with the EndpointSlices of the service import in the hub.
+func (r *ServiceImportController) syncSlices(ctx context.Context, c client.Client, svc *corev1.Service,
+	slices []discoveryv1.EndpointSlice) error {
+	desired := make(map[string]*discoveryv1.EndpointSlice)
+	for i := range slices {
+		slice := mcs.DerivedEndpointSlice(&slices[i])
+		desired[slice.Name] = slice
+	}
+
+	existing := &discoveryv1.EndpointSliceList{}
+	if err := c.List(ctx, existing, client.InNamespace(svc.Namespace), client.MatchingLabels{
+		discoveryv1.LabelServiceName: svc.Name,
+		discoveryv1.LabelManagedBy:   mcs.EndpointSliceManagedBy,
+	}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		if _, ok := desired[existing.Items[i].Name]; ok {
+			continue
+		}
+		if err := c.Delete(ctx, &existing.Items[i]); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete endpoint slice %s: %s", existing.Items[i].Name, err)
+		}
+	}
+	for _, slice := range desired {
+		obj := &discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{Namespace: slice.Namespace, Name: slice.Name}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, c, obj, func() error {
+			obj.Labels = slice.Labels
+			obj.AddressType = slice.AddressType
+			obj.Endpoints = slice.Endpoints
+			obj.Ports = slice.Ports
+			return controllerutil.SetControllerReference(svc, obj, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to apply endpoint slice %s: %s", slice.Name, err)
+		}
+	}
+	return nil
+}
+
+// remove removes the service import and the derived service from the member cluster,
+// the derived EndpointSlices are deleted with the service they belong to.
+func (r *ServiceImportController) remove(ctx context.Context, c client.Client, key types.NamespacedName) error {
+	objs := []client.Object{
+		&mcsv1alpha1.ServiceImport{},
+		&corev1.Service{},
+	}
+	names := []string{key.Name, mcs.DerivedServiceName(key.Name)}
+	for i, obj := range objs {
+		if err := c.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: names[i]}, obj); err != nil {
+			if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+				continue
+			}
+			return err
+		}
+		if !managed(obj) {
+			continue
+		}
+		if err := c.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managed returns true if the object in a member cluster is imported from the hub.
+func managed(obj client.Object) bool {
+	return obj.GetLabels()[v1beta1.ManagedByLabel] == propagation.FieldManager
+}
+
+// setManaged labels the object in a member cluster imported from the hub.
+func setManaged(obj client.Object, service string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[v1beta1.ManagedByLabel] = propagation.FieldManager
+	labels[mcsv1alpha1.LabelServiceName] = service
+	obj.SetLabels(labels)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceImportController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("serviceimport").
+		For(&mcsv1alpha1.ServiceImport{}).
+		Owns(&discoveryv1.EndpointSlice{}).
+		Complete(r)
+}
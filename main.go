@@ -18,8 +18,14 @@ package main
 
 import (
 	"flag"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/identity"
+	"github.com/sumengzs/multi-cluster/pkg/mapper"
 	"github.com/sumengzs/multi-cluster/pkg/pool"
+	"github.com/sumengzs/multi-cluster/pkg/proxy"
+	"github.com/sumengzs/multi-cluster/pkg/search"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,11 +33,15 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	mcsv1alpha1 "github.com/sumengzs/multi-cluster/api/mcs/v1alpha1"
+	sumengzscnv1 "github.com/sumengzs/multi-cluster/api/v1"
 	sumengzscnv1beta1 "github.com/sumengzs/multi-cluster/api/v1beta1"
 	"github.com/sumengzs/multi-cluster/controllers"
 	//+kubebuilder:scaffold:imports
@@ -46,6 +56,8 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(sumengzscnv1beta1.AddToScheme(scheme))
+	utilruntime.Must(sumengzscnv1.AddToScheme(scheme))
+	utilruntime.Must(mcsv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -53,11 +65,111 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var nodePoolLabel string
+	var statusSyncPeriod time.Duration
+	var taintGracePeriod time.Duration
+	var evictionGracePeriod time.Duration
+	var propagationSyncPeriod time.Duration
+	var bindingStatusSyncPeriod time.Duration
+	var garbageCollectionRetryPeriod time.Duration
+	var serviceExportSyncPeriod time.Duration
+	var serviceImportSyncPeriod time.Duration
+	var searchAddr string
+	var searchResources string
+	var searchSyncPeriod time.Duration
+	var searchCertFile string
+	var searchKeyFile string
+	var proxyAddr string
+	var proxyCertFile string
+	var proxyKeyFile string
+	var proxyImpersonation bool
+	var proxyAllowClusterIdentity bool
+	var federatedRBACNamespace string
+	var federatedRBACSyncPeriod time.Duration
+	var memberCacheLazy bool
+	var memberCacheIdleTimeout time.Duration
+	var memberCacheStripManagedFields bool
+	var memberCacheMaxAnnotationSize int
+	var memberCacheMetadataOnly string
+	var memberQPS int
+	var memberBurst int
+	var poolConcurrency int
+	var memberBreakerFailureThreshold int
+	var memberBreakerOpenTimeout time.Duration
+	var memberDiscoveryCacheDir string
+	var memberDiscoveryCacheNamespace string
+	var memberDiscoveryRefreshPeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&nodePoolLabel, "node-pool-label", sumengzscnv1beta1.NodePoolLabel,
+		"The node label used to group the resource summary of member clusters into node pools.")
+	flag.DurationVar(&statusSyncPeriod, "cluster-status-sync-period", 30*time.Second,
+		"The period to collect the status of member clusters.")
+	flag.DurationVar(&taintGracePeriod, "cluster-taint-grace-period", 40*time.Second,
+		"How long a member cluster must stay unhealthy before it is tainted NoSchedule.")
+	flag.DurationVar(&evictionGracePeriod, "cluster-eviction-grace-period", 5*time.Minute,
+		"How long a member cluster must stay unhealthy before it is tainted NoExecute, evicting the workloads not tolerating it.")
+	flag.DurationVar(&propagationSyncPeriod, "propagation-sync-period", time.Minute,
+		"The period to propagate the resources to member clusters again.")
+	flag.DurationVar(&bindingStatusSyncPeriod, "binding-status-sync-period", 30*time.Second,
+		"The period to collect the status of the propagated resources again while they are not ready.")
+	flag.DurationVar(&garbageCollectionRetryPeriod, "garbage-collection-retry-period", 30*time.Second,
+		"The period to remove the propagated resources again from the unreachable member clusters.")
+	flag.DurationVar(&serviceExportSyncPeriod, "service-export-sync-period", 30*time.Second,
+		"The period to watch the service exports in the newly started member clusters.")
+	flag.DurationVar(&serviceImportSyncPeriod, "service-import-sync-period", time.Minute,
+		"The period to import the services into member clusters again.")
+	flag.StringVar(&searchAddr, "search-bind-address", "",
+		"The address the search endpoint binds to, the search is disabled if it is empty.")
+	flag.StringVar(&searchCertFile, "search-cert-file", "", "The serving certificate file of the search endpoint.")
+	flag.StringVar(&searchKeyFile, "search-key-file", "", "The serving key file of the search endpoint.")
+	flag.StringVar(&searchResources, "search-resources", "v1/Pod,v1/Service,apps/v1/Deployment",
+		"The comma separated apiVersion/kind of the resources indexed for the search.")
+	flag.DurationVar(&searchSyncPeriod, "search-sync-period", 30*time.Second,
+		"The period to index the resources in the newly started member clusters.")
+	flag.StringVar(&proxyAddr, "proxy-bind-address", "",
+		"The address the proxy to member clusters binds to, the proxy is disabled if it is empty.")
+	flag.StringVar(&proxyCertFile, "proxy-cert-file", "", "The serving certificate file of the proxy.")
+	flag.StringVar(&proxyKeyFile, "proxy-key-file", "", "The serving key file of the proxy.")
+	flag.BoolVar(&proxyImpersonation, "proxy-impersonation", true,
+		"Impersonate the callers of the proxy in member clusters as mapped by the IdentityMappings.")
+	flag.BoolVar(&proxyAllowClusterIdentity, "proxy-allow-cluster-identity", false,
+		"Allow the proxy to make the requests as the identities of the member clusters if the impersonation is disabled.")
+	flag.StringVar(&federatedRBACNamespace, "federated-rbac-namespace", "multi-cluster-system",
+		"The namespace in the hub the federated Roles and RoleBindings are propagated from.")
+	flag.DurationVar(&federatedRBACSyncPeriod, "federated-rbac-sync-period", time.Minute,
+		"The period to propagate the federated RBAC objects to member clusters again.")
+	flag.BoolVar(&memberCacheLazy, "member-cache-lazy", false,
+		"Start the informers of the member cluster caches on their first use, and stop them when idle.")
+	flag.DurationVar(&memberCacheIdleTimeout, "member-cache-idle-timeout", 10*time.Minute,
+		"The time after which the idle informers of the lazy member cluster caches are stopped.")
+	flag.BoolVar(&memberCacheStripManagedFields, "member-cache-strip-managed-fields", true,
+		"Drop the managed fields of the objects cached from member clusters.")
+	flag.IntVar(&memberCacheMaxAnnotationSize, "member-cache-max-annotation-size", 0,
+		"Drop the annotations larger than the size in bytes of the objects cached from member clusters, zero keeps them.")
+	flag.StringVar(&memberCacheMetadataOnly, "member-cache-metadata-only", "",
+		"The comma separated apiVersion/kind of the resources whose metadata only is cached from member clusters.")
+	flag.IntVar(&memberQPS, "member-qps", 20,
+		"The default QPS to each member cluster shared by all its clients, zero leaves the clients unlimited by the pool.")
+	flag.IntVar(&memberBurst, "member-burst", 40,
+		"The default burst to each member cluster shared by all its clients, it defaults to the QPS if zero.")
+	flag.IntVar(&poolConcurrency, "pool-concurrency", pool.DefaultConcurrency,
+		"The maximum calls to member clusters running at the same time of the pool-wide operations, zero is unlimited.")
+	flag.IntVar(&memberBreakerFailureThreshold, "member-breaker-failure-threshold",
+		cluster.DefaultBreakerOptions.FailureThreshold,
+		"The consecutive failed requests to a member cluster opening its circuit, zero disables the circuit breakers.")
+	flag.DurationVar(&memberBreakerOpenTimeout, "member-breaker-open-timeout", cluster.DefaultBreakerOptions.OpenTimeout,
+		"The time the circuit of a member cluster stays open, failing its requests fast, before a request probes it again.")
+	flag.StringVar(&memberDiscoveryCacheDir, "member-discovery-cache-dir", "",
+		"The directory persisting the discovery of the member clusters, served at once when the manager restarts.")
+	flag.StringVar(&memberDiscoveryCacheNamespace, "member-discovery-cache-namespace", "",
+		"The namespace of the ConfigMaps persisting the discovery of the member clusters, shared by the replicas "+
+			"of the manager. The discovery is kept in memory only if neither it nor the directory is set.")
+	flag.DurationVar(&memberDiscoveryRefreshPeriod, "member-discovery-refresh-period", 10*time.Minute,
+		"The period to refresh the discovery of the member clusters in the background, besides on their CRD changes.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -78,20 +190,194 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
-	p, err := pool.New(mgr.GetConfig())
+	metadataOnly, err := search.ParseResources(memberCacheMetadataOnly)
+	if err != nil {
+		setupLog.Error(err, "invalid member cache metadata only resources")
+		os.Exit(1)
+	}
+	discoveryOptions := mapper.DiscoveryOptions{RefreshPeriod: memberDiscoveryRefreshPeriod}
+	switch {
+	case len(memberDiscoveryCacheDir) != 0 && len(memberDiscoveryCacheNamespace) != 0:
+		setupLog.Error(nil, "member discovery cache dir and namespace are exclusive")
+		os.Exit(1)
+	case len(memberDiscoveryCacheDir) != 0:
+		discoveryOptions.Store = &mapper.DirStore{Dir: memberDiscoveryCacheDir}
+	case len(memberDiscoveryCacheNamespace) != 0:
+		discoveryOptions.Store = &mapper.ConfigMapStore{Client: mgr.GetClient(), Reader: mgr.GetAPIReader(),
+			Namespace: memberDiscoveryCacheNamespace}
+	}
+	p, err := pool.New(mgr.GetConfig(), mgr.GetEventRecorderFor("cluster-pool"), pool.WithConcurrency(poolConcurrency))
 	if err != nil {
 		setupLog.Error(err, "initializing cluster pool failed")
 		os.Exit(1)
 	}
 
+	ctrlmetrics.Registry.MustRegister(pool.StatusCollector(p))
+	if err = mgr.Add(p); err != nil {
+		setupLog.Error(err, "unable to add cluster pool to manager")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.ClusterController{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 		Pool:   p,
+
+		NodePoolLabel:       nodePoolLabel,
+		Recorder:            mgr.GetEventRecorderFor("cluster-controller"),
+		StatusSyncPeriod:    statusSyncPeriod,
+		TaintGracePeriod:    taintGracePeriod,
+		EvictionGracePeriod: evictionGracePeriod,
+		CacheOptions: cluster.CacheOptions{
+			Lazy:        memberCacheLazy,
+			IdleTimeout: memberCacheIdleTimeout,
+
+			StripManagedFields: memberCacheStripManagedFields,
+			MaxAnnotationSize:  memberCacheMaxAnnotationSize,
+			MetadataOnly:       metadataOnly,
+		},
+		RateLimit:        sumengzscnv1beta1.RateLimit{QPS: int32(memberQPS), Burst: int32(memberBurst)},
+		DiscoveryOptions: discoveryOptions,
+		BreakerOptions: cluster.BreakerOptions{
+			FailureThreshold: memberBreakerFailureThreshold,
+			OpenTimeout:      memberBreakerOpenTimeout,
+		},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterSetController{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterSet")
+		os.Exit(1)
+	}
+	if err = (&controllers.PropagationPolicyController{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Pool:       p,
+		SyncPeriod: propagationSyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PropagationPolicy")
+		os.Exit(1)
+	}
+	if err = (&controllers.ResourceBindingController{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Pool:       p,
+		SyncPeriod: bindingStatusSyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ResourceBinding")
+		os.Exit(1)
+	}
+	if err = (&controllers.GarbageCollector{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Pool:        p,
+		RetryPeriod: garbageCollectionRetryPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GarbageCollector")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServiceExportController{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Pool:       p,
+		SyncPeriod: serviceExportSyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServiceExport")
+		os.Exit(1)
+	}
+	if err = (&controllers.ServiceImportController{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Pool:       p,
+		SyncPeriod: serviceImportSyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServiceImport")
+		os.Exit(1)
+	}
+	if err = (&controllers.FederatedRBACController{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Pool:       p,
+		Namespace:  federatedRBACNamespace,
+		SyncPeriod: federatedRBACSyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "FederatedRBAC")
+		os.Exit(1)
+	}
+	var hubClient kubernetes.Interface
+	if len(searchAddr) != 0 || len(proxyAddr) != 0 {
+		if hubClient, err = kubernetes.NewForConfig(mgr.GetConfig()); err != nil {
+			setupLog.Error(err, "unable to create hub client")
+			os.Exit(1)
+		}
+	}
+	if len(searchAddr) != 0 {
+		if len(searchCertFile) == 0 || len(searchKeyFile) == 0 {
+			setupLog.Error(nil, "the search endpoint requires a serving certificate and key")
+			os.Exit(1)
+		}
+		resources, err := search.ParseResources(searchResources)
+		if err != nil {
+			setupLog.Error(err, "invalid search resources")
+			os.Exit(1)
+		}
+		index := search.NewIndex()
+		if err = mgr.Add(&search.Indexer{
+			Pool:       p,
+			Index:      index,
+			Resources:  resources,
+			SyncPeriod: searchSyncPeriod,
+		}); err != nil {
+			setupLog.Error(err, "unable to create search indexer")
+			os.Exit(1)
+		}
+		if err = mgr.Add(&search.Server{
+			Addr:          searchAddr,
+			CertFile:      searchCertFile,
+			KeyFile:       searchKeyFile,
+			Searcher:      index,
+			Authenticator: &proxy.TokenReviewAuthenticator{Client: hubClient},
+			Authorizer:    &proxy.SubjectAccessReviewAuthorizer{Client: hubClient},
+		}); err != nil {
+			setupLog.Error(err, "unable to create search server")
+			os.Exit(1)
+		}
+	}
+	if len(proxyAddr) != 0 {
+		if len(proxyCertFile) == 0 || len(proxyKeyFile) == 0 {
+			setupLog.Error(nil, "the proxy requires a serving certificate and key")
+			os.Exit(1)
+		}
+		clusterProxy := &proxy.Proxy{
+			Pool:          p,
+			Authenticator: &proxy.TokenReviewAuthenticator{Client: hubClient},
+			Authorizer:    &proxy.SubjectAccessReviewAuthorizer{Client: hubClient},
+
+			AllowClusterIdentity: proxyAllowClusterIdentity,
+		}
+		if proxyImpersonation {
+			clusterProxy.Identities = &identity.Resolver{Client: mgr.GetClient()}
+		}
+		if err = mgr.Add(&proxy.Server{
+			Addr:     proxyAddr,
+			CertFile: proxyCertFile,
+			KeyFile:  proxyKeyFile,
+			Proxy:    clusterProxy,
+		}); err != nil {
+			setupLog.Error(err, "unable to create proxy server")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&sumengzscnv1.Cluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Cluster")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,239 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrClusterUnavailable is the error of the requests failed fast while the circuit of the cluster is open.
+var ErrClusterUnavailable = errors.New("cluster is unavailable")
+
+// UnavailableError is the error of a request to a cluster failed fast by its open circuit, it is ErrClusterUnavailable.
+type UnavailableError struct {
+	Cluster string
+	// RetryAfter is the time the circuit half-opens to let a request through again.
+	RetryAfter time.Time
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("cluster %s is unavailable, its circuit is open until %s", e.Cluster, e.RetryAfter.Format(time.RFC3339))
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrClusterUnavailable
+}
+
+// CircuitState is the state of the circuit breaker of the clients of a cluster.
+type CircuitState int
+
+const (
+	// CircuitClosed lets the requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails the requests fast.
+	CircuitOpen
+	// CircuitHalfOpen lets a probe request through, the circuit is closed if it succeeds and opened again otherwise.
+	CircuitHalfOpen
+)
+
+var circuitStates = []string{"closed", "open", "half-open"}
+
+func (s CircuitState) String() string {
+	if s >= 0 && int(s) < len(circuitStates) {
+		return circuitStates[s]
+	}
+	return "unknown"
+}
+
+// BreakerOptions configures the circuit breaker of the clients of a cluster.
+type BreakerOptions struct {
+	// FailureThreshold is the number of the consecutive failed requests opening the circuit,
+	// the breaker is disabled if it is zero.
+	FailureThreshold int
+	// OpenTimeout is the time the circuit stays open before it half-opens.
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerOptions are the options of the circuit breakers of the clusters by default.
+var DefaultBreakerOptions = BreakerOptions{FailureThreshold: 5, OpenTimeout: 30 * time.Second}
+
+// WithBreakerOptions configures the circuit breaker of the clients of the cluster.
+func WithBreakerOptions(opts BreakerOptions) InitOptions {
+	return func(i Interface) error {
+		c, ok := i.(*cluster)
+		if !ok {
+			return fmt.Errorf("unsupported cluster %T", i)
+		}
+		c.breaker.mu.Lock()
+		defer c.breaker.mu.Unlock()
+		c.breaker.opts = opts
+		return nil
+	}
+}
+
+// breaker is the circuit breaker of the requests to a cluster, it opens after the consecutive failures
+// of the threshold, and half-opens after the open timeout to let a probe request through.
+type breaker struct {
+	cluster string
+	// onChange is called with the new state after the state is changed, it must not block.
+	onChange func(state CircuitState)
+	now      func() time.Time
+
+	mu       sync.Mutex
+	opts     BreakerOptions
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(cluster string, opts BreakerOptions) *breaker {
+	return &breaker{cluster: cluster, opts: opts, now: time.Now}
+}
+
+// State returns the state of the circuit, an open circuit is half-open once its open timeout is over.
+func (b *breaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && !b.now().Before(b.retryAfter()) {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *breaker) retryAfter() time.Time {
+	return b.openedAt.Add(b.opts.OpenTimeout)
+}
+
+// allow returns an UnavailableError if the request must fail fast, and whether the request
+// allowed is the probe of the half-open circuit, which is passed to done with its outcome.
+func (b *breaker) allow() (bool, error) {
+	b.mu.Lock()
+	if b.opts.FailureThreshold <= 0 || b.state == CircuitClosed {
+		b.mu.Unlock()
+		return false, nil
+	}
+	if b.probing || b.now().Before(b.retryAfter()) {
+		err := &UnavailableError{Cluster: b.cluster, RetryAfter: b.retryAfter()}
+		b.mu.Unlock()
+		return false, err
+	}
+	b.probing = true
+	b.setState(CircuitHalfOpen)
+	return true, nil
+}
+
+// outcome is the outcome of a request allowed by the breaker.
+type outcome int
+
+const (
+	// succeeded means the cluster served the request.
+	succeeded outcome = iota
+	// failedByCluster means the request failed because of the cluster.
+	failedByCluster
+	// canceled means the request was canceled by the caller, it tells nothing about the cluster.
+	canceled
+)
+
+// done records the outcome of a request allowed, probe is whether it is the probe of the half-open
+// circuit. Only the probe changes the half-open circuit, the outcomes of the requests allowed before
+// the circuit opened are stale then. A canceled probe changes nothing but releases the half-open
+// circuit, so another probe is let through.
+func (b *breaker) done(probe bool, result outcome) {
+	b.mu.Lock()
+	if b.opts.FailureThreshold <= 0 {
+		b.mu.Unlock()
+		return
+	}
+	switch {
+	case !probe && b.state == CircuitClosed:
+		switch result {
+		case succeeded:
+			b.failures = 0
+		case failedByCluster:
+			if b.failures++; b.failures >= b.opts.FailureThreshold {
+				b.open()
+				return
+			}
+		}
+	case probe && b.state == CircuitHalfOpen:
+		b.probing = false
+		switch result {
+		case succeeded:
+			b.failures = 0
+			b.setState(CircuitClosed)
+			return
+		case failedByCluster:
+			b.open()
+			return
+		}
+	}
+	b.mu.Unlock()
+}
+
+// open opens the circuit, the breaker must be locked by the caller and is unlocked by it.
+func (b *breaker) open() {
+	b.openedAt = b.now()
+	b.failures = 0
+	b.setState(CircuitOpen)
+}
+
+// setState changes the state and notifies the change, the breaker must be locked by the caller and is unlocked by it.
+func (b *breaker) setState(state CircuitState) {
+	changed := b.state != state
+	b.state = state
+	b.mu.Unlock()
+	if changed && b.onChange != nil {
+		b.onChange(state)
+	}
+}
+
+// outcomeOf returns the outcome of the request, the errors of the objects, e.g. NotFound and Conflict,
+// are not failures of the cluster.
+func outcomeOf(req *http.Request, resp *http.Response, err error) outcome {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return canceled
+		}
+		return failedByCluster
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return failedByCluster
+	}
+	return succeeded
+}
+
+// breakerTransport fails the requests fast while the circuit of the breaker is open.
+type breakerTransport struct {
+	breaker  *breaker
+	delegate http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	probe, err := t.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.delegate.RoundTrip(req)
+	t.breaker.done(probe, outcomeOf(req, resp, err))
+	return resp, err
+}
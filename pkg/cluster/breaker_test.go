@@ -0,0 +1,303 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(opts BreakerOptions) (*breaker, *testClock, *[]CircuitState) {
+	var mu sync.Mutex
+	var changes []CircuitState
+	clock := &testClock{now: time.Now()}
+	b := newBreaker("test", opts)
+	b.now = clock.Now
+	b.onChange = func(state CircuitState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, state)
+	}
+	return b, clock, &changes
+}
+
+func TestBreaker(t *testing.T) {
+	b, clock, changes := newTestBreaker(BreakerOptions{FailureThreshold: 3, OpenTimeout: 10 * time.Second})
+	call := func(result outcome) error {
+		probe, err := b.allow()
+		if err != nil {
+			return err
+		}
+		b.done(probe, result)
+		return nil
+	}
+
+	// the successes reset the consecutive failures, the canceled requests change nothing
+	for _, result := range []outcome{failedByCluster, failedByCluster, succeeded, failedByCluster, canceled, failedByCluster} {
+		if err := call(result); err != nil {
+			t.Fatalf("allow() = %v while the circuit is closed", err)
+		}
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("State() = %s, want closed", state)
+	}
+
+	// the circuit opens after the consecutive failures and fails fast
+	_ = call(failedByCluster)
+	err := call(succeeded)
+	var unavailable *UnavailableError
+	if !errors.Is(err, ErrClusterUnavailable) || !errors.As(err, &unavailable) || unavailable.Cluster != "test" {
+		t.Fatalf("allow() = %v, want ErrClusterUnavailable of the cluster", err)
+	}
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("State() = %s, want open", state)
+	}
+
+	// the circuit half-opens after the timeout, lets one probe through and opens again when it fails
+	clock.Step(10 * time.Second)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("State() = %s, want half-open after the timeout", state)
+	}
+	if probe, err := b.allow(); err != nil || !probe {
+		t.Fatalf("allow() = %v, %v, want the probe allowed", probe, err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrClusterUnavailable) {
+		t.Fatalf("allow() = %v, want the requests failed fast while probing", err)
+	}
+	b.done(true, failedByCluster)
+	if err := call(succeeded); !errors.Is(err, ErrClusterUnavailable) {
+		t.Fatalf("allow() = %v, want the circuit opened again by the failed probe", err)
+	}
+
+	// a canceled probe releases the half-open circuit for another probe
+	clock.Step(10 * time.Second)
+	if err := call(canceled); err != nil {
+		t.Fatalf("allow() = %v, want the probe allowed", err)
+	}
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("State() = %s, want half-open after the canceled probe", state)
+	}
+
+	// the circuit closes when the probe succeeds
+	if err := call(succeeded); err != nil {
+		t.Fatalf("allow() = %v, want the probe allowed", err)
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("State() = %s, want closed after the probe succeeded", state)
+	}
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if !reflect.DeepEqual(*changes, want) {
+		t.Errorf("changes = %v, want %v", *changes, want)
+	}
+}
+
+func TestBreaker_StaleOutcome(t *testing.T) {
+	b, clock, _ := newTestBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: 10 * time.Second})
+	staleSucceeded, _ := b.allow()
+	staleFailed, _ := b.allow()
+	failed, _ := b.allow()
+	b.done(failed, failedByCluster)
+	clock.Step(10 * time.Second)
+	if probe, err := b.allow(); err != nil || !probe {
+		t.Fatalf("allow() = %v, %v, want the probe allowed", probe, err)
+	}
+
+	// the requests allowed while the circuit was closed change nothing while the probe is in flight
+	b.done(staleSucceeded, succeeded)
+	b.done(staleFailed, failedByCluster)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("State() = %s, want half-open until the probe is done", state)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrClusterUnavailable) {
+		t.Fatalf("allow() = %v, want the requests failed fast while probing", err)
+	}
+	b.done(true, succeeded)
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("State() = %s, want closed after the probe succeeded", state)
+	}
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b, _, changes := newTestBreaker(BreakerOptions{})
+	for i := 0; i < 10; i++ {
+		probe, err := b.allow()
+		if err != nil {
+			t.Fatalf("allow() = %v, want the disabled breaker to allow all", err)
+		}
+		b.done(probe, failedByCluster)
+	}
+	if len(*changes) != 0 {
+		t.Errorf("changes = %v, want none", *changes)
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	var requests int32
+	var down atomic.Value
+	down.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch {
+		case down.Load().(bool):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	b, clock, _ := newTestBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute})
+	config := &rest.Config{Host: server.URL}
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &breakerTransport{breaker: b, delegate: rt}
+	})
+	client, err := rest.HTTPClientFor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) error {
+		resp, err := client.Get(server.URL + path)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get("/"); err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+	}
+	if err := get("/"); !errors.Is(err, ErrClusterUnavailable) {
+		t.Fatalf("request = %v, want ErrClusterUnavailable", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("server received %d requests, want 2 as the open circuit fails fast", n)
+	}
+
+	// the errors of the objects do not open the circuit
+	down.Store(false)
+	clock.Step(time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := get("/missing"); err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("State() = %s, want closed", state)
+	}
+
+	// the requests canceled by the callers do not open the circuit
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Errorf("State() = %s, want closed after the canceled requests", state)
+	}
+}
+
+func TestCluster_Circuit(t *testing.T) {
+	clu, caches := newTestCluster()
+	synced := make(chan struct{})
+	(*caches)[0].synced = synced
+	clu.breaker = newBreaker("test", BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Nanosecond})
+	clu.breaker.onChange = clu.circuitChanged
+	var mu sync.Mutex
+	var changes []Code
+	clu.OnStatusChange(func(_, new Code) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, new)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := clu.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor := func(want Code) {
+		t.Helper()
+		for i := 0; clu.Status() != want; i++ {
+			if i == 500 {
+				t.Fatalf("Status() = %s, want %s", clu.Status(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the circuit opened before the cache is synced keeps the cluster waiting after the sync
+	_, _ = clu.breaker.allow()
+	clu.breaker.done(false, failedByCluster)
+	waitFor(Waiting)
+	close(synced)
+	time.Sleep(10 * time.Millisecond)
+	waitFor(Waiting)
+
+	time.Sleep(time.Millisecond)
+	probe, err := clu.breaker.allow()
+	if err != nil {
+		t.Fatalf("allow() = %v, want the probe allowed", err)
+	}
+	clu.breaker.done(probe, succeeded)
+	waitFor(Ready)
+
+	// the circuit of the stopped cluster changes no status
+	clu.Stop()
+	probe, _ = clu.breaker.allow()
+	clu.breaker.done(probe, failedByCluster)
+	if status := clu.Status(); status != Stopped {
+		t.Fatalf("Status() = %s, want stopped", status)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []Code{Started, Waiting, Ready, Stopped}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+}
+
+func TestCluster_ReadyOnCacheSync(t *testing.T) {
+	clu, caches := newTestCluster()
+	synced := make(chan struct{})
+	(*caches)[0].synced = synced
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := clu.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status := clu.Status(); status != Started {
+		t.Fatalf("Status() = %s, want started before the cache is synced", status)
+	}
+	close(synced)
+	for i := 0; clu.Status() != Ready; i++ {
+		if i == 500 {
+			t.Fatalf("Status() = %s, want ready once the cache is synced", clu.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,13 +2,18 @@ package cluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/mapper"
+	"github.com/sumengzs/multi-cluster/pkg/metrics"
 	"github.com/sumengzs/multi-cluster/pkg/utils"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -17,6 +22,46 @@ type Builder struct {
 	master      client.Client
 	scheme      *runtime.Scheme
 	options     []InitOptions
+	recorder    record.EventRecorder
+	cache       *CacheOptions
+	rateLimit   *v1beta1.RateLimit
+	limiters    RateLimiterProvider
+	discovery   *mapper.DiscoveryOptions
+}
+
+// BuildError is the error of building a cluster, the reason is the machine-readable reason of the failure.
+type BuildError struct {
+	Reason string
+	Err    error
+}
+
+func (e *BuildError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// Reason returns the reason of the error building a cluster, it is InvalidConfig for the errors without one.
+func Reason(err error) string {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return buildErr.Reason
+	}
+	var configErr *utils.ConfigError
+	if errors.As(err, &configErr) {
+		return configErr.Reason
+	}
+	return v1beta1.ReasonInvalidConfig
+}
+
+// connectReason returns the reason of the error connecting to the API server of a cluster.
+func connectReason(err error) string {
+	if apierrors.IsUnauthorized(err) || apierrors.IsForbidden(err) {
+		return v1beta1.ReasonCredentialsRejected
+	}
+	return v1beta1.ReasonEndpointUnreachable
 }
 
 func By(master client.Client) *Builder {
@@ -36,6 +81,32 @@ func (b *Builder) WithOptions(opts ...InitOptions) *Builder {
 	return b
 }
 
+// WithEventRecorder records the build failures and the lifecycle transitions of the cluster as events on its Cluster object.
+func (b *Builder) WithEventRecorder(recorder record.EventRecorder) *Builder {
+	b.recorder = recorder
+	return b
+}
+
+// WithCacheOptions configures the cache of the cluster.
+func (b *Builder) WithCacheOptions(opts CacheOptions) *Builder {
+	b.cache = &opts
+	return b
+}
+
+// WithRateLimit limits the requests of all the clients of the cluster with a shared token bucket, the rate limit
+// of the Cluster object overrides the default one. The limiters provide the shared rate limiters, they may be nil.
+func (b *Builder) WithRateLimit(defaults v1beta1.RateLimit, limiters RateLimiterProvider) *Builder {
+	b.rateLimit = &defaults
+	b.limiters = limiters
+	return b
+}
+
+// WithDiscoveryOptions serves the REST mapper of the cluster from its persisted discovery, refreshed in the background.
+func (b *Builder) WithDiscoveryOptions(opts mapper.DiscoveryOptions) *Builder {
+	b.discovery = &opts
+	return b
+}
+
 func (b *Builder) Named(clusterName string) *Builder {
 	b.clusterName = clusterName
 	return b
@@ -51,11 +122,26 @@ func (b *Builder) Complete() (Interface, error) {
 	}
 	config, err := b.loadConfig(clusterCR.Spec.Connect)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load client rest config: %s", err)
+		return nil, b.failed(clusterCR, &BuildError{Reason: Reason(err),
+			Err: fmt.Errorf("failed to load client rest config: %s", err)})
+	}
+	limitConfig(b.clusterName, config, b.rateLimit, clusterCR.Spec.RateLimit, b.limiters)
+	options := b.options
+	if b.recorder != nil {
+		options = append([]InitOptions{WithEventRecorder(b.recorder)}, options...)
+	}
+	if b.cache != nil {
+		options = append([]InitOptions{WithCacheOptions(*b.cache)}, options...)
+	}
+	var cluster Interface
+	if b.discovery != nil {
+		cluster, err = newPersistedCluster(b.clusterName, config, b.scheme, *b.discovery, options...)
+	} else {
+		cluster, err = New(b.clusterName, config, b.scheme, options...)
 	}
-	cluster, err := New(config, b.scheme, b.options...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cluster: %s", err)
+		return nil, b.failed(clusterCR, &BuildError{Reason: Reason(err),
+			Err: fmt.Errorf("failed to create cluster: %s", err)})
 	}
 	if clusterCR.Spec.Disabled {
 		cluster.Disable()
@@ -63,6 +149,14 @@ func (b *Builder) Complete() (Interface, error) {
 	return cluster, nil
 }
 
+// failed records the build failure as a warning event on the Cluster object.
+func (b *Builder) failed(clusterCR *v1beta1.Cluster, err *BuildError) error {
+	if b.recorder != nil {
+		b.recorder.Event(clusterCR, v1.EventTypeWarning, err.Reason, err.Error())
+	}
+	return err
+}
+
 func (b *Builder) loadClusterCR() (*v1beta1.Cluster, error) {
 	if b.master == nil {
 		return nil, fmt.Errorf("must provide a non-nil master cluster client")
@@ -74,7 +168,11 @@ func (b *Builder) loadClusterCR() (*v1beta1.Cluster, error) {
 }
 
 func (b *Builder) loadConfig(connect v1beta1.ConnectConfig) (*rest.Config, error) {
-	return utils.BuildConfig(b.clusterName, connect, b.secretGetter)
+	config, err := utils.BuildConfig(b.clusterName, connect, b.secretGetter)
+	if err != nil {
+		return nil, err
+	}
+	return metrics.InstrumentConfig(b.clusterName, config), nil
 }
 
 func (b *Builder) clusterGetter(name string) (*v1beta1.Cluster, error) {
@@ -2,10 +2,15 @@ package cluster
 
 import (
 	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +23,7 @@ var (
 func init() {
 	fakeScheme = runtime.NewScheme()
 	v1beta1.AddToScheme(fakeScheme)
+	corev1.AddToScheme(fakeScheme)
 	clusterCR = GetMockCluster()
 	fakeClient = fake.NewClientBuilder().WithScheme(fakeScheme).WithObjects(clusterCR).Build()
 }
@@ -101,10 +107,11 @@ func TestBuilder_Complete(t *testing.T) {
 		scheme      *runtime.Scheme
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		want    Interface
-		wantErr bool
+		name       string
+		fields     fields
+		want       Interface
+		wantErr    bool
+		wantReason string
 	}{
 		{
 			name: "test-cluster",
@@ -113,18 +120,101 @@ func TestBuilder_Complete(t *testing.T) {
 				master:      fakeClient,
 				scheme:      fakeScheme,
 			},
-			want:    nil,
-			wantErr: true,
+			want:       nil,
+			wantErr:    true,
+			wantReason: v1beta1.ReasonSecretNotFound,
+		},
+		{
+			name: "token without ca",
+			fields: fields{
+				clusterName: "token-cluster",
+				master: fake.NewClientBuilder().WithScheme(fakeScheme).WithObjects(&v1beta1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "token-cluster"},
+					Spec: v1beta1.ClusterSpec{Connect: v1beta1.ConnectConfig{
+						Token:    &v1beta1.TokenRef{Token: "A78DDS464Z"},
+						Endpoint: "https://10.10.0.1:6443",
+					}},
+				}).Build(),
+				scheme: fakeScheme,
+			},
+			want:       nil,
+			wantErr:    true,
+			wantReason: v1beta1.ReasonInvalidConfig,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			b := By(tt.fields.master).WithScheme(tt.fields.scheme).Named(tt.fields.clusterName)
+			recorder := record.NewFakeRecorder(1)
+			b := By(tt.fields.master).WithScheme(tt.fields.scheme).WithEventRecorder(recorder).Named(tt.fields.clusterName)
 			_, err := b.Complete()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Complete() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if !tt.wantErr {
+				return
+			}
+			if reason := Reason(err); reason != tt.wantReason {
+				t.Errorf("Reason() = %v, want %v", reason, tt.wantReason)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+tt.wantReason+" ") {
+					t.Errorf("event = %v, want reason %v", event, tt.wantReason)
+				}
+			default:
+				t.Errorf("no event recorded")
+			}
+		})
+	}
+}
+
+// fakeLimiters provides the rate limiters of the clusters in the tests.
+type fakeLimiters map[string]flowcontrol.RateLimiter
+
+func (l fakeLimiters) RateLimiter(cluster string, qps float32, burst int) flowcontrol.RateLimiter {
+	limiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	if qps > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	l[cluster] = limiter
+	return limiter
+}
+
+func TestLimitConfig(t *testing.T) {
+	defaults := &v1beta1.RateLimit{QPS: 20, Burst: 40}
+	tests := []struct {
+		name      string
+		defaults  *v1beta1.RateLimit
+		limit     *v1beta1.RateLimit
+		wantQPS   float32
+		wantBurst int
+		limited   bool
+	}{
+		{name: "unlimited"},
+		{name: "default", defaults: defaults, wantQPS: 20, wantBurst: 40, limited: true},
+		{name: "cluster", defaults: defaults, limit: &v1beta1.RateLimit{QPS: 5, Burst: 10}, wantQPS: 5, wantBurst: 10, limited: true},
+		{name: "burst defaults to qps", limit: &v1beta1.RateLimit{QPS: 5}, wantQPS: 5, wantBurst: 5, limited: true},
+		{name: "zero default", defaults: &v1beta1.RateLimit{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &rest.Config{}
+			limiters := fakeLimiters{}
+			limitConfig("a", config, tt.defaults, tt.limit, limiters)
+			if config.QPS != tt.wantQPS || config.Burst != tt.wantBurst {
+				t.Errorf("QPS, Burst = %v, %v, want %v, %v", config.QPS, config.Burst, tt.wantQPS, tt.wantBurst)
+			}
+			// the limiter of the provider is installed even if unlimited, so the limit set later applies
+			if config.RateLimiter == nil || config.RateLimiter != limiters["a"] {
+				t.Errorf("RateLimiter is not shared from the provider")
+			}
+
+			config = &rest.Config{}
+			limitConfig("a", config, tt.defaults, tt.limit, nil)
+			if limited := config.RateLimiter != nil; limited != tt.limited {
+				t.Fatalf("limited without provider = %v, want %v", limited, tt.limited)
+			}
 		})
 	}
 }
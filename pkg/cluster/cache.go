@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// CacheOptions configures the cache of a member cluster.
+type CacheOptions struct {
+	// Lazy starts the informer of a kind on its first use rather than caching the kinds
+	// in one cache for the lifetime of the cluster, and stops it when it is idle.
+	Lazy bool
+	// IdleTimeout is the time after which the informers of the lazy cache not read are stopped,
+	// the informers used for event handlers are never stopped. Zero never stops the informers.
+	IdleTimeout time.Duration
+	// Restrictions restrict the objects cached of the kinds.
+	Restrictions map[schema.GroupVersionKind]Restriction
+
+	// StripManagedFields drops the managed fields of the objects cached.
+	StripManagedFields bool
+	// MaxAnnotationSize drops the annotations larger than it from the objects cached, zero keeps them.
+	MaxAnnotationSize int
+	// Transforms transform the objects cached of the kinds, e.g. dropping the fields not read.
+	Transforms map[schema.GroupVersionKind][]Transform
+	// MetadataOnly caches only the metadata of the objects of the kinds, the other fields
+	// of the objects read from the cache are empty.
+	MetadataOnly []schema.GroupVersionKind
+}
+
+// Restriction restricts the objects cached of a kind.
+type Restriction struct {
+	// Namespace caches the objects in the namespace only, it requires the lazy cache.
+	Namespace string
+	// Label caches the objects matching the label selector only.
+	Label labels.Selector
+	// Field caches the objects matching the field selector only.
+	Field fields.Selector
+}
+
+// WithCacheOptions caches the objects of the cluster as configured by the options.
+func WithCacheOptions(opts CacheOptions) InitOptions {
+	return func(i Interface) error {
+		c, ok := i.(*cluster)
+		if !ok {
+			return fmt.Errorf("unsupported cluster %T", i)
+		}
+		newCache, err := newCacheFunc(c.config, c.scheme, c.mapper, opts)
+		if err != nil {
+			return err
+		}
+		if c.cache, err = newCache(); err != nil {
+			return fmt.Errorf("failed to create runtime cache: %s", err)
+		}
+		c.newCache = newCache
+		return nil
+	}
+}
+
+// newCacheFunc returns the function creating the caches of a cluster configured by the options.
+func newCacheFunc(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper,
+	opts CacheOptions) (func() (cache.Cache, error), error) {
+	if opts.transforming() {
+		config = transformConfig(config, mapper, opts)
+	}
+	if opts.Lazy {
+		return func() (cache.Cache, error) {
+			return newLazyCache(config, scheme, mapper, opts), nil
+		}, nil
+	}
+	selectors := make(cache.SelectorsByObject)
+	for gvk, restriction := range opts.Restrictions {
+		if len(restriction.Namespace) != 0 {
+			return nil, fmt.Errorf("namespace restriction of %s requires the lazy cache", gvk)
+		}
+		selectors[objectFor(scheme, gvk)] = cache.ObjectSelector{Label: restriction.Label, Field: restriction.Field}
+	}
+	return func() (cache.Cache, error) {
+		return cache.New(config, cache.Options{Scheme: scheme, Mapper: mapper, SelectorsByObject: selectors})
+	}, nil
+}
+
+// objectFor returns an empty object of the kind, which is unstructured if the kind is not in the scheme.
+func objectFor(scheme *runtime.Scheme, gvk schema.GroupVersionKind) client.Object {
+	if obj, err := scheme.New(gvk); err == nil {
+		if o, ok := obj.(client.Object); ok {
+			return o
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
@@ -19,16 +19,23 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
 	"github.com/sumengzs/multi-cluster/pkg/mapper"
+	"github.com/sumengzs/multi-cluster/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
 )
 
 var _ Interface = &cluster{}
@@ -36,49 +43,96 @@ var _ Interface = &cluster{}
 type InitOptions func(Interface) error
 
 type cluster struct {
-	name       string
-	ctx        context.Context
-	cancelFunc context.CancelFunc
-	status     Code
+	name string
+	// mu guards the status, the cache and its context.
+	mu           sync.RWMutex
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	status       Code
+	cache        cache.Cache
+	cacheStarted bool
+	// cacheSynced is whether the cache started by the last start is synced.
+	cacheSynced bool
+	newCache    func() (cache.Cache, error)
+	// notifyMu orders the notifications of the status changes.
+	notifyMu sync.Mutex
+
 	scheme     *runtime.Scheme
 	client     client.Client
-	cache      cache.Cache
 	mapper     meta.RESTMapper
 	dynamic    dynamic.Interface
 	discovery  discovery.DiscoveryInterface
 	extensions clientset.Interface
 	config     *rest.Config
+	// clientConfig is the config of the clients guarded by the circuit breaker.
+	clientConfig *rest.Config
+	recorder     record.EventRecorder
+	breaker      *breaker
+
+	handlersMu sync.Mutex
+	handlers   []StatusHandler
+}
+
+// WithEventRecorder records the events of the lifecycle transitions of the cluster on its Cluster object.
+func WithEventRecorder(recorder record.EventRecorder) InitOptions {
+	return func(i Interface) error {
+		c, ok := i.(*cluster)
+		if !ok {
+			return fmt.Errorf("unsupported cluster %T", i)
+		}
+		c.recorder = recorder
+		return nil
+	}
 }
 
 // New returns a new cluster or error
 // default status code is Stopped
-func New(config *rest.Config, scheme *runtime.Scheme, options ...InitOptions) (Interface, error) {
-	var clu *cluster
-	var err error
-
-	clu.mapper, err = mapper.Provider(config)
+func New(name string, config *rest.Config, scheme *runtime.Scheme, options ...InitOptions) (Interface, error) {
+	restMapper, err := mapper.Provider(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create mapper: %s", err)
+		return nil, &BuildError{Reason: connectReason(err), Err: fmt.Errorf("failed to create mapper: %s", err)}
 	}
+	clu, err := newCluster(name, config, scheme, restMapper, options...)
+	if err != nil {
+		return nil, err
+	}
+	return clu, nil
+}
+
+// newCluster returns a new cluster serving the REST mapper.
+func newCluster(name string, config *rest.Config, scheme *runtime.Scheme, restMapper meta.RESTMapper,
+	options ...InitOptions) (*cluster, error) {
+	clu := &cluster{name: name, config: config, mapper: restMapper, breaker: newBreaker(name, DefaultBreakerOptions)}
+	clu.breaker.onChange = clu.circuitChanged
+	var err error
+	// the clients fail fast while the circuit is open, the mapper and the cache keep retrying on their own.
+	clientConfig := rest.CopyConfig(config)
+	clu.clientConfig = clientConfig
+	clientConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &breakerTransport{breaker: clu.breaker, delegate: rt}
+	})
 
-	if clu.client, err = client.New(config, client.Options{Scheme: scheme, Mapper: clu.mapper}); err != nil {
+	if clu.client, err = client.New(clientConfig, client.Options{Scheme: scheme, Mapper: clu.mapper}); err != nil {
 		return nil, fmt.Errorf("failed to create runtime client: %s", err)
 	}
 
-	if clu.cache, err = cache.New(config, cache.Options{Scheme: scheme, Mapper: clu.mapper}); err != nil {
+	clu.newCache = func() (cache.Cache, error) {
+		return cache.New(config, cache.Options{Scheme: scheme, Mapper: clu.mapper})
+	}
+	if clu.cache, err = clu.newCache(); err != nil {
 		return nil, fmt.Errorf("failed to create runtime cache: %s", err)
 	}
 
-	if clu.dynamic, err = dynamic.NewForConfig(config); err != nil {
+	if clu.dynamic, err = dynamic.NewForConfig(clientConfig); err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %s", err)
 	}
 
-	if clu.extensions, err = clientset.NewForConfig(config); err != nil {
+	if clu.extensions, err = clientset.NewForConfig(clientConfig); err != nil {
 		return nil, fmt.Errorf("failed to create api-extensions client: %s", err)
 	}
 	clu.extensions.ApiextensionsV1beta1().CustomResourceDefinitions()
 
-	if clu.discovery, err = discovery.NewDiscoveryClientForConfig(config); err != nil {
+	if clu.discovery, err = discovery.NewDiscoveryClientForConfig(clientConfig); err != nil {
 		return nil, fmt.Errorf("failed to create discovery client: %s", err)
 	}
 
@@ -94,34 +148,52 @@ func New(config *rest.Config, scheme *runtime.Scheme, options ...InitOptions) (I
 	return clu, nil
 }
 
+// Start starts the cache of the cluster. A cluster stopped before is restarted with a new cache,
+// as a cache can not be started twice. It returns an error if the cluster is disabled.
 func (c *cluster) Start(ctx context.Context) error {
-	switch {
-	case c.status == Disabled:
-		klog.Infof("%s,needs to be enabled first", c)
-	case c.status >= Started:
+	c.mu.Lock()
+	if c.status >= Started {
+		c.mu.Unlock()
 		klog.Infof("%s,no need to start", c)
-	case c.status == Stopped:
-		c.ctx, c.cancelFunc = context.WithCancel(ctx)
-		go func() {
-			_ = c.cache.Start(c.ctx)
-		}()
-		c.status = Started
-		klog.Infof("%s", c)
-	default:
-		return fmt.Errorf("%s", c)
+		return nil
 	}
-	return nil
+	if !ValidTransition(c.status, Started) {
+		c.mu.Unlock()
+		return &TransitionError{Cluster: c.name, From: c.status, To: Started}
+	}
+	if c.cacheStarted {
+		newCache, err := c.newCache()
+		if err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to recreate runtime cache: %s", err)
+		}
+		c.cache = newCache
+	}
+	c.ctx, c.cancelFunc = context.WithCancel(ctx)
+	c.cacheStarted = true
+	c.cacheSynced = false
+	go func(ctx context.Context, cache cache.Cache) {
+		_ = cache.Start(ctx)
+	}(c.ctx, c.cache)
+	go c.waitForCacheSync(c.ctx, c.cache)
+	if runnable, ok := c.mapper.(mapperRunnable); ok {
+		go func(ctx context.Context) {
+			_ = runnable.Start(ctx)
+		}(c.ctx)
+	}
+	return c.transition(Started)
 }
 
+// Stop stops the cache of the cluster, it does nothing if the cluster is not started.
 func (c *cluster) Stop() {
-	if c.status == Disabled {
+	c.mu.Lock()
+	if c.status <= Stopped {
+		c.mu.Unlock()
 		klog.Infof("%s,no need stop", c)
+		return
 	}
-	if c.status > Stopped {
-		c.cancelFunc()
-		c.status = Stopped
-	}
-	klog.Infof("%s", c)
+	c.cancelFunc()
+	_ = c.transition(Stopped)
 }
 
 func (c *cluster) Name() string {
@@ -129,14 +201,77 @@ func (c *cluster) Name() string {
 }
 
 func (c *cluster) Status() Code {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.status
 }
 
+// Disable stops the cluster if it is started and disables it, it can not be started until it is enabled.
 func (c *cluster) Disable() {
-	if c.status >= Started {
-		c.Stop()
+	c.mu.Lock()
+	switch {
+	case c.status == Disabled:
+		c.mu.Unlock()
+	case c.status >= Started:
+		c.cancelFunc()
+		_ = c.transition(Stopped, Disabled)
+	default:
+		_ = c.transition(Disabled)
+	}
+}
+
+// Enable enables the disabled cluster so it can be started again, it does nothing if the cluster is not disabled.
+func (c *cluster) Enable() error {
+	c.mu.Lock()
+	if c.status != Disabled {
+		c.mu.Unlock()
+		return nil
 	}
-	c.status = Disabled
+	return c.transition(Stopped)
+}
+
+func (c *cluster) OnStatusChange(handler StatusHandler) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+// transition changes the status of the cluster through the codes in order, the cluster must be locked
+// by the caller and is unlocked by it. It returns an error without any change if a transition is invalid,
+// otherwise it notifies the handlers of every change, the changes are notified in the order they are made.
+func (c *cluster) transition(codes ...Code) error {
+	from := c.status
+	for _, to := range codes {
+		if !ValidTransition(from, to) {
+			c.mu.Unlock()
+			return &TransitionError{Cluster: c.name, From: from, To: to}
+		}
+		from = to
+	}
+	from, c.status = c.status, from
+	c.notifyMu.Lock()
+	c.mu.Unlock()
+	defer c.notifyMu.Unlock()
+
+	c.handlersMu.Lock()
+	handlers := c.handlers
+	c.handlersMu.Unlock()
+	for _, to := range codes {
+		klog.Infof("cluster %s is %s", c.name, to)
+		switch to {
+		case Started:
+			c.event(corev1.EventTypeNormal, v1beta1.ReasonClusterStarted, to)
+		case Stopped:
+			c.event(corev1.EventTypeNormal, v1beta1.ReasonClusterStopped, to)
+		case Disabled:
+			c.event(corev1.EventTypeNormal, v1beta1.ReasonClusterDisabled, to)
+		}
+		for _, handler := range handlers {
+			handler(from, to)
+		}
+		from = to
+	}
+	return nil
 }
 
 func (c *cluster) Client() client.Client {
@@ -144,6 +279,8 @@ func (c *cluster) Client() client.Client {
 }
 
 func (c *cluster) Cache() cache.Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.cache
 }
 
@@ -163,10 +300,70 @@ func (c *cluster) Config() *rest.Config {
 	return c.config
 }
 
+func (c *cluster) ClientConfig() *rest.Config {
+	return c.clientConfig
+}
+
 func (c *cluster) Discovery() discovery.DiscoveryInterface {
 	return c.discovery
 }
 
+func (c *cluster) Circuit() CircuitState {
+	return c.breaker.State()
+}
+
+// circuitChanged feeds the state of the circuit into the status of the cluster, the started cluster is waiting
+// while its circuit is open, and is ready once its circuit is closed again.
+func (c *cluster) circuitChanged(state CircuitState) {
+	metrics.RecordCircuit(c.name, state == CircuitOpen)
+	c.mu.Lock()
+	switch {
+	case state == CircuitOpen && (c.status == Started || c.status == Ready):
+		if c.transition(Waiting) == nil {
+			c.event(corev1.EventTypeWarning, v1beta1.ReasonCircuitOpen, Waiting)
+		}
+	case state == CircuitClosed && c.status == Waiting && c.cacheSynced:
+		if c.transition(Ready) == nil {
+			c.event(corev1.EventTypeNormal, v1beta1.ReasonCircuitClosed, Ready)
+		}
+	default:
+		c.mu.Unlock()
+	}
+}
+
+// waitForCacheSync moves the cluster started with the context to Ready once its cache is synced,
+// or to Waiting if the circuit of its clients is open then.
+func (c *cluster) waitForCacheSync(ctx context.Context, cache cache.Cache) {
+	if !cache.WaitForCacheSync(ctx) {
+		return
+	}
+	c.mu.Lock()
+	if ctx.Err() != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.cacheSynced = true
+	// a half-open circuit is not closed until its probe succeeds
+	open := c.breaker.State() != CircuitClosed
+	switch {
+	case open && c.status == Started:
+		_ = c.transition(Waiting)
+	case !open && (c.status == Started || c.status == Waiting):
+		_ = c.transition(Ready)
+	default:
+		c.mu.Unlock()
+	}
+}
+
+// event records the event of a lifecycle transition on the Cluster object.
+func (c *cluster) event(eventType, reason string, code Code) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Eventf(&v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: c.name}}, eventType, reason,
+		"cluster %s is %s", c.name, code)
+}
+
 func (c *cluster) String() string {
-	return fmt.Sprintf("cluster %s is %s", c.name, c.status)
+	return fmt.Sprintf("cluster %s is %s", c.name, c.Status())
 }
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sync"
+	"testing"
+)
+
+type fakeCache struct {
+	cache.Cache
+	// synced is closed when the cache is synced, it is never synced if it is nil.
+	synced chan struct{}
+}
+
+func (c *fakeCache) WaitForCacheSync(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.synced:
+		return true
+	}
+}
+
+func (c *fakeCache) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// newTestCluster returns a stopped cluster whose caches are recorded in the order they are created.
+func newTestCluster() (*cluster, *[]*fakeCache) {
+	var caches []*fakeCache
+	clu := &cluster{name: "test", status: Stopped, breaker: newBreaker("test", DefaultBreakerOptions)}
+	clu.newCache = func() (cache.Cache, error) {
+		c := &fakeCache{}
+		caches = append(caches, c)
+		return c, nil
+	}
+	clu.cache, _ = clu.newCache()
+	return clu, &caches
+}
+
+func TestValidTransition(t *testing.T) {
+	tests := []struct {
+		from Code
+		to   Code
+		want bool
+	}{
+		{from: Disabled, to: Stopped, want: true},
+		{from: Disabled, to: Started},
+		{from: Stopped, to: Started, want: true},
+		{from: Stopped, to: Disabled, want: true},
+		{from: Started, to: Disabled},
+		{from: Started, to: Ready, want: true},
+		{from: Ready, to: Stopped, want: true},
+		{from: Waiting, to: Started},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from.String()+"-"+tt.to.String(), func(t *testing.T) {
+			if got := ValidTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("ValidTransition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCluster_Lifecycle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clu, caches := newTestCluster()
+	type change struct{ from, to Code }
+	var changes []change
+	clu.OnStatusChange(func(old, new Code) {
+		changes = append(changes, change{from: old, to: new})
+	})
+
+	clu.Disable()
+	var transitionErr *TransitionError
+	if err := clu.Start(ctx); !errors.As(err, &transitionErr) {
+		t.Fatalf("Start() of disabled cluster error = %v, want TransitionError", err)
+	}
+	if err := clu.Enable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := clu.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := clu.Start(ctx); err != nil {
+		t.Fatalf("Start() of started cluster error = %v", err)
+	}
+	clu.Stop()
+	if err := clu.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clu.Disable()
+
+	want := []change{
+		{from: Stopped, to: Disabled},
+		{from: Disabled, to: Stopped},
+		{from: Stopped, to: Started},
+		{from: Started, to: Stopped},
+		{from: Stopped, to: Started},
+		{from: Started, to: Stopped},
+		{from: Stopped, to: Disabled},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("changes[%d] = %v, want %v", i, changes[i], want[i])
+		}
+	}
+	if len(*caches) != 2 {
+		t.Fatalf("created %d caches, want a new cache for the restart", len(*caches))
+	}
+	if clu.Cache() != (*caches)[1] {
+		t.Errorf("Cache() is not the cache of the restart")
+	}
+}
+
+func TestCluster_Race(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clu, _ := newTestCluster()
+	var mu sync.Mutex
+	last := Stopped
+	clu.OnStatusChange(func(old, new Code) {
+		mu.Lock()
+		defer mu.Unlock()
+		if old != last || !ValidTransition(old, new) {
+			t.Errorf("change from %s to %s after %s", old, new, last)
+		}
+		last = new
+		// handlers may read the cluster
+		_ = clu.Status()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				switch (i + j) % 5 {
+				case 0:
+					_ = clu.Start(ctx)
+				case 1:
+					clu.Stop()
+				case 2:
+					clu.Disable()
+				case 3:
+					_ = clu.Enable()
+				default:
+					_ = clu.Status()
+					_ = clu.Cache()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if status := clu.Status(); status != last {
+		t.Errorf("Status() = %s, last change to %s", status, last)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/pkg/mapper"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+// mapperRunnable is the REST mapper refreshing the discovery in the background while the cluster is started.
+type mapperRunnable interface {
+	Start(ctx context.Context) error
+}
+
+// newPersistedCluster returns a new cluster serving the REST mapper from the discovery persisted by the store of the
+// options, so the cluster is not discovered when it is built, the discovery is refreshed in the background while the
+// cluster is started.
+func newPersistedCluster(name string, config *rest.Config, scheme *runtime.Scheme, opts mapper.DiscoveryOptions,
+	options ...InitOptions) (Interface, error) {
+	restMapper, err := mapper.NewPersistedRESTMapper(name, config, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mapper: %s", err)
+	}
+	clu, err := newCluster(name, config, scheme, restMapper, options...)
+	if err != nil {
+		return nil, err
+	}
+	return clu, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UserInterface accesses a member cluster as a user impersonated by the identity of the
+// cluster, so the requests are authorized and audited as the user in the member. It has no
+// cache, because the cache of the cluster is shared by all the users.
+type UserInterface interface {
+	Name() string
+	User() rest.ImpersonationConfig
+	Client() client.Client
+	Dynamic() dynamic.Interface
+	RESTMapper() meta.RESTMapper
+	Config() *rest.Config
+	Discovery() discovery.DiscoveryInterface
+}
+
+type userCluster struct {
+	name      string
+	user      rest.ImpersonationConfig
+	client    client.Client
+	mapper    meta.RESTMapper
+	dynamic   dynamic.Interface
+	discovery discovery.DiscoveryInterface
+	config    *rest.Config
+}
+
+var _ UserInterface = &userCluster{}
+
+// Impersonate returns the UserInterface of the cluster impersonating the user. The identity
+// of the cluster needs the permission to impersonate the user, groups and extra in the member.
+// The REST mapper of the cluster is shared, so the user may see the kinds it can not access.
+func Impersonate(clu Interface, user rest.ImpersonationConfig) (UserInterface, error) {
+	if len(user.UserName) == 0 {
+		return nil, fmt.Errorf("cluster %s: impersonated user name cannot be empty", clu.Name())
+	}
+	config := rest.CopyConfig(clu.Config())
+	config.Impersonate = user
+
+	uc := &userCluster{name: clu.Name(), user: user, mapper: clu.RESTMapper(), config: config}
+	var err error
+	if uc.client, err = client.New(config, client.Options{Scheme: clu.Client().Scheme(), Mapper: uc.mapper}); err != nil {
+		return nil, fmt.Errorf("cluster %s: failed to create client of user %s: %s", clu.Name(), user.UserName, err)
+	}
+	if uc.dynamic, err = dynamic.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("cluster %s: failed to create dynamic client of user %s: %s", clu.Name(), user.UserName, err)
+	}
+	if uc.discovery, err = discovery.NewDiscoveryClientForConfig(config); err != nil {
+		return nil, fmt.Errorf("cluster %s: failed to create discovery client of user %s: %s", clu.Name(), user.UserName, err)
+	}
+	return uc, nil
+}
+
+func (c *userCluster) Name() string {
+	return c.name
+}
+
+func (c *userCluster) User() rest.ImpersonationConfig {
+	return c.user
+}
+
+func (c *userCluster) Client() client.Client {
+	return c.client
+}
+
+func (c *userCluster) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+func (c *userCluster) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func (c *userCluster) Config() *rest.Config {
+	return c.config
+}
+
+func (c *userCluster) Discovery() discovery.DiscoveryInterface {
+	return c.discovery
+}
@@ -37,12 +37,27 @@ type Interface interface {
 	Dynamic() dynamic.Interface
 	RESTMapper() meta.RESTMapper
 	Config() *rest.Config
+	// ClientConfig returns the config Client, Dynamic, Discovery and ApiExtensions are created from, its transport
+	// is guarded by the circuit breaker and its RateLimiter is shared by the clients of the cluster.
+	ClientConfig() *rest.Config
 	Discovery() discovery.DiscoveryInterface
+	// Circuit returns the state of the circuit breaker of Client, Dynamic, Discovery and ApiExtensions,
+	// they fail fast with ErrClusterUnavailable while it is open.
+	Circuit() CircuitState
 }
 
+// StatusHandler handles the change of the status of a cluster from old to new,
+// it is called synchronously by the transition and must not block.
+type StatusHandler func(old, new Code)
+
 type Status interface {
 	Status() Code
+	// Disable stops the cluster if it is started, and disables it until it is enabled.
 	Disable()
+	// Enable enables the disabled cluster, so it can be started again.
+	Enable() error
+	// OnStatusChange registers the handler of the changes of the status.
+	OnStatusChange(handler StatusHandler)
 }
 
 type Runnable interface {
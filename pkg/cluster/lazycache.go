@@ -0,0 +1,300 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ cache.Cache = &lazyCache{}
+
+// lazyCache caches every kind in a cache of its own, started on the first use of the kind
+// and stopped when it is not read for the idle timeout. Every kind may be restricted to a
+// namespace, and to the objects matching label and field selectors.
+type lazyCache struct {
+	scheme       *runtime.Scheme
+	idleTimeout  time.Duration
+	restrictions map[schema.GroupVersionKind]Restriction
+	// newCache creates the cache of a kind with the options.
+	newCache func(opts cache.Options) (cache.Cache, error)
+	now      func() time.Time
+
+	mu      sync.Mutex
+	ctx     context.Context
+	started chan struct{}
+	kinds   map[schema.GroupVersionKind]*kindEntry
+	indexes []index
+}
+
+// kindEntry is the cache of a kind.
+type kindEntry struct {
+	cache  cache.Cache
+	cancel context.CancelFunc
+	// started is closed when the cache is started, synced is whether it is synced then.
+	started  chan struct{}
+	synced   bool
+	lastUsed time.Time
+	// readers is the number of the reads in progress, the cache is not stopped when idle while it is read.
+	readers int
+	// pinned caches have informers used for event handlers, they are never stopped when idle.
+	pinned bool
+}
+
+// index is a field index of the objects of a kind, added to the cache of the kind when it is created.
+type index struct {
+	gvk     schema.GroupVersionKind
+	obj     client.Object
+	field   string
+	extract client.IndexerFunc
+}
+
+func newLazyCache(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper, opts CacheOptions) *lazyCache {
+	return &lazyCache{
+		scheme:       scheme,
+		idleTimeout:  opts.IdleTimeout,
+		restrictions: opts.Restrictions,
+		newCache: func(cacheOpts cache.Options) (cache.Cache, error) {
+			cacheOpts.Mapper = mapper
+			return cache.New(config, cacheOpts)
+		},
+		now:     time.Now,
+		started: make(chan struct{}),
+		kinds:   make(map[schema.GroupVersionKind]*kindEntry),
+	}
+}
+
+// cacheFor returns the started cache of the kind, it creates and starts the cache on the first use of the kind.
+// The cache is not stopped when idle until release is called, and waiting for it to be synced is given up when
+// the context is done.
+func (c *lazyCache) cacheFor(ctx context.Context, gvk schema.GroupVersionKind, pin bool) (kindCache cache.Cache, release func(), err error) {
+	kc, err := c.entryFor(gvk, pin)
+	if err != nil {
+		return nil, nil, err
+	}
+	release = func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		kc.readers--
+		kc.lastUsed = c.now()
+	}
+	select {
+	case <-ctx.Done():
+		release()
+		return nil, nil, ctx.Err()
+	case <-kc.started:
+	}
+	if !kc.synced {
+		release()
+		return nil, nil, fmt.Errorf("cache of %s is stopped before it is synced", gvk)
+	}
+	return kc.cache, release, nil
+}
+
+// entryFor returns the cache of the kind with a reader added, it creates and starts the cache on the first use of the kind.
+func (c *lazyCache) entryFor(gvk schema.GroupVersionKind, pin bool) (*kindEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		return nil, &cache.ErrCacheNotStarted{}
+	}
+	if kc, ok := c.kinds[gvk]; ok {
+		kc.lastUsed = c.now()
+		kc.readers++
+		kc.pinned = kc.pinned || pin
+		return kc, nil
+	}
+
+	opts := cache.Options{Scheme: c.scheme}
+	if restriction, ok := c.restrictions[gvk]; ok {
+		opts.Namespace = restriction.Namespace
+		if restriction.Label != nil || restriction.Field != nil {
+			opts.SelectorsByObject = cache.SelectorsByObject{
+				objectFor(c.scheme, gvk): {Label: restriction.Label, Field: restriction.Field},
+			}
+		}
+	}
+	kindCache, err := c.newCache(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache of %s: %s", gvk, err)
+	}
+	for _, idx := range c.indexes {
+		if idx.gvk != gvk {
+			continue
+		}
+		if err := kindCache.IndexField(c.ctx, idx.obj, idx.field, idx.extract); err != nil {
+			return nil, fmt.Errorf("failed to index %s of %s: %s", idx.field, gvk, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	kc := &kindEntry{cache: kindCache, cancel: cancel, started: make(chan struct{}), lastUsed: c.now(), readers: 1, pinned: pin}
+	c.kinds[gvk] = kc
+
+	go func() {
+		if err := kindCache.Start(ctx); err != nil {
+			klog.Errorf("error running cache of %s: %v", gvk, err)
+		}
+	}()
+	klog.V(4).Infof("started cache of %s", gvk)
+	// the cache reads after it is started only
+	go func() {
+		kc.synced = kindCache.WaitForCacheSync(ctx)
+		close(kc.started)
+	}()
+	return kc, nil
+}
+
+func (c *lazyCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	kindCache, release, err := c.cacheFor(ctx, gvk, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return kindCache.Get(ctx, key, obj)
+}
+
+func (c *lazyCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	gvk, err := apiutil.GVKForObject(list, c.scheme)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(gvk.Kind, "List") {
+		return fmt.Errorf("non-list type %T (kind %q) passed as output", list, gvk)
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	kindCache, release, err := c.cacheFor(ctx, gvk, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return kindCache.List(ctx, list, opts...)
+}
+
+func (c *lazyCache) GetInformer(ctx context.Context, obj client.Object) (cache.Informer, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	kindCache, release, err := c.cacheFor(ctx, gvk, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return kindCache.GetInformer(ctx, obj)
+}
+
+func (c *lazyCache) GetInformerForKind(ctx context.Context, gvk schema.GroupVersionKind) (cache.Informer, error) {
+	kindCache, release, err := c.cacheFor(ctx, gvk, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return kindCache.GetInformerForKind(ctx, gvk)
+}
+
+// IndexField adds the index to the cache of the kind of the object, it must be
+// called before the kind is used, as an index can not be added to a started informer.
+func (c *lazyCache) IndexField(ctx context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes = append(c.indexes, index{gvk: gvk, obj: obj, field: field, extract: extractValue})
+	if kc, ok := c.kinds[gvk]; ok {
+		return kc.cache.IndexField(ctx, obj, field, extractValue)
+	}
+	return nil
+}
+
+// Start starts the cache, the caches of the kinds are started on their first use.
+// It blocks until the context is done and stops the caches of the kinds then.
+func (c *lazyCache) Start(ctx context.Context) error {
+	c.mu.Lock()
+	if c.ctx != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("the cache can not be started twice")
+	}
+	c.ctx = ctx
+	close(c.started)
+	c.mu.Unlock()
+
+	if c.idleTimeout > 0 {
+		go wait.UntilWithContext(ctx, c.stopIdle, c.idleTimeout/2)
+	}
+	<-ctx.Done()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for gvk, kc := range c.kinds {
+		kc.cancel()
+		delete(c.kinds, gvk)
+	}
+	return nil
+}
+
+// stopIdle stops the caches of the kinds not read for the idle timeout and not being read.
+func (c *lazyCache) stopIdle(context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for gvk, kc := range c.kinds {
+		if !kc.pinned && kc.readers == 0 && now.Sub(kc.lastUsed) >= c.idleTimeout {
+			kc.cancel()
+			delete(c.kinds, gvk)
+			klog.V(4).Infof("stopped idle cache of %s", gvk)
+		}
+	}
+}
+
+// WaitForCacheSync waits for the cache to be started, and the caches of the kinds used to sync.
+func (c *lazyCache) WaitForCacheSync(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.started:
+	}
+	c.mu.Lock()
+	caches := make([]cache.Cache, 0, len(c.kinds))
+	for _, kc := range c.kinds {
+		caches = append(caches, kc.cache)
+	}
+	c.mu.Unlock()
+	for _, kindCache := range caches {
+		if !kindCache.WaitForCacheSync(ctx) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kindTestCache is the cache of a kind created by the lazy cache in the tests.
+type kindTestCache struct {
+	informertest.FakeInformers
+	opts    cache.Options
+	indexed []string
+	stopped chan struct{}
+	// synced blocks the sync of the cache until it is closed if it is not nil.
+	synced chan struct{}
+}
+
+func (c *kindTestCache) WaitForCacheSync(ctx context.Context) bool {
+	if c.synced == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.synced:
+		return true
+	}
+}
+
+func (c *kindTestCache) Start(ctx context.Context) error {
+	<-ctx.Done()
+	close(c.stopped)
+	return nil
+}
+
+func (c *kindTestCache) IndexField(_ context.Context, _ client.Object, field string, _ client.IndexerFunc) error {
+	c.indexed = append(c.indexed, field)
+	return nil
+}
+
+// testClock is the clock of the lazy cache in the tests.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestLazyCache(opts CacheOptions) (*lazyCache, *[]*kindTestCache, *testClock) {
+	var mu sync.Mutex
+	var created []*kindTestCache
+	clock := &testClock{now: time.Now()}
+	c := newLazyCache(nil, scheme.Scheme, nil, opts)
+	c.newCache = func(opts cache.Options) (cache.Cache, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		kc := &kindTestCache{FakeInformers: informertest.FakeInformers{Scheme: scheme.Scheme}, opts: opts,
+			stopped: make(chan struct{})}
+		created = append(created, kc)
+		return kc, nil
+	}
+	c.now = clock.Now
+	return c, &created, clock
+}
+
+func TestLazyCache(t *testing.T) {
+	selector := labels.SelectorFromSet(labels.Set{"app": "web"})
+	c, created, clock := newTestLazyCache(CacheOptions{
+		Lazy:        true,
+		IdleTimeout: time.Minute,
+		Restrictions: map[schema.GroupVersionKind]Restriction{
+			corev1.SchemeGroupVersion.WithKind("Pod"): {Namespace: "apps", Label: selector},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var notStarted *cache.ErrCacheNotStarted
+	if err := c.Get(ctx, client.ObjectKey{Name: "a"}, &corev1.ConfigMap{}); !errors.As(err, &notStarted) {
+		t.Fatalf("Get() before Start() error = %v, want ErrCacheNotStarted", err)
+	}
+	if err := c.IndexField(ctx, &corev1.Pod{}, "spec.nodeName", nil); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = c.Start(ctx) }()
+	if !c.WaitForCacheSync(ctx) {
+		t.Fatal("WaitForCacheSync() = false")
+	}
+
+	// the caches of the kinds are created on their first use only
+	if err := c.List(ctx, &corev1.PodList{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "apps", Name: "web"}, &corev1.Pod{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetInformer(ctx, &corev1.Service{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "a"}, &corev1.ConfigMap{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*created) != 3 {
+		t.Fatalf("created %d caches, want one per kind", len(*created))
+	}
+	pods, services, configMaps := (*created)[0], (*created)[1], (*created)[2]
+	if pods.opts.Namespace != "apps" || len(pods.opts.SelectorsByObject) != 1 {
+		t.Errorf("pod cache options = %+v, want restricted", pods.opts)
+	}
+	if len(pods.indexed) != 1 || len(configMaps.indexed) != 0 {
+		t.Errorf("indexed pods %v, config maps %v, want the pod index only", pods.indexed, configMaps.indexed)
+	}
+	if configMaps.opts.Namespace != "" || configMaps.opts.SelectorsByObject != nil {
+		t.Errorf("config map cache options = %+v, want unrestricted", configMaps.opts)
+	}
+
+	// the idle caches are stopped, except the caches whose informers are used
+	clock.Step(30 * time.Second)
+	if err := c.List(ctx, &corev1.ConfigMapList{}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Step(45 * time.Second)
+	c.stopIdle(ctx)
+	select {
+	case <-pods.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle pod cache is not stopped")
+	}
+	for name, kc := range map[string]*kindTestCache{"service": services, "config map": configMaps} {
+		select {
+		case <-kc.stopped:
+			t.Errorf("%s cache is stopped", name)
+		default:
+		}
+	}
+
+	// the kind is cached again on its next use
+	if err := c.List(ctx, &corev1.PodList{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*created) != 4 {
+		t.Errorf("created %d caches, want the pod cache created again", len(*created))
+	}
+
+	cancel()
+	select {
+	case <-services.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("service cache is not stopped with the cache")
+	}
+}
+
+func TestLazyCache_Read(t *testing.T) {
+	c, created, clock := newTestLazyCache(CacheOptions{Lazy: true, IdleTimeout: time.Minute})
+	synced := make(chan struct{})
+	newCache := c.newCache
+	c.newCache = func(opts cache.Options) (cache.Cache, error) {
+		kc, err := newCache(opts)
+		kc.(*kindTestCache).synced = synced
+		return kc, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Start(ctx) }()
+	if !c.WaitForCacheSync(ctx) {
+		t.Fatal("WaitForCacheSync() = false")
+	}
+
+	// the read gives up waiting for the cache to be synced when its context is done
+	readCtx, readCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer readCancel()
+	if err := c.List(readCtx, &corev1.PodList{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("List() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(synced)
+
+	// the cache being read is not stopped when idle
+	pods, release, err := c.cacheFor(ctx, corev1.SchemeGroupVersion.WithKind("Pod"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods != (*created)[0] {
+		t.Fatalf("cacheFor() = %v, want the cache created by the first read", pods)
+	}
+	clock.Step(2 * time.Minute)
+	c.stopIdle(ctx)
+	select {
+	case <-(*created)[0].stopped:
+		t.Fatal("pod cache is stopped while it is read")
+	default:
+	}
+
+	// it is stopped after it is idle for the timeout since the read
+	release()
+	clock.Step(30 * time.Second)
+	c.stopIdle(ctx)
+	select {
+	case <-(*created)[0].stopped:
+		t.Fatal("pod cache is stopped before it is idle for the timeout")
+	default:
+	}
+	clock.Step(30 * time.Second)
+	c.stopIdle(ctx)
+	select {
+	case <-(*created)[0].stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle pod cache is not stopped")
+	}
+}
+
+func TestNewCacheFunc(t *testing.T) {
+	_, err := newCacheFunc(nil, scheme.Scheme, nil, CacheOptions{
+		Restrictions: map[schema.GroupVersionKind]Restriction{
+			corev1.SchemeGroupVersion.WithKind("Pod"): {Namespace: "apps"},
+		},
+	})
+	if err == nil {
+		t.Errorf("newCacheFunc() with namespace restriction of the default cache error = nil")
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// RateLimiterProvider provides the rate limiter of a member cluster, it is shared by all the clients of the cluster.
+// The limiter is unlimited if the QPS is not positive.
+type RateLimiterProvider interface {
+	RateLimiter(cluster string, qps float32, burst int) flowcontrol.RateLimiter
+}
+
+// EffectiveRateLimit returns the QPS and the burst of the rate limit of a cluster, the limit of the cluster
+// overrides the default limit, and the burst defaults to the QPS. It returns false if neither limit is set.
+func EffectiveRateLimit(defaults, limit *v1beta1.RateLimit) (float32, int, bool) {
+	if limit == nil {
+		limit = defaults
+	}
+	if limit == nil || limit.QPS < 1 {
+		return 0, 0, false
+	}
+	burst := int(limit.Burst)
+	if burst < 1 {
+		burst = int(limit.QPS)
+	}
+	return float32(limit.QPS), burst, true
+}
+
+// limitConfig limits the requests of all the clients created from the config with the shared rate limiter.
+// The limiter of the provider is installed even if no rate limit is set, so the rate limit set or removed
+// later applies to the clients at once. Without a provider, the config is left unlimited if no rate limit is set.
+func limitConfig(name string, config *rest.Config, defaults, limit *v1beta1.RateLimit, limiters RateLimiterProvider) {
+	qps, burst, ok := EffectiveRateLimit(defaults, limit)
+	if limiters != nil {
+		config.QPS, config.Burst = qps, burst
+		config.RateLimiter = limiters.RateLimiter(name, qps, burst)
+		return
+	}
+	if !ok {
+		return
+	}
+	config.QPS, config.Burst = qps, burst
+	config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+}
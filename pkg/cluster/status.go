@@ -16,6 +16,10 @@ limitations under the License.
 
 package cluster
 
+import (
+	"fmt"
+)
+
 type Code int
 
 const (
@@ -33,9 +37,48 @@ const (
 
 var codes = []string{"disabled", "stopped", "started", "waiting", "ready"}
 
+// Codes returns all the status codes of a cluster.
+func Codes() []Code {
+	all := make([]Code, len(codes))
+	for i := range codes {
+		all[i] = Code(i)
+	}
+	return all
+}
+
 func (c Code) String() string {
-	if int(c) < len(codes)-1 {
+	if c >= 0 && int(c) < len(codes) {
 		return codes[c]
 	}
 	return "unknown"
 }
+
+// transitions are the valid transitions of the status of a cluster.
+var transitions = map[Code][]Code{
+	Disabled: {Stopped},
+	Stopped:  {Started, Disabled},
+	Started:  {Waiting, Ready, Stopped},
+	Waiting:  {Ready, Stopped},
+	Ready:    {Waiting, Stopped},
+}
+
+// ValidTransition returns true if the status of a cluster can change from one code to another.
+func ValidTransition(from, to Code) bool {
+	for _, code := range transitions[from] {
+		if code == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is the error of an invalid transition of the status of a cluster.
+type TransitionError struct {
+	Cluster string
+	From    Code
+	To      Code
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cluster %s can not change from %s to %s", e.Cluster, e.From, e.To)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Transform transforms an object before it is cached, the object is its JSON decoded into a map.
+type Transform func(obj map[string]interface{})
+
+// DropField returns the transform dropping the field at the path, e.g. "status", "images".
+// The transform does nothing if the path is empty.
+func DropField(path ...string) Transform {
+	if len(path) == 0 {
+		return func(map[string]interface{}) {}
+	}
+	return func(obj map[string]interface{}) {
+		parent := obj
+		for _, field := range path[:len(path)-1] {
+			child, ok := parent[field].(map[string]interface{})
+			if !ok {
+				return
+			}
+			parent = child
+		}
+		delete(parent, path[len(path)-1])
+	}
+}
+
+// DropAnnotations returns the transform dropping the annotations of the keys.
+func DropAnnotations(keys ...string) Transform {
+	return func(obj map[string]interface{}) {
+		for _, key := range keys {
+			DropField("metadata", "annotations", key)(obj)
+		}
+	}
+}
+
+// stripManagedFields drops the managed fields.
+func stripManagedFields(obj map[string]interface{}) {
+	DropField("metadata", "managedFields")(obj)
+}
+
+// dropLargeAnnotations returns the transform dropping the annotations whose values are larger than the size.
+func dropLargeAnnotations(size int) Transform {
+	return func(obj map[string]interface{}) {
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		annotations, _ := metadata["annotations"].(map[string]interface{})
+		for key, value := range annotations {
+			if s, ok := value.(string); ok && len(s) > size {
+				delete(annotations, key)
+			}
+		}
+	}
+}
+
+// metadataOnly drops all the fields except the type and the metadata.
+func metadataOnly(obj map[string]interface{}) {
+	for field := range obj {
+		switch field {
+		case "apiVersion", "kind", "metadata":
+		default:
+			delete(obj, field)
+		}
+	}
+}
+
+// transforms returns the transforms of the kind configured by the options.
+func (opts CacheOptions) transforms(gvk schema.GroupVersionKind) []Transform {
+	var transforms []Transform
+	if opts.StripManagedFields {
+		transforms = append(transforms, stripManagedFields)
+	}
+	if opts.MaxAnnotationSize > 0 {
+		transforms = append(transforms, dropLargeAnnotations(opts.MaxAnnotationSize))
+	}
+	transforms = append(transforms, opts.Transforms[gvk]...)
+	for _, kind := range opts.MetadataOnly {
+		if kind == gvk {
+			transforms = append(transforms, metadataOnly)
+		}
+	}
+	return transforms
+}
+
+// transforming returns true if the options transform any object.
+func (opts CacheOptions) transforming() bool {
+	return opts.StripManagedFields || opts.MaxAnnotationSize > 0 || len(opts.Transforms) != 0 || len(opts.MetadataOnly) != 0
+}
+
+// transformConfig returns a copy of the config of a cache whose transport transforms the objects
+// listed and watched as configured by the options, before they are decoded and cached. The objects
+// are requested in JSON, so they can be transformed whatever their types.
+func transformConfig(config *rest.Config, mapper meta.RESTMapper, opts CacheOptions) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.ContentType = runtime.ContentTypeJSON
+	config.AcceptContentTypes = runtime.ContentTypeJSON
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &transformer{delegate: rt, mapper: mapper, opts: opts}
+	})
+	return config
+}
+
+type transformer struct {
+	delegate http.RoundTripper
+	mapper   meta.RESTMapper
+	opts     CacheOptions
+}
+
+func (t *transformer) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), runtime.ContentTypeJSON) {
+		return resp, err
+	}
+	gvr, ok := resourceFor(req.URL.Path)
+	if !ok {
+		return resp, nil
+	}
+	var gvk schema.GroupVersionKind
+	if t.mapper != nil {
+		gvk, _ = t.mapper.KindFor(gvr)
+	}
+	transforms := t.opts.transforms(gvk)
+	if len(transforms) == 0 {
+		return resp, nil
+	}
+	if watch, _ := strconv.ParseBool(req.URL.Query().Get("watch")); watch {
+		resp.Body = transformWatch(resp.Body, transforms)
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if transformed, err := transformObject(body, transforms); err == nil {
+		body = transformed
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// resourceFor returns the resource of the request path of the objects of a resource, it returns
+// false for the other paths, e.g. the subresources and the discovery.
+func resourceFor(path string) (schema.GroupVersionResource, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var gvr schema.GroupVersionResource
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		gvr.Version, parts = parts[1], parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		gvr.Group, gvr.Version, parts = parts[1], parts[2], parts[3:]
+	default:
+		return gvr, false
+	}
+	if parts[0] == "namespaces" && len(parts) >= 3 {
+		parts = parts[2:]
+	}
+	if len(parts) > 2 {
+		return gvr, false
+	}
+	gvr.Resource = parts[0]
+	return gvr, true
+}
+
+// transformObject transforms the JSON object, or every item of the JSON list.
+func transformObject(data []byte, transforms []Transform) ([]byte, error) {
+	obj, err := decodeObject(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	apply(obj, transforms)
+	return json.Marshal(obj)
+}
+
+func apply(obj map[string]interface{}, transforms []Transform) {
+	if kind, _ := obj["kind"].(string); strings.HasSuffix(kind, "List") {
+		items, _ := obj["items"].([]interface{})
+		for _, item := range items {
+			if o, ok := item.(map[string]interface{}); ok {
+				apply(o, transforms)
+			}
+		}
+		return
+	}
+	for _, transform := range transforms {
+		transform(obj)
+	}
+}
+
+func decodeObject(decoder *json.Decoder) (map[string]interface{}, error) {
+	decoder.UseNumber()
+	obj := make(map[string]interface{})
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// transformWatch transforms the objects of the events of the JSON watch stream.
+func transformWatch(body io.ReadCloser, transforms []Transform) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+		decoder := json.NewDecoder(body)
+		encoder := json.NewEncoder(writer)
+		for {
+			event, err := decodeObject(decoder)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			if obj, ok := event["object"].(map[string]interface{}); ok && event["type"] != "ERROR" {
+				apply(obj, transforms)
+			}
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+	}()
+	return &watchBody{PipeReader: reader, body: body}
+}
+
+// watchBody closes the watch stream when the transformed stream is closed.
+type watchBody struct {
+	*io.PipeReader
+	body io.Closer
+}
+
+func (b *watchBody) Close() error {
+	_ = b.PipeReader.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const transformPod = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default",` +
+	`"managedFields":[{"manager":"kubectl"}],"annotations":{"small":"v","large":"%s"}},` +
+	`"spec":{"nodeName":"n","priority":1000000000},"status":{"phase":"Running"}}`
+
+func TestResourceFor(t *testing.T) {
+	tests := []struct {
+		path string
+		want schema.GroupVersionResource
+		ok   bool
+	}{
+		{"/api/v1/pods", schema.GroupVersionResource{Version: "v1", Resource: "pods"}, true},
+		{"/api/v1/namespaces/default/pods/a", schema.GroupVersionResource{Version: "v1", Resource: "pods"}, true},
+		{"/api/v1/namespaces/default", schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, true},
+		{"/apis/apps/v1/namespaces/default/deployments",
+			schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, true},
+		{"/api/v1/namespaces/default/pods/a/log", schema.GroupVersionResource{}, false},
+		{"/apis/apps/v1", schema.GroupVersionResource{}, false},
+		{"/version", schema.GroupVersionResource{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := resourceFor(tt.path)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("resourceFor() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDropField(t *testing.T) {
+	tests := []struct {
+		name string
+		path []string
+		want map[string]interface{}
+	}{
+		{
+			name: "top level field",
+			path: []string{"status"},
+			want: map[string]interface{}{"spec": map[string]interface{}{"nodeName": "n"}},
+		},
+		{
+			name: "nested field",
+			path: []string{"spec", "nodeName"},
+			want: map[string]interface{}{"spec": map[string]interface{}{}, "status": "s"},
+		},
+		{
+			name: "missing parent",
+			path: []string{"metadata", "name"},
+			want: map[string]interface{}{"spec": map[string]interface{}{"nodeName": "n"}, "status": "s"},
+		},
+		{
+			name: "empty path",
+			want: map[string]interface{}{"spec": map[string]interface{}{"nodeName": "n"}, "status": "s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := map[string]interface{}{"spec": map[string]interface{}{"nodeName": "n"}, "status": "s"}
+			DropField(tt.path...)(obj)
+			if !reflect.DeepEqual(obj, tt.want) {
+				t.Errorf("DropField() = %v, want %v", obj, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformConfig(t *testing.T) {
+	pod := fmt.Sprintf(transformPod, strings.Repeat("x", 100))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Query().Get("watch") == "true":
+			fmt.Fprintf(w, `{"type":"ADDED","object":%s}`+"\n", pod)
+			fmt.Fprintf(w, `{"type":"ERROR","object":{"kind":"Status","status":"Failure","code":410}}`+"\n")
+		case strings.HasSuffix(r.URL.Path, "/pods"):
+			fmt.Fprintf(w, `{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[%s]}`, pod)
+		default:
+			fmt.Fprint(w, pod)
+		}
+	}))
+	defer server.Close()
+
+	podKind := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(podKind, meta.RESTScopeNamespace)
+	stripped := map[string]interface{}{
+		"apiVersion": "v1", "kind": "Pod",
+		"metadata": map[string]interface{}{"name": "a", "namespace": "default",
+			"annotations": map[string]interface{}{"small": "v"}},
+		"spec":   map[string]interface{}{"nodeName": "n", "priority": json.Number("1000000000")},
+		"status": map[string]interface{}{"phase": "Running"},
+	}
+	tests := []struct {
+		name string
+		opts CacheOptions
+		path string
+		want map[string]interface{}
+	}{
+		{
+			name: "get",
+			opts: CacheOptions{StripManagedFields: true, MaxAnnotationSize: 10},
+			path: "/api/v1/namespaces/default/pods/a",
+			want: stripped,
+		},
+		{
+			name: "list",
+			opts: CacheOptions{StripManagedFields: true, MaxAnnotationSize: 10},
+			path: "/api/v1/pods",
+			want: stripped,
+		},
+		{
+			name: "watch",
+			opts: CacheOptions{StripManagedFields: true, MaxAnnotationSize: 10},
+			path: "/api/v1/pods?watch=true",
+			want: stripped,
+		},
+		{
+			name: "transforms",
+			opts: CacheOptions{Transforms: map[schema.GroupVersionKind][]Transform{
+				podKind: {DropField("status"), DropField("spec", "nodeName"), DropAnnotations("large", "small")},
+			}},
+			path: "/api/v1/namespaces/default/pods/a",
+			want: map[string]interface{}{
+				"apiVersion": "v1", "kind": "Pod",
+				"metadata": map[string]interface{}{"name": "a", "namespace": "default",
+					"managedFields": []interface{}{map[string]interface{}{"manager": "kubectl"}},
+					"annotations":   map[string]interface{}{}},
+				"spec": map[string]interface{}{"priority": json.Number("1000000000")},
+			},
+		},
+		{
+			name: "metadata only",
+			opts: CacheOptions{StripManagedFields: true, MetadataOnly: []schema.GroupVersionKind{podKind}},
+			path: "/api/v1/namespaces/default/pods/a",
+			want: map[string]interface{}{
+				"apiVersion": "v1", "kind": "Pod",
+				"metadata": map[string]interface{}{"name": "a", "namespace": "default",
+					"annotations": map[string]interface{}{"small": "v", "large": strings.Repeat("x", 100)}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := transformConfig(&rest.Config{Host: server.URL}, mapper, tt.opts)
+			if config.ContentType != "application/json" {
+				t.Errorf("ContentType = %q, want application/json", config.ContentType)
+			}
+			client, err := rest.HTTPClientFor(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			decoder := json.NewDecoder(resp.Body)
+			got, err := decodeObject(decoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch got["kind"] {
+			case "PodList":
+				got = got["items"].([]interface{})[0].(map[string]interface{})
+			case nil:
+				if got["type"] != "ADDED" {
+					t.Fatalf("event type = %v, want ADDED", got["type"])
+				}
+				got = got["object"].(map[string]interface{})
+				event, err := decodeObject(decoder)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if event["type"] != "ERROR" || event["object"].(map[string]interface{})["kind"] != "Status" {
+					t.Errorf("error event = %v, want unchanged", event)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("object = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformConfig_Untransformed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"v1","managedFields":[]}`)
+	}))
+	defer server.Close()
+
+	config := transformConfig(&rest.Config{Host: server.URL}, nil, CacheOptions{StripManagedFields: true})
+	client, err := rest.HTTPClientFor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL + "/api/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if want := `{"kind":"APIResourceList","groupVersion":"v1","managedFields":[]}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+//+kubebuilder:rbac:groups=sumengzs.cn,resources=identitymappings,verbs=get;list;watch
+
+// ErrNotMapped is returned when no identity mapping rule matches the hub user.
+var ErrNotMapped = errors.New("no identity mapping rule matches the user")
+
+// systemGroupPrefix is the prefix of the groups reserved by Kubernetes, e.g. system:masters.
+const systemGroupPrefix = "system:"
+
+// Map returns the identity impersonated in the member cluster for the hub user by the
+// first rule matching the user in the mappings targeting the cluster, in the order of
+// the names of the mappings. ErrNotMapped is returned if no rule matches. The groups of the
+// hub users are only kept by the rules with an empty identity, which impersonate the hub
+// users as they are except the system groups, a remapped user has only the groups of the rule.
+func Map(user *authenticationv1.UserInfo, clu *v1beta1.Cluster, mappings []v1beta1.IdentityMapping) (rest.ImpersonationConfig, error) {
+	mappings = append([]v1beta1.IdentityMapping(nil), mappings...)
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Name < mappings[j].Name })
+	for i := range mappings {
+		matched, err := propagation.MatchCluster(&mappings[i].Spec.TargetCluster, clu)
+		if err != nil {
+			return rest.ImpersonationConfig{}, fmt.Errorf("identity mapping %s: %s", mappings[i].Name, err)
+		}
+		if !matched {
+			continue
+		}
+		for _, rule := range mappings[i].Spec.Rules {
+			if !matchRule(&rule, user) {
+				continue
+			}
+			identity := rest.ImpersonationConfig{UserName: rule.Identity.User, Groups: rule.Identity.Groups}
+			if len(identity.UserName) != 0 {
+				return identity, nil
+			}
+			identity.UserName = user.Username
+			if len(identity.Groups) == 0 {
+				for _, group := range user.Groups {
+					if !strings.HasPrefix(group, systemGroupPrefix) {
+						identity.Groups = append(identity.Groups, group)
+					}
+				}
+			}
+			return identity, nil
+		}
+	}
+	return rest.ImpersonationConfig{}, ErrNotMapped
+}
+
+// matchRule returns true if the user is one of the users of the rule or in any of its groups.
+func matchRule(rule *v1beta1.IdentityMappingRule, user *authenticationv1.UserInfo) bool {
+	for _, name := range rule.Users {
+		if name == user.Username {
+			return true
+		}
+	}
+	for _, group := range rule.Groups {
+		for _, userGroup := range user.Groups {
+			if group == userGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolver resolves the identities impersonated in the member clusters for the hub users by
+// the IdentityMappings in the hub.
+type Resolver struct {
+	Client client.Reader
+}
+
+// Resolve returns the identity impersonated in the named member cluster for the hub user.
+func (r *Resolver) Resolve(ctx context.Context, cluster string, user *authenticationv1.UserInfo) (rest.ImpersonationConfig, error) {
+	clu := &v1beta1.Cluster{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: cluster}, clu); err != nil {
+		return rest.ImpersonationConfig{}, fmt.Errorf("failed to get cluster %s: %s", cluster, err)
+	}
+	mappings := &v1beta1.IdentityMappingList{}
+	if err := r.Client.List(ctx, mappings); err != nil {
+		return rest.ImpersonationConfig{}, fmt.Errorf("failed to list identity mappings: %s", err)
+	}
+	return Map(user, clu, mappings.Items)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"errors"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	clu := &v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}
+	mappings := []v1beta1.IdentityMapping{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "b-default"},
+			Spec: v1beta1.IdentityMappingSpec{Rules: []v1beta1.IdentityMappingRule{
+				{Groups: []string{"dev"}, Identity: v1beta1.Identity{Groups: []string{"viewers"}}},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-prod"},
+			Spec: v1beta1.IdentityMappingSpec{
+				TargetCluster: v1beta1.ClusterAffinity{ClusterNames: []string{"prod"}},
+				Rules: []v1beta1.IdentityMappingRule{
+					{Users: []string{"alice@example.com"}, Identity: v1beta1.Identity{User: "alice"}},
+					{Groups: []string{"ops"}, Identity: v1beta1.Identity{User: "operator", Groups: []string{"admins"}}},
+					{Groups: []string{"sre"}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-staging"},
+			Spec: v1beta1.IdentityMappingSpec{
+				TargetCluster: v1beta1.ClusterAffinity{ClusterNames: []string{"staging"}},
+				Rules: []v1beta1.IdentityMappingRule{
+					{Users: []string{"bob@example.com"}, Identity: v1beta1.Identity{User: "root"}},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		want    rest.ImpersonationConfig
+		wantErr error
+	}{
+		{
+			name: "remapped user drops hub groups",
+			user: authenticationv1.UserInfo{Username: "alice@example.com", Groups: []string{"ops"}},
+			want: rest.ImpersonationConfig{UserName: "alice"},
+		},
+		{
+			name: "group rule",
+			user: authenticationv1.UserInfo{Username: "carol@example.com", Groups: []string{"ops", "dev"}},
+			want: rest.ImpersonationConfig{UserName: "operator", Groups: []string{"admins"}},
+		},
+		{
+			name: "later mapping keeps user name",
+			user: authenticationv1.UserInfo{Username: "dave@example.com", Groups: []string{"dev"}},
+			want: rest.ImpersonationConfig{UserName: "dave@example.com", Groups: []string{"viewers"}},
+		},
+		{
+			name: "system groups are never kept",
+			user: authenticationv1.UserInfo{Username: "erin@example.com", Groups: []string{"system:masters", "sre"}},
+			want: rest.ImpersonationConfig{UserName: "erin@example.com", Groups: []string{"sre"}},
+		},
+		{
+			name:    "mapping of other cluster does not apply",
+			user:    authenticationv1.UserInfo{Username: "bob@example.com", Groups: []string{"system:masters"}},
+			wantErr: ErrNotMapped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Map(&tt.user, clu, mappings)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Map() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sync"
+	"time"
+)
+
+// DefaultMinRefreshInterval is the minimum interval of the refreshes of the discovery by default.
+const DefaultMinRefreshInterval = 10 * time.Second
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// DiscoveryOptions configures the discovery of the REST mapper of a cluster.
+type DiscoveryOptions struct {
+	// Store persists the discovery, it is kept in memory only if the store is nil.
+	Store Store
+	// RefreshPeriod is the period to refresh the discovery in the background, besides the refreshes
+	// on the changes of the CRDs. The discovery is not refreshed periodically if it is zero.
+	RefreshPeriod time.Duration
+	// MinRefreshInterval is the minimum interval of the refreshes, it limits the refreshes on the kinds
+	// not found and on the CRDs changed. It defaults to DefaultMinRefreshInterval.
+	MinRefreshInterval time.Duration
+}
+
+// PersistedRESTMapper is a stale-while-revalidate REST mapper of a cluster. It serves the discovery persisted
+// by the store at once, without discovering the cluster on creation, and refreshes the discovery in the background
+// while it is started. The discovery is refreshed when a kind or a resource is not found, and when the CRDs of
+// the cluster change.
+type PersistedRESTMapper struct {
+	cluster   string
+	discovery discovery.DiscoveryInterface
+	metadata  metadata.Interface
+	opts      DiscoveryOptions
+	// now returns the current time, it is replaced in the tests.
+	now func() time.Time
+
+	mu     sync.RWMutex
+	mapper meta.RESTMapper
+	// resources is the discovery served by the mapper.
+	resources []*restmapper.APIGroupResources
+	refreshed time.Time
+	// refreshMu serializes the refreshes.
+	refreshMu sync.Mutex
+	// stale is signaled to refresh the discovery in the background.
+	stale chan struct{}
+}
+
+var _ meta.RESTMapper = &PersistedRESTMapper{}
+
+// NewPersistedRESTMapper returns the REST mapper of the cluster serving the discovery persisted by the store, the cluster
+// is discovered on the first use of the mapper if there is no discovery persisted.
+func NewPersistedRESTMapper(cluster string, cfg *rest.Config, opts DiscoveryOptions) (*PersistedRESTMapper, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metadataClient, err := metadata.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newPersistedRESTMapper(cluster, discoveryClient, metadataClient, opts), nil
+}
+
+func newPersistedRESTMapper(cluster string, discoveryClient discovery.DiscoveryInterface, metadataClient metadata.Interface,
+	opts DiscoveryOptions) *PersistedRESTMapper {
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = DefaultMinRefreshInterval
+	}
+	m := &PersistedRESTMapper{
+		cluster:   cluster,
+		discovery: discoveryClient,
+		metadata:  metadataClient,
+		opts:      opts,
+		now:       time.Now,
+		stale:     make(chan struct{}, 1),
+	}
+	m.load()
+	return m
+}
+
+// load serves the discovery persisted, which is revalidated once the mapper is started.
+func (m *PersistedRESTMapper) load() {
+	if m.opts.Store == nil {
+		return
+	}
+	resources, err := m.opts.Store.Load(context.Background(), m.cluster)
+	if err != nil {
+		klog.Errorf("error loading discovery of cluster %s: %v", m.cluster, err)
+		return
+	}
+	if resources == nil {
+		return
+	}
+	m.mu.Lock()
+	m.mapper = restmapper.NewDiscoveryRESTMapper(resources)
+	m.resources = resources
+	m.mu.Unlock()
+	m.Invalidate()
+}
+
+// Invalidate marks the discovery stale, it is refreshed in the background.
+func (m *PersistedRESTMapper) Invalidate() {
+	select {
+	case m.stale <- struct{}{}:
+	default:
+	}
+}
+
+// Start refreshes the discovery in the background on the CRD changes, and every RefreshPeriod,
+// until the context is done. It can be started again after the context is done.
+func (m *PersistedRESTMapper) Start(ctx context.Context) error {
+	informer := metadatainformer.NewFilteredMetadataInformer(m.metadata, crdResource, metav1.NamespaceAll, 0,
+		cache.Indexers{}, nil).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) {
+			m.Invalidate()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, oldErr := meta.Accessor(oldObj)
+			newMeta, newErr := meta.Accessor(newObj)
+			if oldErr != nil || newErr != nil || oldMeta.GetGeneration() != newMeta.GetGeneration() {
+				m.Invalidate()
+			}
+		},
+		DeleteFunc: func(interface{}) {
+			m.Invalidate()
+		},
+	})
+	go informer.Run(ctx.Done())
+
+	var periodic <-chan time.Time
+	if m.opts.RefreshPeriod > 0 {
+		ticker := time.NewTicker(m.opts.RefreshPeriod)
+		defer ticker.Stop()
+		periodic = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.stale:
+			// debounce the changes of many CRDs, e.g. the CRDs listed on start or installed by an operator
+			if wait := m.opts.MinRefreshInterval - m.now().Sub(m.lastRefreshed()); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(wait):
+				}
+			}
+		case <-periodic:
+		}
+		if err := m.refresh(ctx); err != nil {
+			klog.Errorf("error refreshing discovery of cluster %s: %v", m.cluster, err)
+		}
+	}
+}
+
+func (m *PersistedRESTMapper) lastRefreshed() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.refreshed
+}
+
+// refresh discovers the cluster, serves the discovery and persists it.
+func (m *PersistedRESTMapper) refresh(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	resources, err := getAPIGroupResources(m.discovery)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return err
+	}
+	m.mu.Lock()
+	if failed, ok := err.(*discovery.ErrGroupDiscoveryFailed); ok {
+		resources = mergeFailed(resources, m.resources, failed.Groups)
+	}
+	m.mapper = restmapper.NewDiscoveryRESTMapper(resources)
+	m.resources = resources
+	m.refreshed = m.now()
+	m.mu.Unlock()
+	if err != nil {
+		// the discovery of some groups failed, the previous discovery of them is served but not persisted
+		return err
+	}
+	if m.opts.Store != nil {
+		if err := m.opts.Store.Save(ctx, m.cluster, resources); err != nil {
+			klog.Errorf("error saving discovery of cluster %s: %v", m.cluster, err)
+		}
+	}
+	return nil
+}
+
+// getAPIGroupResources returns the discovery of the cluster like restmapper.GetAPIGroupResources,
+// but returns the ErrGroupDiscoveryFailed of the group versions failed to discover with the others.
+func getAPIGroupResources(d discovery.DiscoveryInterface) ([]*restmapper.APIGroupResources, error) {
+	groups, lists, err := d.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	listed := make(map[string]*metav1.APIResourceList, len(lists))
+	for _, list := range lists {
+		listed[list.GroupVersion] = list
+	}
+	resources := make([]*restmapper.APIGroupResources, 0, len(groups))
+	for _, group := range groups {
+		groupResources := &restmapper.APIGroupResources{
+			Group:              *group,
+			VersionedResources: make(map[string][]metav1.APIResource),
+		}
+		for _, version := range group.Versions {
+			if list, ok := listed[version.GroupVersion]; ok {
+				groupResources.VersionedResources[version.Version] = list.APIResources
+			}
+		}
+		resources = append(resources, groupResources)
+	}
+	return resources, err
+}
+
+// mergeFailed returns the discovery with the group versions failed to discover taken from the previous
+// discovery, so a group version unavailable for a while, e.g. of an aggregated API, is still mapped.
+func mergeFailed(resources, previous []*restmapper.APIGroupResources,
+	failed map[schema.GroupVersion]error) []*restmapper.APIGroupResources {
+	groups := make(map[string]*restmapper.APIGroupResources, len(resources))
+	for _, group := range resources {
+		groups[group.Group.Name] = group
+	}
+	for _, prev := range previous {
+		for gv := range failed {
+			if gv.Group != prev.Group.Name {
+				continue
+			}
+			versioned, ok := prev.VersionedResources[gv.Version]
+			if !ok {
+				continue
+			}
+			group, ok := groups[gv.Group]
+			if !ok {
+				group = &restmapper.APIGroupResources{Group: prev.Group, VersionedResources: map[string][]metav1.APIResource{}}
+				groups[gv.Group] = group
+				resources = append(resources, group)
+			}
+			if group.VersionedResources == nil {
+				group.VersionedResources = map[string][]metav1.APIResource{}
+			}
+			if _, ok := group.VersionedResources[gv.Version]; !ok {
+				group.VersionedResources[gv.Version] = versioned
+			}
+		}
+	}
+	return resources
+}
+
+// current returns the mapper serving the discovery, the cluster is discovered if there is none.
+func (m *PersistedRESTMapper) current() (meta.RESTMapper, error) {
+	m.mu.RLock()
+	mapper := m.mapper
+	m.mu.RUnlock()
+	if mapper != nil {
+		return mapper, nil
+	}
+	if err := m.refresh(context.Background()); err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mapper, nil
+}
+
+// do calls fn with the mapper serving the discovery, and calls it again after refreshing the discovery
+// if the kind or the resource is not found, unless the discovery is refreshed within MinRefreshInterval.
+func (m *PersistedRESTMapper) do(fn func(mapper meta.RESTMapper) error) error {
+	mapper, err := m.current()
+	if err != nil {
+		return err
+	}
+	if err = fn(mapper); !meta.IsNoMatchError(err) || m.now().Sub(m.lastRefreshed()) < m.opts.MinRefreshInterval {
+		return err
+	}
+	if refreshErr := m.refresh(context.Background()); refreshErr != nil && !discovery.IsGroupDiscoveryFailedError(refreshErr) {
+		return err
+	}
+	m.mu.RLock()
+	mapper = m.mapper
+	m.mu.RUnlock()
+	return fn(mapper)
+}
+
+func (m *PersistedRESTMapper) KindFor(resource schema.GroupVersionResource) (gvk schema.GroupVersionKind, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		gvk, err = mapper.KindFor(resource)
+		return err
+	})
+	return gvk, err
+}
+
+func (m *PersistedRESTMapper) KindsFor(resource schema.GroupVersionResource) (gvks []schema.GroupVersionKind, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		gvks, err = mapper.KindsFor(resource)
+		return err
+	})
+	return gvks, err
+}
+
+func (m *PersistedRESTMapper) ResourceFor(input schema.GroupVersionResource) (gvr schema.GroupVersionResource, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		gvr, err = mapper.ResourceFor(input)
+		return err
+	})
+	return gvr, err
+}
+
+func (m *PersistedRESTMapper) ResourcesFor(input schema.GroupVersionResource) (gvrs []schema.GroupVersionResource, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		gvrs, err = mapper.ResourcesFor(input)
+		return err
+	})
+	return gvrs, err
+}
+
+func (m *PersistedRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (mapping *meta.RESTMapping, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		mapping, err = mapper.RESTMapping(gk, versions...)
+		return err
+	})
+	return mapping, err
+}
+
+func (m *PersistedRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) (mappings []*meta.RESTMapping, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		mappings, err = mapper.RESTMappings(gk, versions...)
+		return err
+	})
+	return mappings, err
+}
+
+func (m *PersistedRESTMapper) ResourceSingularizer(resource string) (singular string, err error) {
+	err = m.do(func(mapper meta.RESTMapper) error {
+		singular, err = mapper.ResourceSingularizer(resource)
+		return err
+	})
+	return singular, err
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	discoveryclient "k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakemetadata "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	podResource        = metav1.APIResource{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "Pod"}
+	deploymentResource = metav1.APIResource{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment"}
+	widgetResource     = metav1.APIResource{Name: "widgets", SingularName: "widget", Namespaced: true, Kind: "Widget"}
+)
+
+func groupResources(group, version string, resources ...metav1.APIResource) *restmapper.APIGroupResources {
+	gv := metav1.GroupVersionForDiscovery{GroupVersion: schema.GroupVersion{Group: group, Version: version}.String(),
+		Version: version}
+	return &restmapper.APIGroupResources{
+		Group:              metav1.APIGroup{Name: group, Versions: []metav1.GroupVersionForDiscovery{gv}, PreferredVersion: gv},
+		VersionedResources: map[string][]metav1.APIResource{version: resources},
+	}
+}
+
+// memoryStore is the store of the discovery in the tests.
+type memoryStore struct {
+	mu        sync.Mutex
+	resources map[string][]*restmapper.APIGroupResources
+	saves     int
+}
+
+func (s *memoryStore) Load(_ context.Context, cluster string) ([]*restmapper.APIGroupResources, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resources[cluster], nil
+}
+
+func (s *memoryStore) Save(_ context.Context, cluster string, resources []*restmapper.APIGroupResources) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[cluster] = resources
+	s.saves++
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, cluster string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.resources, cluster)
+	return nil
+}
+
+func (s *memoryStore) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func TestStores(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	stores := map[string]Store{
+		"dir":       &DirStore{Dir: t.TempDir()},
+		"configmap": &ConfigMapStore{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Namespace: "default"},
+	}
+	resources := []*restmapper.APIGroupResources{
+		groupResources("", "v1", podResource),
+		groupResources("apps", "v1", deploymentResource),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if got, err := store.Load(ctx, "a"); got != nil || err != nil {
+				t.Fatalf("Load() = %v, %v, want nothing persisted", got, err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := store.Save(ctx, "a", resources[:i+1]); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+			got, err := store.Load(ctx, "a")
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(resources)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("Load() = %s, want %s", gotJSON, wantJSON)
+			}
+			if got, _ := store.Load(ctx, "b"); got != nil {
+				t.Errorf("Load() = %v of another cluster", got)
+			}
+			for i := 0; i < 2; i++ {
+				if err := store.Delete(ctx, "a"); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+			}
+			if got, err := store.Load(ctx, "a"); got != nil || err != nil {
+				t.Errorf("Load() = %v, %v, want nothing persisted after Delete", got, err)
+			}
+		})
+	}
+}
+
+// testClock is the clock of the mapper in the tests.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeDiscovery(resources ...*metav1.APIResourceList) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: resources}}
+}
+
+func TestPersistedRESTMapper(t *testing.T) {
+	discovery := newFakeDiscovery(&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{podResource}})
+	store := &memoryStore{resources: map[string][]*restmapper.APIGroupResources{
+		"test": {groupResources("", "v1", podResource), groupResources("apps", "v1", deploymentResource)},
+	}}
+	m := newPersistedRESTMapper("test", discovery, nil, DiscoveryOptions{Store: store, MinRefreshInterval: time.Minute})
+	clock := &testClock{now: time.Now()}
+	m.now = clock.Now
+
+	// the persisted discovery is served without discovering the cluster
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	if gvk, err := m.KindFor(deployments); err != nil || gvk.Kind != "Deployment" {
+		t.Fatalf("KindFor() = %v, %v, want the persisted Deployment", gvk, err)
+	}
+	if actions := discovery.Actions(); len(actions) != 0 {
+		t.Fatalf("discovered the cluster on creation: %v", actions)
+	}
+
+	// a resource not found refreshes the discovery, which is persisted
+	widgets := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	if _, err := m.KindFor(widgets); !meta.IsNoMatchError(err) {
+		t.Fatalf("KindFor() error = %v, want no match", err)
+	}
+	if store.Saves() != 1 {
+		t.Fatalf("discovery is saved %d times, want 1", store.Saves())
+	}
+	if _, err := m.KindFor(deployments); !meta.IsNoMatchError(err) {
+		t.Errorf("KindFor() error = %v, want the deleted Deployment not found after the refresh", err)
+	}
+
+	// the refreshes on the resources not found are limited
+	discovery.Resources = append(discovery.Resources,
+		&metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{widgetResource}})
+	actions := len(discovery.Actions())
+	if _, err := m.KindFor(widgets); !meta.IsNoMatchError(err) {
+		t.Fatalf("KindFor() error = %v, want no match within the min refresh interval", err)
+	}
+	if len(discovery.Actions()) != actions {
+		t.Errorf("discovery is refreshed within the min refresh interval")
+	}
+	clock.Step(time.Minute)
+	if gvk, err := m.KindFor(widgets); err != nil || gvk.Kind != "Widget" {
+		t.Errorf("KindFor() = %v, %v, want the Widget discovered", gvk, err)
+	}
+}
+
+// partialDiscovery fails to discover the group versions.
+type partialDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	failed map[schema.GroupVersion]error
+}
+
+func (d *partialDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	groups, lists, err := d.FakeDiscovery.ServerGroupsAndResources()
+	if err != nil {
+		return nil, nil, err
+	}
+	var discovered []*metav1.APIResourceList
+	for _, list := range lists {
+		if gv, _ := schema.ParseGroupVersion(list.GroupVersion); d.failed[gv] == nil {
+			discovered = append(discovered, list)
+		}
+	}
+	return groups, discovered, &discoveryclient.ErrGroupDiscoveryFailed{Groups: d.failed}
+}
+
+func TestPersistedRESTMapper_PartialDiscovery(t *testing.T) {
+	metrics := schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+	podMetricsResource := metav1.APIResource{Name: "pods", SingularName: "pod", Namespaced: true, Kind: "PodMetrics"}
+	discovery := &partialDiscovery{
+		FakeDiscovery: newFakeDiscovery(
+			&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{podResource}},
+			&metav1.APIResourceList{GroupVersion: metrics.String(), APIResources: []metav1.APIResource{podMetricsResource}},
+			&metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{widgetResource}},
+		),
+		failed: map[schema.GroupVersion]error{metrics: errors.New("service unavailable")},
+	}
+	store := &memoryStore{resources: map[string][]*restmapper.APIGroupResources{
+		"test": {groupResources("", "v1", podResource), groupResources(metrics.Group, metrics.Version, podMetricsResource)},
+	}}
+	m := newPersistedRESTMapper("test", discovery, nil, DiscoveryOptions{Store: store})
+
+	if err := m.refresh(context.Background()); !discoveryclient.IsGroupDiscoveryFailedError(err) {
+		t.Fatalf("refresh() error = %v, want the group discovery failed", err)
+	}
+	// the group failed to discover is still mapped by the previous discovery, the others are refreshed
+	if gvk, err := m.KindFor(metrics.WithResource("pods")); err != nil || gvk.Kind != "PodMetrics" {
+		t.Errorf("KindFor() = %v, %v, want the PodMetrics discovered before", gvk, err)
+	}
+	if gvk, err := m.KindFor(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}); err != nil ||
+		gvk.Kind != "Widget" {
+		t.Errorf("KindFor() = %v, %v, want the Widget discovered", gvk, err)
+	}
+	if store.Saves() != 0 {
+		t.Errorf("partial discovery is saved %d times, want none", store.Saves())
+	}
+}
+
+func TestPersistedRESTMapper_NotPersisted(t *testing.T) {
+	discovery := newFakeDiscovery(&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{podResource}})
+	store := &memoryStore{resources: map[string][]*restmapper.APIGroupResources{}}
+	m := newPersistedRESTMapper("test", discovery, nil, DiscoveryOptions{Store: store})
+	if len(discovery.Actions()) != 0 {
+		t.Fatalf("discovered the cluster on creation")
+	}
+	mapping, err := m.RESTMapping(schema.GroupKind{Kind: "Pod"}, "v1")
+	if err != nil || mapping.Resource.Resource != "pods" {
+		t.Fatalf("RESTMapping() = %v, %v, want the Pod discovered on the first use", mapping, err)
+	}
+	if store.Saves() != 1 {
+		t.Errorf("discovery is saved %d times, want 1", store.Saves())
+	}
+}
+
+func TestPersistedRESTMapper_Start(t *testing.T) {
+	discovery := newFakeDiscovery(&metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{podResource}})
+	store := &memoryStore{resources: map[string][]*restmapper.APIGroupResources{
+		"test": {groupResources("", "v1", podResource)},
+	}}
+	scheme := runtime.NewScheme()
+	_ = metav1.AddMetaToScheme(scheme)
+	metadata := fakemetadata.NewSimpleMetadataClient(scheme)
+	m := newPersistedRESTMapper("test", discovery, metadata, DiscoveryOptions{Store: store, MinRefreshInterval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		_ = m.Start(ctx)
+		close(done)
+	}()
+	saved := func(n int) {
+		t.Helper()
+		if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			return store.Saves() >= n, nil
+		}); err != nil {
+			t.Fatalf("discovery is saved %d times, want %d", store.Saves(), n)
+		}
+	}
+	// the persisted discovery is revalidated once started
+	saved(1)
+
+	// the discovery is refreshed when a CRD is created
+	discovery.Resources = append(discovery.Resources,
+		&metav1.APIResourceList{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{widgetResource}})
+	crd := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+	}
+	if _, err := metadata.Resource(crdResource).(fakemetadata.MetadataClient).CreateFake(crd, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	saved(2)
+	m.mu.RLock()
+	mapper := m.mapper
+	m.mu.RUnlock()
+	if gvk, err := mapper.KindFor(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}); err != nil ||
+		gvk.Kind != "Widget" {
+		t.Errorf("KindFor() = %v, %v, want the Widget of the CRD created", gvk, err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() is not returned after the context is done")
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/restmapper"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Store persists the discovery of the API group resources of the member clusters.
+type Store interface {
+	// Load returns the discovery of the cluster persisted, it returns nil without an error if there is none.
+	Load(ctx context.Context, cluster string) ([]*restmapper.APIGroupResources, error)
+	// Save persists the discovery of the cluster.
+	Save(ctx context.Context, cluster string, resources []*restmapper.APIGroupResources) error
+	// Delete deletes the discovery of the cluster persisted, it returns nil if there is none.
+	Delete(ctx context.Context, cluster string) error
+}
+
+// DirStore persists the discovery of every cluster in a JSON file of the directory.
+type DirStore struct {
+	Dir string
+}
+
+func (s *DirStore) path(cluster string) string {
+	return filepath.Join(s.Dir, cluster+".json")
+}
+
+func (s *DirStore) Load(_ context.Context, cluster string) ([]*restmapper.APIGroupResources, error) {
+	data, err := os.ReadFile(s.path(cluster))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resources []*restmapper.APIGroupResources
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery of cluster %s: %s", cluster, err)
+	}
+	return resources, nil
+}
+
+// Save writes the discovery to a temporary file renamed to the file of the cluster,
+// so a discovery being saved is never loaded partially.
+func (s *DirStore) Save(_ context.Context, cluster string, resources []*restmapper.APIGroupResources) error {
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Dir, cluster+".json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(cluster))
+}
+
+func (s *DirStore) Delete(_ context.Context, cluster string) error {
+	if err := os.Remove(s.path(cluster)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+const (
+	// DiscoveryConfigMapPrefix is the name prefix of the ConfigMaps persisting the discovery of the clusters.
+	DiscoveryConfigMapPrefix = "discovery-"
+	// DiscoveryConfigMapKey is the key of the gzipped discovery in the binary data of the ConfigMaps.
+	DiscoveryConfigMapKey = "resources.json.gz"
+	// DiscoveryClusterLabel is the label of the ConfigMaps naming the cluster of the discovery.
+	DiscoveryClusterLabel = "sumengzs.cn/discovery-cluster"
+)
+
+// ConfigMapStore persists the discovery of every cluster gzipped in a ConfigMap of the namespace in the hub,
+// so the discovery is shared by the replicas of the manager.
+type ConfigMapStore struct {
+	// Client writes the ConfigMaps.
+	Client client.Client
+	// Reader reads the ConfigMaps, e.g. the API reader of the manager, so all the ConfigMaps are not cached.
+	// The ConfigMaps are read by the Client if it is nil.
+	Reader    client.Reader
+	Namespace string
+}
+
+func (s *ConfigMapStore) key(cluster string) types.NamespacedName {
+	return types.NamespacedName{Namespace: s.Namespace, Name: DiscoveryConfigMapPrefix + cluster}
+}
+
+func (s *ConfigMapStore) reader() client.Reader {
+	if s.Reader != nil {
+		return s.Reader
+	}
+	return s.Client
+}
+
+func (s *ConfigMapStore) Load(ctx context.Context, cluster string) ([]*restmapper.APIGroupResources, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.reader().Get(ctx, s.key(cluster), cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	data, ok := cm.BinaryData[DiscoveryConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress discovery of cluster %s: %s", cluster, err)
+	}
+	defer reader.Close()
+	var resources []*restmapper.APIGroupResources
+	if err := json.NewDecoder(reader).Decode(&resources); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery of cluster %s: %s", cluster, err)
+	}
+	return resources, nil
+}
+
+func (s *ConfigMapStore) Save(ctx context.Context, cluster string, resources []*restmapper.APIGroupResources) error {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(resources); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	key := s.key(cluster)
+	cm := &corev1.ConfigMap{}
+	err := s.reader().Get(ctx, key, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name,
+				Labels: map[string]string{DiscoveryClusterLabel: cluster}},
+			BinaryData: map[string][]byte{DiscoveryConfigMapKey: buf.Bytes()},
+		}
+		return s.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+	cm.BinaryData[DiscoveryConfigMapKey] = buf.Bytes()
+	return s.Client.Update(ctx, cm)
+}
+
+func (s *ConfigMapStore) Delete(ctx context.Context, cluster string) error {
+	key := s.key(cluster)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+	return client.IgnoreNotFound(s.Client.Delete(ctx, cm))
+}
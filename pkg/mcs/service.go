@@ -0,0 +1,203 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mcs
+
+import (
+	mcsv1alpha1 "github.com/sumengzs/multi-cluster/api/mcs/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sort"
+)
+
+// EndpointSliceManagedBy is the value of the managed-by label of the EndpointSlices
+// aggregated into the hub and derived into the consuming clusters, so the EndpointSlice
+// controllers of the clusters leave them alone.
+const EndpointSliceManagedBy = "multi-cluster.sumengzs.cn"
+
+// derivedPrefix is the prefix of the names of the Services and EndpointSlices
+// derived from a ServiceImport in the consuming clusters.
+const derivedPrefix = "derived-"
+
+// Export is a Service exported by a member cluster.
+type Export struct {
+	// Cluster is the name of the exporting cluster.
+	Cluster string
+	// Created is when the Service is exported.
+	Created metav1.Time
+	// Service is the exported Service.
+	Service *corev1.Service
+}
+
+// ImportSpec returns the spec of the ServiceImport of the Service exported by the clusters,
+// and the clusters whose exports conflict with it. The properties of the oldest export win,
+// as the Multi-Cluster Services API requires.
+func ImportSpec(exports []Export) (mcsv1alpha1.ServiceImportSpec, []string) {
+	exports = append([]Export(nil), exports...)
+	sort.SliceStable(exports, func(i, j int) bool {
+		if !exports[i].Created.Equal(&exports[j].Created) {
+			return exports[i].Created.Before(&exports[j].Created)
+		}
+		return exports[i].Cluster < exports[j].Cluster
+	})
+
+	var spec mcsv1alpha1.ServiceImportSpec
+	var conflicts []string
+	for i, export := range exports {
+		current := importSpec(export.Service)
+		if i == 0 {
+			spec = current
+			continue
+		}
+		if !equality.Semantic.DeepEqual(spec, current) {
+			conflicts = append(conflicts, export.Cluster)
+		}
+	}
+	return spec, conflicts
+}
+
+func importSpec(svc *corev1.Service) mcsv1alpha1.ServiceImportSpec {
+	spec := mcsv1alpha1.ServiceImportSpec{
+		Type:                  mcsv1alpha1.ClusterSetIP,
+		SessionAffinity:       svc.Spec.SessionAffinity,
+		SessionAffinityConfig: svc.Spec.SessionAffinityConfig,
+	}
+	if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		spec.Type = mcsv1alpha1.Headless
+	}
+	for _, port := range svc.Spec.Ports {
+		spec.Ports = append(spec.Ports, mcsv1alpha1.ServicePort{
+			Name:        port.Name,
+			Protocol:    port.Protocol,
+			AppProtocol: port.AppProtocol,
+			Port:        port.Port,
+		})
+	}
+	return spec
+}
+
+// HubEndpointSlice returns the EndpointSlice in the hub aggregating the EndpointSlice
+// of the Service exported by the member cluster. The references to the objects in
+// the member cluster are dropped, because they are meaningless in the other clusters.
+func HubEndpointSlice(cluster, service string, slice *discoveryv1.EndpointSlice) *discoveryv1.EndpointSlice {
+	hub := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: slice.Namespace,
+			Name:      cluster + "-" + slice.Name,
+			Labels: map[string]string{
+				mcsv1alpha1.LabelServiceName:   service,
+				mcsv1alpha1.LabelSourceCluster: cluster,
+				discoveryv1.LabelManagedBy:     EndpointSliceManagedBy,
+			},
+		},
+		AddressType: slice.AddressType,
+		Ports:       slice.Ports,
+	}
+	for _, endpoint := range slice.Endpoints {
+		endpoint := *endpoint.DeepCopy()
+		endpoint.TargetRef = nil
+		endpoint.NodeName = nil
+		hub.Endpoints = append(hub.Endpoints, endpoint)
+	}
+	return hub
+}
+
+// DerivedServiceName returns the name of the Service derived from the ServiceImport
+// in the consuming clusters, which allocates the cluster set IP of the import.
+func DerivedServiceName(service string) string {
+	return derivedPrefix + service
+}
+
+// DerivedEndpointSlice returns the EndpointSlice in a consuming cluster derived from the
+// EndpointSlice in the hub, it belongs to the derived Service so kube-proxy routes to it.
+func DerivedEndpointSlice(slice *discoveryv1.EndpointSlice) *discoveryv1.EndpointSlice {
+	service := slice.Labels[mcsv1alpha1.LabelServiceName]
+	derived := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: slice.Namespace,
+			Name:      derivedPrefix + slice.Name,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName:   DerivedServiceName(service),
+				mcsv1alpha1.LabelServiceName:   service,
+				mcsv1alpha1.LabelSourceCluster: slice.Labels[mcsv1alpha1.LabelSourceCluster],
+				discoveryv1.LabelManagedBy:     EndpointSliceManagedBy,
+			},
+		},
+		AddressType: slice.AddressType,
+	}
+	for i := range slice.Endpoints {
+		derived.Endpoints = append(derived.Endpoints, *slice.Endpoints[i].DeepCopy())
+	}
+	for i := range slice.Ports {
+		derived.Ports = append(derived.Ports, *slice.Ports[i].DeepCopy())
+	}
+	return derived
+}
+
+// DerivedServicePorts returns the ports of the Service derived from the ServiceImport,
+// defaulted as the API server does to keep the derived Service from being updated again.
+func DerivedServicePorts(spec *mcsv1alpha1.ServiceImportSpec) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, port := range spec.Ports {
+		protocol := port.Protocol
+		if len(protocol) == 0 {
+			protocol = corev1.ProtocolTCP
+		}
+		ports = append(ports, corev1.ServicePort{
+			Name:        port.Name,
+			Protocol:    protocol,
+			AppProtocol: port.AppProtocol,
+			Port:        port.Port,
+			TargetPort:  intstr.FromInt(int(port.Port)),
+		})
+	}
+	return ports
+}
+
+// SetExportCondition sets the condition of the ServiceExport, and returns true if it changes.
+// The last transition time only changes when the status of the condition changes.
+func SetExportCondition(export *mcsv1alpha1.ServiceExport, conditionType mcsv1alpha1.ServiceExportConditionType,
+	status corev1.ConditionStatus, reason, message string) bool {
+	condition := mcsv1alpha1.ServiceExportCondition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  &reason,
+		Message: &message,
+	}
+	for i := range export.Status.Conditions {
+		existing := &export.Status.Conditions[i]
+		if existing.Type != conditionType {
+			continue
+		}
+		condition.LastTransitionTime = existing.LastTransitionTime
+		if existing.Status != status {
+			now := metav1.Now()
+			condition.LastTransitionTime = &now
+		}
+		if equality.Semantic.DeepEqual(existing, &condition) {
+			return false
+		}
+		*existing = condition
+		return true
+	}
+	now := metav1.Now()
+	condition.LastTransitionTime = &now
+	export.Status.Conditions = append(export.Status.Conditions, condition)
+	return true
+}
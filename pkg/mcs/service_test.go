@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mcs
+
+import (
+	mcsv1alpha1 "github.com/sumengzs/multi-cluster/api/mcs/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newService(clusterIP string, ports ...int32) *corev1.Service {
+	svc := &corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: clusterIP}}
+	for _, port := range ports {
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Name: "http", Protocol: corev1.ProtocolTCP, Port: port})
+	}
+	return svc
+}
+
+func TestImportSpec(t *testing.T) {
+	now := time.Now()
+	older := metav1.NewTime(now.Add(-time.Hour))
+	newer := metav1.NewTime(now)
+	tests := []struct {
+		name          string
+		exports       []Export
+		wantType      mcsv1alpha1.ServiceImportType
+		wantPort      int32
+		wantConflicts []string
+	}{
+		{
+			name:     "single export",
+			exports:  []Export{{Cluster: "a", Created: newer, Service: newService("10.0.0.1", 80)}},
+			wantType: mcsv1alpha1.ClusterSetIP,
+			wantPort: 80,
+		},
+		{
+			name: "consistent exports",
+			exports: []Export{
+				{Cluster: "a", Created: newer, Service: newService("10.0.0.1", 80)},
+				{Cluster: "b", Created: older, Service: newService("10.1.0.1", 80)},
+			},
+			wantType: mcsv1alpha1.ClusterSetIP,
+			wantPort: 80,
+		},
+		{
+			name: "oldest export wins",
+			exports: []Export{
+				{Cluster: "a", Created: newer, Service: newService("10.0.0.1", 8080)},
+				{Cluster: "b", Created: older, Service: newService("10.1.0.1", 80)},
+			},
+			wantType:      mcsv1alpha1.ClusterSetIP,
+			wantPort:      80,
+			wantConflicts: []string{"a"},
+		},
+		{
+			name: "headless conflicts with cluster set ip",
+			exports: []Export{
+				{Cluster: "b", Created: older, Service: newService(corev1.ClusterIPNone, 80)},
+				{Cluster: "a", Created: older, Service: newService("10.0.0.1", 80)},
+			},
+			wantType:      mcsv1alpha1.ClusterSetIP,
+			wantPort:      80,
+			wantConflicts: []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, conflicts := ImportSpec(tt.exports)
+			if spec.Type != tt.wantType {
+				t.Errorf("ImportSpec() type = %s, want %s", spec.Type, tt.wantType)
+			}
+			if len(spec.Ports) != 1 || spec.Ports[0].Port != tt.wantPort {
+				t.Errorf("ImportSpec() ports = %v, want port %d", spec.Ports, tt.wantPort)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("ImportSpec() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestEndpointSlices(t *testing.T) {
+	nodeName := "node-1"
+	ready := true
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "nginx-abcde",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "nginx"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  []string{"10.244.0.5"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			NodeName:   &nodeName,
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: "nginx-0"},
+		}},
+	}
+
+	hub := HubEndpointSlice("member-1", "nginx", slice)
+	if hub.Name != "member-1-nginx-abcde" || hub.Namespace != "default" {
+		t.Errorf("HubEndpointSlice() name = %s/%s", hub.Namespace, hub.Name)
+	}
+	if hub.Labels[mcsv1alpha1.LabelSourceCluster] != "member-1" || hub.Labels[mcsv1alpha1.LabelServiceName] != "nginx" {
+		t.Errorf("HubEndpointSlice() labels = %v", hub.Labels)
+	}
+	if _, ok := hub.Labels[discoveryv1.LabelServiceName]; ok {
+		t.Errorf("HubEndpointSlice() belongs to a service in the hub")
+	}
+	if endpoint := hub.Endpoints[0]; endpoint.TargetRef != nil || endpoint.NodeName != nil ||
+		!reflect.DeepEqual(endpoint.Addresses, []string{"10.244.0.5"}) {
+		t.Errorf("HubEndpointSlice() endpoint = %v", endpoint)
+	}
+	if slice.Endpoints[0].TargetRef == nil {
+		t.Errorf("HubEndpointSlice() modifies the slice of the member")
+	}
+
+	derived := DerivedEndpointSlice(hub)
+	if derived.Name != "derived-member-1-nginx-abcde" {
+		t.Errorf("DerivedEndpointSlice() name = %s", derived.Name)
+	}
+	if derived.Labels[discoveryv1.LabelServiceName] != DerivedServiceName("nginx") ||
+		derived.Labels[mcsv1alpha1.LabelSourceCluster] != "member-1" ||
+		derived.Labels[discoveryv1.LabelManagedBy] != EndpointSliceManagedBy {
+		t.Errorf("DerivedEndpointSlice() labels = %v", derived.Labels)
+	}
+}
+
+func TestSetExportCondition(t *testing.T) {
+	export := &mcsv1alpha1.ServiceExport{}
+	if !SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionTrue, "Exported", "exported") {
+		t.Fatalf("SetExportCondition() = false when adding the condition")
+	}
+	transition := export.Status.Conditions[0].LastTransitionTime
+	if SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionTrue, "Exported", "exported") {
+		t.Errorf("SetExportCondition() = true when the condition does not change")
+	}
+	if !SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionTrue, "Exported", "again") {
+		t.Errorf("SetExportCondition() = false when the message changes")
+	}
+	if export.Status.Conditions[0].LastTransitionTime != transition {
+		t.Errorf("SetExportCondition() changes the transition time without a status change")
+	}
+	if !SetExportCondition(export, mcsv1alpha1.ServiceExportValid, corev1.ConditionFalse, "ServiceNotFound", "") ||
+		len(export.Status.Conditions) != 1 || export.Status.Conditions[0].Status != corev1.ConditionFalse {
+		t.Errorf("SetExportCondition() conditions = %v", export.Status.Conditions)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var statusDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "cluster", "status"),
+	"Status of the member cluster in the pool, 1 for its current status and 0 for the others.",
+	[]string{ClusterLabel, "status"}, nil,
+)
+
+// StatusCollector collects the status of the member clusters in the pool on every scrape,
+// so the clusters removed from the pool are no longer reported.
+type StatusCollector struct {
+	// States are all the statuses a cluster may be in.
+	States []string
+	// Statuses returns the current status of every cluster in the pool by its name.
+	Statuses func() map[string]string
+}
+
+var _ prometheus.Collector = &StatusCollector{}
+
+func (c *StatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- statusDesc
+}
+
+func (c *StatusCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, current := range c.Statuses() {
+		for _, state := range c.States {
+			ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, boolValue(state == current), name, state)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "multicluster"
+
+	// ClusterLabel is the label of the metrics naming the member cluster.
+	ClusterLabel = "cluster"
+)
+
+var (
+	// ClusterReady is 1 if the member cluster is reachable and has a ready node.
+	ClusterReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "ready",
+		Help:      "Whether the member cluster is reachable and has a ready node.",
+	}, []string{ClusterLabel})
+	// ClusterCacheSynced is 1 if the cache of the member cluster is synced.
+	ClusterCacheSynced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "cache_synced",
+		Help:      "Whether the cache of the member cluster is synced.",
+	}, []string{ClusterLabel})
+	// ClusterNodes is the number of nodes of the member cluster, by their state total or ready.
+	ClusterNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "nodes",
+		Help:      "Number of nodes of the member cluster by state.",
+	}, []string{ClusterLabel, "state"})
+
+	// ClusterCircuitOpen is 1 if the circuit of the clients of the member cluster is open.
+	ClusterCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "circuit_open",
+		Help:      "Whether the circuit of the clients of the member cluster is open, failing the requests fast.",
+	}, []string{ClusterLabel})
+
+	// RequestLatency is the latency of the requests to the API server of the member cluster.
+	RequestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests to the API server of the member cluster by verb.",
+		Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{ClusterLabel, "verb"})
+	// RequestErrors is the number of the failed requests to the API server of the member cluster,
+	// the code is the status code of the response, or error if there is no response.
+	RequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cluster",
+		Name:      "request_errors_total",
+		Help:      "Number of the failed requests to the API server of the member cluster by verb and status code.",
+	}, []string{ClusterLabel, "verb", "code"})
+
+	// ClustersAdded is the number of the clusters added to the pool.
+	ClustersAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "pool",
+		Name:      "clusters_added_total",
+		Help:      "Number of the clusters added to the pool.",
+	})
+	// ClustersRemoved is the number of the clusters removed from the pool.
+	ClustersRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "pool",
+		Name:      "clusters_removed_total",
+		Help:      "Number of the clusters removed from the pool.",
+	})
+	// ClustersFailed is the number of the clusters failed to be built or added to the pool.
+	ClustersFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "pool",
+		Name:      "clusters_failed_total",
+		Help:      "Number of the clusters failed to be built or added to the pool.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(ClusterReady, ClusterCacheSynced, ClusterNodes, ClusterCircuitOpen, RequestLatency,
+		RequestErrors, ClustersAdded, ClustersRemoved, ClustersFailed)
+}
+
+// RecordHealth records the health of the member cluster collected from its cache.
+func RecordHealth(cluster string, ready, synced bool, total, readyNodes int32) {
+	ClusterReady.WithLabelValues(cluster).Set(boolValue(ready))
+	ClusterCacheSynced.WithLabelValues(cluster).Set(boolValue(synced))
+	ClusterNodes.WithLabelValues(cluster, "total").Set(float64(total))
+	ClusterNodes.WithLabelValues(cluster, "ready").Set(float64(readyNodes))
+}
+
+// RecordCircuit records whether the circuit of the member cluster is open.
+func RecordCircuit(cluster string, open bool) {
+	ClusterCircuitOpen.WithLabelValues(cluster).Set(boolValue(open))
+}
+
+// Forget removes the health of the member cluster removed from the pool. The request
+// metrics are kept, as they are counters of the requests already sent to the cluster.
+func Forget(cluster string) {
+	ClusterReady.DeleteLabelValues(cluster)
+	ClusterCacheSynced.DeleteLabelValues(cluster)
+	ClusterNodes.DeleteLabelValues(cluster, "total")
+	ClusterNodes.DeleteLabelValues(cluster, "ready")
+	ClusterCircuitOpen.DeleteLabelValues(cluster)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL}
+	instrumented := InstrumentConfig("member", config)
+	if config.WrapTransport != nil {
+		t.Fatalf("InstrumentConfig() modified the config")
+	}
+	httpClient, err := rest.HTTPClientFor(instrumented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/api/v1/pods"},
+		{method: http.MethodGet, path: "/api/v1/pods?watch=true"},
+		{method: http.MethodDelete, path: "/api/v1/namespaces/default/pods/missing"},
+		{method: http.MethodDelete, path: "/api/v1/namespaces/default/pods/missing"},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, server.URL+r.path, nil)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{
+			name: "requests observed",
+			got:  float64(testutil.CollectAndCount(RequestLatency)),
+			want: 3,
+		},
+		{
+			name: "not found errors",
+			got:  testutil.ToFloat64(RequestErrors.WithLabelValues("member", http.MethodDelete, "404")),
+			want: 2,
+		},
+		{
+			name: "no errors of successful requests",
+			got:  testutil.ToFloat64(RequestErrors.WithLabelValues("member", "WATCH", "200")),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordHealth(t *testing.T) {
+	RecordHealth("edge", true, false, 3, 2)
+	if got := testutil.ToFloat64(ClusterNodes.WithLabelValues("edge", "ready")); got != 2 {
+		t.Errorf("ready nodes = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(ClusterCacheSynced.WithLabelValues("edge")); got != 0 {
+		t.Errorf("cache synced = %v, want 0", got)
+	}
+	Forget("edge")
+	if got := testutil.CollectAndCount(ClusterReady); got != 0 {
+		t.Errorf("ready metrics after Forget() = %v, want 0", got)
+	}
+}
+
+func TestStatusCollector(t *testing.T) {
+	collector := &StatusCollector{
+		States: []string{"stopped", "ready"},
+		Statuses: func() map[string]string {
+			return map[string]string{"prod": "ready"}
+		},
+	}
+	want := `
+# HELP multicluster_cluster_status Status of the member cluster in the pool, 1 for its current status and 0 for the others.
+# TYPE multicluster_cluster_status gauge
+multicluster_cluster_status{cluster="prod",status="ready"} 1
+multicluster_cluster_status{cluster="prod",status="stopped"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
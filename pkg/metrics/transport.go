@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"k8s.io/client-go/rest"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// InstrumentConfig returns a copy of the config of the member cluster whose transport records
+// the latency and the errors of the requests to its API server.
+func InstrumentConfig(cluster string, config *rest.Config) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &roundTripper{cluster: cluster, delegate: rt}
+	})
+	return config
+}
+
+type roundTripper struct {
+	cluster  string
+	delegate http.RoundTripper
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb := requestVerb(req)
+	start := time.Now()
+	resp, err := rt.delegate.RoundTrip(req)
+	RequestLatency.WithLabelValues(rt.cluster, verb).Observe(time.Since(start).Seconds())
+	switch {
+	case err != nil:
+		RequestErrors.WithLabelValues(rt.cluster, verb, "error").Inc()
+	case resp.StatusCode >= http.StatusBadRequest:
+		RequestErrors.WithLabelValues(rt.cluster, verb, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
+
+// requestVerb returns the method of the request, or WATCH for the watch requests
+// whose latency is the time to establish the watch.
+func requestVerb(req *http.Request) string {
+	if req.Method == http.MethodGet {
+		if watch, _ := strconv.ParseBool(req.URL.Query().Get("watch")); watch {
+			return "WATCH"
+		}
+	}
+	return req.Method
+}
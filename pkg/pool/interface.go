@@ -17,13 +17,38 @@ limitations under the License.
 package pool
 
 import (
+	"context"
 	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 type Interface interface {
 	cluster.Runnable
 	Add(clu cluster.Interface) error
 	Remove(name string)
+	// Enable enables the disabled cluster in the pool, and starts it if the pool is started.
+	Enable(name string) error
+	// Disable stops the cluster in the pool, and disables it until it is enabled.
+	Disable(name string) error
 	Cluster(name string) cluster.Interface
 	Clusters() map[string]cluster.Interface
+	// Tolerate returns the clusters whose NoSchedule and NoExecute taints are all tolerated by the tolerations.
+	Tolerate(ctx context.Context, tolerations []corev1.Toleration) (map[string]cluster.Interface, error)
+	// ClusterSet returns the clusters in the pool that are members of the named ClusterSet.
+	ClusterSet(ctx context.Context, name string) (map[string]cluster.Interface, error)
+	// Subscribe returns the channel of the changes of the clusters in the pool from now on,
+	// it is closed when the context is done. The changes of a cluster are delivered in order, and
+	// a slow subscriber never blocks the pool or the other subscribers. The changes of a cluster are
+	// merged once a subscriber falls far behind, e.g. an added cluster whose status is changed before
+	// the subscriber receives it is delivered as added with the latest status.
+	Subscribe(ctx context.Context) <-chan Event
+	// RateLimiter returns the rate limiter shared by all the clients of the named cluster, it changes the rate
+	// of the limiter returned before if the QPS or the burst is changed. The limiter is unlimited if the QPS
+	// is not positive.
+	RateLimiter(name string, qps float32, burst int) flowcontrol.RateLimiter
+	// ForEach calls fn for each of the clusters concurrently within the concurrency budget shared by all the
+	// callers, and returns the errors by the cluster names.
+	ForEach(ctx context.Context, clusters map[string]cluster.Interface,
+		fn func(ctx context.Context, clu cluster.Interface) error) map[string]error
 }
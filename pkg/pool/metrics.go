@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/metrics"
+)
+
+// StatusCollector returns the collector of the status of the clusters in the pool.
+func StatusCollector(p Interface) *metrics.StatusCollector {
+	var states []string
+	for _, code := range cluster.Codes() {
+		states = append(states, code.String())
+	}
+	return &metrics.StatusCollector{
+		States: states,
+		Statuses: func() map[string]string {
+			statuses := make(map[string]string)
+			for name, clu := range p.Clusters() {
+				statuses[name] = clu.Status().String()
+			}
+			return statuses
+		},
+	}
+}
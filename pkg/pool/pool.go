@@ -19,8 +19,16 @@ package pool
 import (
 	"context"
 	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
 	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/metrics"
+	"github.com/sumengzs/multi-cluster/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sync"
 )
@@ -29,9 +37,21 @@ type Pool struct {
 	mu       sync.RWMutex
 	client   client.Client
 	clusters map[string]cluster.Interface
+	recorder record.EventRecorder
+
+	members     map[string]*membership
+	subscribers subscribers
+	// ctx is the context the pool is started with, the clusters are started with it.
+	ctx context.Context
+
+	limiters rateLimiters
+	// budget limits the calls of the pool-wide operations running at the same time, nil is unlimited.
+	budget chan struct{}
 }
 
-func New(config *rest.Config) (Interface, error) {
+// New returns a new pool, the recorder records the clusters added to and removed from the pool
+// as events on their Cluster objects, it may be nil.
+func New(config *rest.Config, recorder record.EventRecorder, opts ...Option) (Interface, error) {
 	clusters := make(map[string]cluster.Interface)
 	config = rest.AddUserAgent(config, UserAgentName)
 
@@ -42,23 +62,44 @@ func New(config *rest.Config) (Interface, error) {
 		return nil, err
 	}
 
-	return &Pool{
+	p := &Pool{
 		client:   cli,
 		clusters: clusters,
-	}, nil
+		recorder: recorder,
+		members:  make(map[string]*membership),
+		budget:   make(chan struct{}, DefaultConcurrency),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
 }
 
+// Start starts the clusters in the pool except the disabled ones, and the clusters added or enabled later,
+// it blocks until the context is done and stops the clusters then.
 func (p *Pool) Start(ctx context.Context) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	p.ctx = ctx
 	for _, clu := range p.clusters {
+		if clu.Status() == cluster.Disabled {
+			continue
+		}
 		if err := clu.Start(ctx); err != nil {
+			p.mu.Unlock()
 			return err
 		}
 	}
+	p.mu.Unlock()
+	<-ctx.Done()
+	p.Stop()
 	return nil
 }
 
+// NeedLeaderElection implements the LeaderElectionRunnable, the clusters are started in every replica.
+func (p *Pool) NeedLeaderElection() bool {
+	return false
+}
+
 func (p *Pool) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -73,20 +114,64 @@ func (p *Pool) Add(clu cluster.Interface) error {
 	if oldC, ok := p.clusters[clu.Name()]; ok {
 		switch oldC.Status() {
 		case cluster.Started, cluster.Ready, cluster.Waiting:
-			return fmt.Errorf("%s,can not replace", clu)
+			err := fmt.Errorf("%s,can not replace", clu)
+			metrics.ClustersFailed.Inc()
+			p.event(clu.Name(), corev1.EventTypeWarning, v1beta1.ReasonClusterAddFailed, err.Error())
+			return err
 		}
 	}
 	p.clusters[clu.Name()] = clu
+	p.join(clu)
+	if p.ctx != nil && clu.Status() == cluster.Stopped {
+		if err := clu.Start(p.ctx); err != nil {
+			klog.Errorf("error starting cluster %s: %v", clu.Name(), err)
+		}
+	}
+	metrics.ClustersAdded.Inc()
+	p.event(clu.Name(), corev1.EventTypeNormal, v1beta1.ReasonClusterAdded, fmt.Sprintf("cluster %s is added to the pool", clu.Name()))
 	return nil
 }
 
 func (p *Pool) Remove(name string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if _, ok := p.clusters[name]; ok {
-		p.clusters[name].Stop()
+	if clu, ok := p.clusters[name]; ok {
+		clu.Stop()
 		delete(p.clusters, name)
+		p.leave(name)
+		p.limiters.remove(name)
+		p.subscribers.publish(Event{Type: Removed, Cluster: name, Old: clu.Status(), New: clu.Status()})
+		metrics.ClustersRemoved.Inc()
+		metrics.Forget(name)
+		p.event(name, corev1.EventTypeNormal, v1beta1.ReasonClusterRemoved, fmt.Sprintf("cluster %s is removed from the pool", name))
+	}
+}
+
+func (p *Pool) Enable(name string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	clu, ok := p.clusters[name]
+	if !ok {
+		return fmt.Errorf("cluster %s is not in the pool", name)
+	}
+	if err := clu.Enable(); err != nil {
+		return err
+	}
+	if p.ctx == nil {
+		return nil
+	}
+	return clu.Start(p.ctx)
+}
+
+func (p *Pool) Disable(name string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	clu, ok := p.clusters[name]
+	if !ok {
+		return fmt.Errorf("cluster %s is not in the pool", name)
 	}
+	clu.Disable()
+	return nil
 }
 
 func (p *Pool) Cluster(name string) cluster.Interface {
@@ -104,3 +189,77 @@ func (p *Pool) Clusters() map[string]cluster.Interface {
 	}
 	return clusters
 }
+
+func (p *Pool) Tolerate(ctx context.Context, tolerations []corev1.Toleration) (map[string]cluster.Interface, error) {
+	list := &v1beta1.ClusterList{}
+	if err := p.client.List(ctx, list); err != nil {
+		return nil, err
+	}
+	clusters := p.Clusters()
+	for _, item := range list.Items {
+		_, untolerated := utils.FindUntoleratedTaint(item.Spec.Taints, tolerations,
+			corev1.TaintEffectNoSchedule, corev1.TaintEffectNoExecute)
+		if untolerated {
+			delete(clusters, item.Name)
+		}
+	}
+	return clusters, nil
+}
+
+func (p *Pool) ClusterSet(ctx context.Context, name string) (map[string]cluster.Interface, error) {
+	set := &v1beta1.ClusterSet{}
+	if err := p.client.Get(ctx, types.NamespacedName{Name: name}, set); err != nil {
+		return nil, err
+	}
+	list := &v1beta1.ClusterList{}
+	if err := p.client.List(ctx, list); err != nil {
+		return nil, err
+	}
+	members, err := utils.ResolveClusterSet(set, list.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cluster set %s: %s", name, err)
+	}
+	clusters := make(map[string]cluster.Interface, len(members))
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, member := range members {
+		if clu, ok := p.clusters[member.Name]; ok {
+			clusters[member.Name] = clu
+		}
+	}
+	return clusters, nil
+}
+
+// event records the event on the Cluster object of the named cluster.
+func (p *Pool) event(name, eventType, reason, message string) {
+	if p.recorder == nil {
+		return
+	}
+	p.recorder.Event(&v1beta1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}, eventType, reason, message)
+}
+
+func (p *Pool) Subscribe(ctx context.Context) <-chan Event {
+	return p.subscribers.subscribe(ctx)
+}
+
+// join publishes the cluster added to the pool and the changes of its status while it is in the pool,
+// it replaces the membership of the previous cluster of the name.
+func (p *Pool) join(clu cluster.Interface) {
+	p.leave(clu.Name())
+	member := &membership{active: true}
+	p.members[clu.Name()] = member
+	name := clu.Name()
+	clu.OnStatusChange(func(old, new cluster.Code) {
+		if member.isActive() {
+			p.subscribers.publish(Event{Type: StatusChanged, Cluster: name, Old: old, New: new})
+		}
+	})
+	p.subscribers.publish(Event{Type: Added, Cluster: name, Old: clu.Status(), New: clu.Status()})
+}
+
+func (p *Pool) leave(name string) {
+	if member, ok := p.members[name]; ok {
+		member.deactivate()
+		delete(p.members, name)
+	}
+}
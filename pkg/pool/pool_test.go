@@ -0,0 +1,470 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCluster struct {
+	cluster.Interface
+	name string
+
+	mu       sync.Mutex
+	status   cluster.Code
+	handlers []cluster.StatusHandler
+	cache    cache.Cache
+}
+
+func (c *fakeCluster) Name() string { return c.name }
+
+func (c *fakeCluster) Status() cluster.Code {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *fakeCluster) OnStatusChange(handler cluster.StatusHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *fakeCluster) setStatus(code cluster.Code) {
+	c.mu.Lock()
+	old := c.status
+	c.status = code
+	handlers := c.handlers
+	c.mu.Unlock()
+	for _, handler := range handlers {
+		handler(old, code)
+	}
+}
+
+func (c *fakeCluster) Start(context.Context) error {
+	if status := c.Status(); status == cluster.Disabled {
+		return &cluster.TransitionError{Cluster: c.name, From: status, To: cluster.Started}
+	}
+	if c.Status() == cluster.Stopped {
+		c.setStatus(cluster.Started)
+	}
+	return nil
+}
+
+func (c *fakeCluster) Enable() error {
+	if c.Status() == cluster.Disabled {
+		c.setStatus(cluster.Stopped)
+	}
+	return nil
+}
+
+func (c *fakeCluster) Disable() {
+	c.Stop()
+	if c.Status() != cluster.Disabled {
+		c.setStatus(cluster.Disabled)
+	}
+}
+
+func (c *fakeCluster) Cache() cache.Cache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache
+}
+
+func (c *fakeCluster) Stop() {
+	if c.Status() > cluster.Stopped {
+		c.setStatus(cluster.Stopped)
+	}
+}
+
+func newTestPool() *Pool {
+	return &Pool{clusters: make(map[string]cluster.Interface), members: make(map[string]*membership)}
+}
+
+func receive(t *testing.T, events <-chan Event, n int) []Event {
+	var got []Event
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("channel closed after %d events", len(got))
+			}
+			got = append(got, event)
+		case <-timeout:
+			t.Fatalf("received %d events, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestPool_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newTestPool()
+	events := p.Subscribe(ctx)
+
+	old := &fakeCluster{name: "a", status: cluster.Stopped}
+	if err := p.Add(old); err != nil {
+		t.Fatal(err)
+	}
+	// the status changes of a replaced cluster are no longer published
+	replacement := &fakeCluster{name: "a", status: cluster.Stopped}
+	if err := p.Add(replacement); err != nil {
+		t.Fatal(err)
+	}
+	_ = old.Start(ctx)
+	_ = replacement.Start(ctx)
+	p.Remove("a")
+
+	want := []Event{
+		{Type: Added, Cluster: "a", Old: cluster.Stopped, New: cluster.Stopped},
+		{Type: Added, Cluster: "a", Old: cluster.Stopped, New: cluster.Stopped},
+		{Type: StatusChanged, Cluster: "a", Old: cluster.Stopped, New: cluster.Started},
+		{Type: StatusChanged, Cluster: "a", Old: cluster.Started, New: cluster.Stopped},
+		{Type: Removed, Cluster: "a", Old: cluster.Stopped, New: cluster.Stopped},
+	}
+	if got := receive(t, events, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Subscribe() got = %v, want %v", got, want)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Subscribe() received an event after the context is done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Subscribe() channel is not closed after the context is done")
+	}
+}
+
+func TestPool_SubscribeSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newTestPool()
+	slow := p.Subscribe(ctx)
+	fast := p.Subscribe(ctx)
+
+	const n = 1000
+	clusters := make([]*fakeCluster, n)
+	for i := range clusters {
+		clusters[i] = &fakeCluster{name: fmt.Sprintf("member-%d", i), status: cluster.Stopped}
+	}
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		var wg sync.WaitGroup
+		for _, clu := range clusters {
+			if err := p.Add(clu); err != nil {
+				t.Error(err)
+			}
+			wg.Add(1)
+			go func(clu *fakeCluster) {
+				defer wg.Done()
+				_ = clu.Start(ctx)
+			}(clu)
+		}
+		wg.Wait()
+	}()
+
+	// the pool and the fast subscriber are not blocked by the slow subscriber that receives nothing yet
+	receiveStarted(t, fast, n)
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("publishing is blocked by the slow subscriber")
+	}
+
+	// the slow subscriber receives the events later, merged if it fell far behind
+	receiveStarted(t, slow, n)
+}
+
+func TestSubscriber_Merge(t *testing.T) {
+	sub := newSubscriber()
+	for i := 0; i < queueLimit; i++ {
+		sub.publish(Event{Type: Added, Cluster: fmt.Sprintf("member-%d", i), Old: cluster.Stopped, New: cluster.Stopped})
+	}
+	sub.publish(Event{Type: StatusChanged, Cluster: "member-0", Old: cluster.Stopped, New: cluster.Started})
+	sub.publish(Event{Type: StatusChanged, Cluster: "member-1", Old: cluster.Stopped, New: cluster.Started})
+	sub.publish(Event{Type: StatusChanged, Cluster: "member-1", Old: cluster.Started, New: cluster.Waiting})
+	sub.publish(Event{Type: Removed, Cluster: "member-2", Old: cluster.Stopped, New: cluster.Stopped})
+	sub.publish(Event{Type: Added, Cluster: "new", Old: cluster.Stopped, New: cluster.Stopped})
+	sub.publish(Event{Type: StatusChanged, Cluster: "new", Old: cluster.Stopped, New: cluster.Started})
+	sub.publish(Event{Type: StatusChanged, Cluster: "new", Old: cluster.Started, New: cluster.Ready})
+
+	if len(sub.queue) != queueLimit+1 {
+		t.Fatalf("queued %d events, want %d", len(sub.queue), queueLimit+1)
+	}
+	want := []Event{
+		{Type: Added, Cluster: "member-0", Old: cluster.Started, New: cluster.Started},
+		{Type: Added, Cluster: "member-1", Old: cluster.Waiting, New: cluster.Waiting},
+		{Type: Removed, Cluster: "member-2", Old: cluster.Stopped, New: cluster.Stopped},
+	}
+	if got := sub.queue[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged events = %v, want %v", got, want)
+	}
+	if got, want := sub.queue[queueLimit], (Event{Type: Added, Cluster: "new", Old: cluster.Ready, New: cluster.Ready}); got != want {
+		t.Errorf("merged event = %v, want %v", got, want)
+	}
+}
+
+// receiveStarted receives the events until the n clusters are started, each cluster added before
+// it is changed, and returns the number of the received events.
+func receiveStarted(t *testing.T, events <-chan Event, n int) int {
+	statuses := make(map[string]cluster.Code)
+	var received int
+	timeout := time.After(5 * time.Second)
+	for started := 0; started < n; {
+		select {
+		case event := <-events:
+			received++
+			if _, ok := statuses[event.Cluster]; !ok && event.Type != Added {
+				t.Fatalf("cluster %s is changed before it is added", event.Cluster)
+			}
+			if statuses[event.Cluster] != cluster.Started && event.New == cluster.Started {
+				started++
+			}
+			statuses[event.Cluster] = event.New
+		case <-timeout:
+			t.Fatalf("received %d events, want %d clusters started", received, n)
+		}
+	}
+	return received
+}
+
+func TestPool_EnableDisable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newTestPool()
+	disabled := &fakeCluster{name: "disabled", status: cluster.Disabled}
+	if err := p.Add(disabled); err != nil {
+		t.Fatal(err)
+	}
+	events := p.Subscribe(ctx)
+	stopped := make(chan error)
+	go func() { stopped <- p.Start(ctx) }()
+
+	// the clusters added to the started pool are started
+	added := &fakeCluster{name: "added", status: cluster.Stopped}
+	if err := p.Add(added); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, events, 2); got[1] != (Event{Type: StatusChanged, Cluster: "added", Old: cluster.Stopped, New: cluster.Started}) {
+		t.Fatalf("events = %v, want added cluster started", got)
+	}
+	if status := disabled.Status(); status != cluster.Disabled {
+		t.Errorf("disabled cluster is %s", status)
+	}
+
+	steps := []struct {
+		name   string
+		toggle func() error
+		want   cluster.Code
+	}{
+		{name: "enable", toggle: func() error { return p.Enable("disabled") }, want: cluster.Started},
+		{name: "disable", toggle: func() error { return p.Disable("disabled") }, want: cluster.Disabled},
+		{name: "enable again", toggle: func() error { return p.Enable("disabled") }, want: cluster.Started},
+	}
+	for _, step := range steps {
+		if err := step.toggle(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if status := disabled.Status(); status != step.want {
+			t.Errorf("%s: cluster is %s, want %s", step.name, status, step.want)
+		}
+	}
+	if err := p.Enable("missing"); err == nil {
+		t.Errorf("Enable() of missing cluster error = nil")
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	for _, clu := range []*fakeCluster{disabled, added} {
+		if status := clu.Status(); status != cluster.Stopped {
+			t.Errorf("cluster %s is %s after the pool is stopped", clu.name, status)
+		}
+	}
+}
+
+func TestWatcher_Restart(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool()
+	clu := &fakeCluster{name: "member", status: cluster.Stopped, cache: &informertest.FakeInformers{Scheme: scheme.Scheme}}
+	if err := p.Add(clu); err != nil {
+		t.Fatal(err)
+	}
+	watcher := NewWatcher(p)
+	var handled []string
+	watch := func() {
+		if err := watcher.Watch(ctx, clu, corev1.SchemeGroupVersion.WithKind("Pod"), &corev1.Pod{},
+			func(obj metav1.Object) { handled = append(handled, obj.GetName()) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(name string) {
+		informer, err := clu.Cache().(*informertest.FakeInformers).FakeInformerFor(&corev1.Pod{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		informer.Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	watch()
+	watch()
+	add("first")
+	// the restarted cluster has a new cache, whose informer is watched again
+	clu.mu.Lock()
+	clu.cache = &informertest.FakeInformers{Scheme: scheme.Scheme}
+	clu.mu.Unlock()
+	watch()
+	add("second")
+	if !reflect.DeepEqual(handled, []string{"first", "second"}) {
+		t.Errorf("handled = %v, want each pod once", handled)
+	}
+}
+
+func TestPool_RateLimiter(t *testing.T) {
+	p := newTestPool()
+	limiter := p.RateLimiter("a", 1, 1)
+	if !limiter.TryAccept() || limiter.TryAccept() {
+		t.Fatalf("limiter of burst 1 accepted the requests wrongly")
+	}
+	if p.RateLimiter("a", 1, 1) != limiter {
+		t.Fatalf("RateLimiter() returned another limiter of the cluster")
+	}
+	if p.RateLimiter("a", 1, 1).TryAccept() {
+		t.Errorf("limiter of the unchanged rate is refilled")
+	}
+	if p.RateLimiter("b", 1, 1) == limiter {
+		t.Errorf("RateLimiter() shared the limiter of another cluster")
+	}
+
+	p.RateLimiter("a", 100, 3)
+	if qps := limiter.QPS(); qps != 100 {
+		t.Errorf("QPS() = %v, want 100 after the rate is changed", qps)
+	}
+	for i := 0; i < 3; i++ {
+		if !limiter.TryAccept() {
+			t.Fatalf("limiter of burst 3 rejected request %d", i)
+		}
+	}
+
+	// the limiter accepts all the requests while it is unlimited
+	p.RateLimiter("a", 0, 0)
+	for i := 0; i < 10; i++ {
+		if !limiter.TryAccept() {
+			t.Fatalf("unlimited limiter rejected request %d", i)
+		}
+	}
+	p.RateLimiter("a", 1, 1)
+	if !limiter.TryAccept() || limiter.TryAccept() {
+		t.Errorf("limiter of burst 1 accepted the requests wrongly after it is limited again")
+	}
+
+	p.clusters["a"] = &fakeCluster{name: "a", status: cluster.Stopped}
+	p.Remove("a")
+	if p.RateLimiter("a", 100, 3) == limiter {
+		t.Errorf("limiter of the removed cluster is kept")
+	}
+}
+
+func TestPool_ForEach(t *testing.T) {
+	clusters := make(map[string]cluster.Interface)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("c%d", i)
+		clusters[name] = &fakeCluster{name: name, status: cluster.Ready}
+	}
+	p := newTestPool()
+	WithConcurrency(3)(p)
+
+	var mu sync.Mutex
+	var running, peak int
+	errs := p.ForEach(context.Background(), clusters, func(ctx context.Context, clu cluster.Interface) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if clu.Name() == "c0" {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	})
+	if peak > 3 {
+		t.Errorf("%d calls ran at the same time, want at most 3", peak)
+	}
+	if len(errs) != 1 || errs["c0"] == nil {
+		t.Errorf("ForEach() = %v, want the error of c0", errs)
+	}
+
+	// the calls waiting for the budget fail when the context is done
+	for i := 0; i < 3; i++ {
+		p.budget <- struct{}{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs = p.ForEach(ctx, clusters, func(ctx context.Context, clu cluster.Interface) error {
+		t.Errorf("fn called without the budget")
+		return nil
+	})
+	if len(errs) != len(clusters) || errs["c1"] != context.Canceled {
+		t.Errorf("ForEach() = %v, want context canceled for all the clusters", errs)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := &Queue{}
+	key := types.NamespacedName{Namespace: "default", Name: "nginx"}
+	// the requests before the controller is started are dropped
+	q.Add(key)
+
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	if err := q.Start(context.Background(), nil, queue); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		q.Add(key)
+	}
+	if queue.Len() != 1 {
+		t.Fatalf("queue length = %d, want the requests of the object merged", queue.Len())
+	}
+	if item, _ := queue.Get(); item != (reconcile.Request{NamespacedName: key}) {
+		t.Errorf("queued %v, want the request of %s", item, key)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"k8s.io/client-go/util/flowcontrol"
+	"sync"
+)
+
+// DefaultConcurrency is the default concurrency budget of the pool-wide operations.
+const DefaultConcurrency = 16
+
+// Option configures the pool.
+type Option func(p *Pool)
+
+// WithConcurrency limits the calls running at the same time of the pool-wide operations of all the callers,
+// it is unlimited if n is not positive.
+func WithConcurrency(n int) Option {
+	return func(p *Pool) {
+		p.budget = nil
+		if n > 0 {
+			p.budget = make(chan struct{}, n)
+		}
+	}
+}
+
+// limiter is the token bucket rate limiter of a member cluster, its bucket is replaced when the QPS or the
+// burst is changed, so the clients holding it are limited by the new rate at once. It accepts all the requests
+// while the QPS is not positive.
+type limiter struct {
+	mu     sync.RWMutex
+	bucket flowcontrol.RateLimiter
+	qps    float32
+	burst  int
+}
+
+func (l *limiter) current() flowcontrol.RateLimiter {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.bucket
+}
+
+func (l *limiter) set(qps float32, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.bucket != nil && l.qps == qps && l.burst == burst {
+		return
+	}
+	l.qps, l.burst = qps, burst
+	if qps <= 0 {
+		l.bucket = flowcontrol.NewFakeAlwaysRateLimiter()
+		return
+	}
+	l.bucket = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+}
+
+func (l *limiter) TryAccept() bool {
+	return l.current().TryAccept()
+}
+
+func (l *limiter) Accept() {
+	l.current().Accept()
+}
+
+func (l *limiter) Stop() {
+	l.current().Stop()
+}
+
+func (l *limiter) QPS() float32 {
+	return l.current().QPS()
+}
+
+func (l *limiter) Wait(ctx context.Context) error {
+	return l.current().Wait(ctx)
+}
+
+// rateLimiters are the rate limiters of the member clusters, they outlive the clusters rebuilt with the same name,
+// so rebuilding a cluster does not refill its bucket.
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+func (r *rateLimiters) get(name string, qps float32, burst int) flowcontrol.RateLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiters == nil {
+		r.limiters = make(map[string]*limiter)
+	}
+	l, ok := r.limiters[name]
+	if !ok {
+		l = &limiter{}
+		r.limiters[name] = l
+	}
+	l.set(qps, burst)
+	return l
+}
+
+func (r *rateLimiters) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.limiters, name)
+}
+
+// RateLimiter returns the rate limiter shared by all the clients of the named cluster, the rate of the limiter
+// returned before is changed if the QPS or the burst is changed. The limiter is unlimited if the QPS is not positive.
+func (p *Pool) RateLimiter(name string, qps float32, burst int) flowcontrol.RateLimiter {
+	return p.limiters.get(name, qps, burst)
+}
+
+// ForEach calls fn for each of the clusters concurrently, and returns the errors by the cluster names. The calls
+// of all the callers share the concurrency budget of the pool, so fn must not call ForEach. The calls waiting for
+// the budget fail with the error of the context when it is done.
+func (p *Pool) ForEach(ctx context.Context, clusters map[string]cluster.Interface,
+	fn func(ctx context.Context, clu cluster.Interface) error) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	for name, clu := range clusters {
+		name, clu := name, clu
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.acquire(ctx)
+			if err == nil {
+				defer p.release()
+				err = fn(ctx, clu)
+			}
+			if err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func (p *Pool) acquire(ctx context.Context) error {
+	if p.budget == nil {
+		return nil
+	}
+	select {
+	case p.budget <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	if p.budget != nil {
+		<-p.budget
+	}
+}
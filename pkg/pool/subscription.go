@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"sync"
+)
+
+// EventType is the type of the change of a cluster in the pool.
+type EventType string
+
+const (
+	// Added means the cluster is added to the pool.
+	Added EventType = "Added"
+	// Removed means the cluster is removed from the pool.
+	Removed EventType = "Removed"
+	// StatusChanged means the status of the cluster in the pool is changed.
+	StatusChanged EventType = "StatusChanged"
+)
+
+// Event is a change of a cluster in the pool. Old and New are the status of the cluster
+// before and after the change, they are the same for the added and removed clusters.
+type Event struct {
+	Type    EventType
+	Cluster string
+	Old     cluster.Code
+	New     cluster.Code
+}
+
+// queueLimit is the number of events a subscriber may fall behind before the events
+// of the clusters with pending events are merged into them.
+const queueLimit = 1024
+
+// subscriber delivers the events to a subscription in order, so publishing never blocks on a slow
+// subscriber. Once queueLimit events are queued, a new event of a cluster with a pending event is
+// merged into it instead of queued, so the queue holds at most queueLimit events and one per cluster.
+type subscriber struct {
+	mu    sync.Mutex
+	queue []Event
+	// pending are the indexes of the last queued events of the clusters.
+	pending map[string]int
+	signal  chan struct{}
+	out     chan Event
+}
+
+func newSubscriber() *subscriber {
+	return &subscriber{
+		pending: make(map[string]int),
+		signal:  make(chan struct{}, 1),
+		out:     make(chan Event),
+	}
+}
+
+func (s *subscriber) publish(event Event) {
+	s.mu.Lock()
+	if i, ok := s.pending[event.Cluster]; ok && len(s.queue) >= queueLimit {
+		s.queue[i] = merge(s.queue[i], event)
+	} else {
+		s.pending[event.Cluster] = len(s.queue)
+		s.queue = append(s.queue, event)
+	}
+	s.mu.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// merge returns the event of the cluster with the changes of both the pending and the later event.
+// An added cluster whose status is changed later is delivered as added with the latest status, and
+// the cluster added or removed later is delivered as the later event.
+func merge(pending, later Event) Event {
+	if later.Type != StatusChanged {
+		return later
+	}
+	switch pending.Type {
+	case Added:
+		return Event{Type: Added, Cluster: later.Cluster, Old: later.New, New: later.New}
+	case StatusChanged:
+		return Event{Type: StatusChanged, Cluster: later.Cluster, Old: pending.Old, New: later.New}
+	default:
+		return later
+	}
+}
+
+// run delivers the queued events until the context is done, then closes the channel.
+func (s *subscriber) run(ctx context.Context) {
+	defer close(s.out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.signal:
+		}
+		s.mu.Lock()
+		events := s.queue
+		s.queue = nil
+		s.pending = make(map[string]int)
+		s.mu.Unlock()
+		for _, event := range events {
+			select {
+			case <-ctx.Done():
+				return
+			case s.out <- event:
+			}
+		}
+	}
+}
+
+// subscribers fans out the events to all the subscriptions.
+type subscribers struct {
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+}
+
+func (s *subscribers) subscribe(ctx context.Context) <-chan Event {
+	sub := newSubscriber()
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[*subscriber]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	go func() {
+		sub.run(ctx)
+		s.mu.Lock()
+		delete(s.subs, sub)
+		s.mu.Unlock()
+	}()
+	return sub.out
+}
+
+func (s *subscribers) publish(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for sub := range s.subs {
+		sub.publish(event)
+	}
+}
+
+// membership is the membership of a cluster in the pool, the status changes of the
+// cluster are published while it is active.
+type membership struct {
+	mu     sync.RWMutex
+	active bool
+}
+
+func (m *membership) isActive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active
+}
+
+func (m *membership) deactivate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = false
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sync"
+	"time"
+)
+
+// InformerSyncTimeout is the timeout to wait for the informer of a member cluster to sync.
+const InformerSyncTimeout = 30 * time.Second
+
+// Watcher registers event handlers to the informers in the caches of the
+// member clusters in the pool, once per cache and kind. A member cluster restarted
+// has a new cache, whose informers are watched again.
+type Watcher struct {
+	pool Interface
+
+	mu sync.Mutex
+	// watched records the kinds watched in every cache of the member clusters.
+	watched map[cache.Cache]*watchedCache
+}
+
+type watchedCache struct {
+	cluster string
+	kinds   map[schema.GroupVersionKind]bool
+}
+
+// NewWatcher returns a Watcher of the member clusters in the pool.
+func NewWatcher(p Interface) *Watcher {
+	return &Watcher{
+		pool:    p,
+		watched: make(map[cache.Cache]*watchedCache),
+	}
+}
+
+// Watch registers the handler to the informer of the kind in the cache of the member cluster
+// once, obj is an empty object of the kind. The handler is called with the object of every
+// event, including the deleted ones.
+func (w *Watcher) Watch(ctx context.Context, clu cluster.Interface, gvk schema.GroupVersionKind,
+	obj client.Object, handler func(obj metav1.Object)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	memberCache := clu.Cache()
+	if w.watched[memberCache] != nil && w.watched[memberCache].kinds[gvk] {
+		return nil
+	}
+	// forget the caches of the clusters removed, replaced or restarted in the pool
+	for watchedCache, watched := range w.watched {
+		if current := w.pool.Cluster(watched.cluster); current == nil || current.Cache() != watchedCache {
+			delete(w.watched, watchedCache)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, InformerSyncTimeout)
+	defer cancel()
+	informer, err := memberCache.GetInformer(ctx, obj)
+	if err != nil {
+		return err
+	}
+	handle := func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if accessor, err := meta.Accessor(obj); err == nil {
+			handler(accessor)
+		}
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, obj interface{}) { handle(obj) },
+		DeleteFunc: handle,
+	})
+	if w.watched[memberCache] == nil {
+		w.watched[memberCache] = &watchedCache{cluster: clu.Name(), kinds: make(map[schema.GroupVersionKind]bool)}
+	}
+	w.watched[memberCache].kinds[gvk] = true
+	return nil
+}
+
+// Queue is the source of a controller reconciling the objects in the hub changed in the member clusters.
+// The handlers of the Watcher add the requests straight into the work queue of the controller, so they
+// never block the informers of the members, and the requests of an object are merged while it is queued.
+type Queue struct {
+	mu    sync.RWMutex
+	queue workqueue.RateLimitingInterface
+}
+
+// Start records the work queue of the controller, it implements source.Source.
+func (q *Queue) Start(_ context.Context, _ handler.EventHandler, queue workqueue.RateLimitingInterface,
+	_ ...predicate.Predicate) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queue = queue
+	return nil
+}
+
+// Add adds the request of the object to the work queue. It is dropped before the controller is started,
+// as the controller reconciles all the objects once it is started.
+func (q *Queue) Add(key types.NamespacedName) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.queue != nil {
+		q.queue.Add(reconcile.Request{NamespacedName: key})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// FieldManager is the field manager used to apply the resources to the member clusters.
+const FieldManager = "multi-cluster"
+
+// BindingName returns the name of the ResourceBinding of the resource.
+func BindingName(name, kind string) string {
+	return strings.ToLower(name + "-" + kind)
+}
+
+// Templates returns the resources in the namespace selected by the selectors. Only the namespaced resources
+// can be selected, as the policy is namespaced and must not propagate the resources out of its namespace.
+func Templates(ctx context.Context, reader client.Reader, mapper meta.RESTMapper, namespace string,
+	selectors []v1beta1.ResourceSelector) ([]*unstructured.Unstructured, error) {
+	var templates []*unstructured.Unstructured
+	for _, rs := range selectors {
+		gvk := schema.FromAPIVersionAndKind(rs.APIVersion, rs.Kind)
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rest mapping of %s: %s", gvk, err)
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return nil, fmt.Errorf("%s is not namespaced, only namespaced resources can be propagated", gvk.Kind)
+		}
+		if len(rs.Name) != 0 {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(gvk)
+			if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: rs.Name}, obj); err != nil {
+				return nil, fmt.Errorf("failed to get %s %s: %s", gvk.Kind, rs.Name, err)
+			}
+			templates = append(templates, obj)
+			continue
+		}
+
+		selector := labels.Everything()
+		if rs.LabelSelector != nil {
+			if selector, err = metav1.LabelSelectorAsSelector(rs.LabelSelector); err != nil {
+				return nil, err
+			}
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := reader.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %s", gvk.Kind, err)
+		}
+		for i := range list.Items {
+			templates = append(templates, &list.Items[i])
+		}
+	}
+	return templates, nil
+}
+
+// Prepare returns a copy of the template that can be applied to the member clusters,
+// the fields managed by the hub api server and the status are removed, so are the fields of the services
+// and the jobs allocated by the hub.
+func Prepare(template *unstructured.Unstructured) *unstructured.Unstructured {
+	obj := template.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "status")
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetSelfLink("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetDeletionTimestamp(nil)
+	obj.SetDeletionGracePeriodSeconds(nil)
+	obj.SetManagedFields(nil)
+	obj.SetOwnerReferences(nil)
+	obj.SetFinalizers(nil)
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		prepareService(obj)
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		prepareJob(obj)
+	}
+	return obj
+}
+
+// prepareService removes the cluster ips and the node ports allocated by the hub, they are allocated
+// again by the member cluster. The headless services keep their cluster ip.
+func prepareService(obj *unstructured.Unstructured) {
+	if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != corev1.ClusterIPNone {
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+	}
+	ports, found, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	if !found {
+		return
+	}
+	for _, port := range ports {
+		if port, ok := port.(map[string]interface{}); ok {
+			delete(port, "nodePort")
+		}
+	}
+	_ = unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+}
+
+// prepareJob removes the selector and the labels of the pod template generated by the hub, they are generated
+// again by the member cluster with its own uid. The selector of a job with a manual selector is kept.
+func prepareJob(obj *unstructured.Unstructured) {
+	if manual, _, _ := unstructured.NestedBool(obj.Object, "spec", "manualSelector"); manual {
+		return
+	}
+	unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+	labels, found, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+	if !found {
+		return
+	}
+	delete(labels, "controller-uid")
+	delete(labels, "batch.kubernetes.io/controller-uid")
+	_ = unstructured.SetNestedStringMap(obj.Object, labels, "spec", "template", "metadata", "labels")
+}
+
+// Apply server-side applies the object stamped by Stamp to the member cluster. The object existing in the
+// member cluster is not taken over unless it is stamped by the same binding.
+func Apply(ctx context.Context, clu cluster.Interface, obj *unstructured.Unstructured) error {
+	mapping, err := clu.RESTMapper().RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+	if err != nil {
+		return fmt.Errorf("failed to get rest mapping of %s: %s", obj.GroupVersionKind(), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%s is not namespaced in the cluster", obj.GetKind())
+	}
+	ri := resourceInterface(clu, mapping, obj.GetNamespace())
+	current, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		binding := obj.GetAnnotations()[v1beta1.ResourceBindingAnnotation]
+		if len(binding) == 0 || current.GetAnnotations()[v1beta1.ResourceBindingAnnotation] != binding {
+			return fmt.Errorf("%s %s/%s exists in the cluster and is not propagated by the binding", obj.GetKind(),
+				obj.GetNamespace(), obj.GetName())
+		}
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	force := true
+	_, err = ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data,
+		metav1.PatchOptions{FieldManager: FieldManager, Force: &force})
+	return err
+}
+
+func resourceInterface(clu cluster.Interface, mapping *meta.RESTMapping, namespace string) dynamic.ResourceInterface {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return clu.Dynamic().Resource(mapping.Resource).Namespace(namespace)
+	}
+	return clu.Dynamic().Resource(mapping.Resource)
+}
+
+// Stamp labels and annotates the object propagated by the ResourceBinding, so the
+// object in the member cluster can be traced back to its source in the hub.
+func Stamp(obj *unstructured.Unstructured, binding types.NamespacedName) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[v1beta1.ManagedByLabel] = FieldManager
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1beta1.ResourceBindingAnnotation] = binding.String()
+	obj.SetAnnotations(annotations)
+}
+
+// Remove removes the resource of the ResourceBinding from the member cluster by the deletion
+// policy of the binding. The object is left untouched if it is not stamped by the binding.
+func Remove(ctx context.Context, clu cluster.Interface, binding *v1beta1.ResourceBinding) error {
+	ref := binding.Spec.Resource
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+	mapping, err := clu.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get rest mapping of %s: %s", gvk, err)
+	}
+	ri := resourceInterface(clu, mapping, ref.Namespace)
+	obj, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	key := types.NamespacedName{Namespace: binding.Namespace, Name: binding.Name}
+	if obj.GetAnnotations()[v1beta1.ResourceBindingAnnotation] != key.String() {
+		return nil
+	}
+
+	if binding.Spec.DeletionPolicy == v1beta1.DeletionPolicyOrphan {
+		patch := fmt.Sprintf(`{"metadata":{"labels":{%q:null},"annotations":{%q:null}}}`,
+			v1beta1.ManagedByLabel, v1beta1.ResourceBindingAnnotation)
+		_, err = ri.Patch(ctx, ref.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		return client.IgnoreNotFound(err)
+	}
+	uid, background := obj.GetUID(), metav1.DeletePropagationBackground
+	err = ri.Delete(ctx, ref.Name, metav1.DeleteOptions{
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		PropagationPolicy: &background,
+	})
+	return client.IgnoreNotFound(err)
+}
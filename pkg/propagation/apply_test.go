@@ -0,0 +1,337 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+type fakeCluster struct {
+	cluster.Interface
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+}
+
+func (c *fakeCluster) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+func (c *fakeCluster) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func testRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(rbacv1.SchemeGroupVersion.WithKind("ClusterRole"), meta.RESTScopeRoot)
+	return mapper
+}
+
+func TestTemplates(t *testing.T) {
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nginx"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "admin"}},
+	).Build()
+	tests := []struct {
+		name     string
+		selector v1beta1.ResourceSelector
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "namespaced",
+			selector: v1beta1.ResourceSelector{APIVersion: "v1", Kind: "ConfigMap", Name: "nginx"},
+			want:     []string{"nginx"},
+		},
+		{
+			name:     "cluster scoped by name",
+			selector: v1beta1.ResourceSelector{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "admin"},
+			wantErr:  true,
+		},
+		{
+			name:     "cluster scoped by labels",
+			selector: v1beta1.ResourceSelector{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := Templates(context.TODO(), reader, testRESTMapper(), "default",
+				[]v1beta1.ResourceSelector{tt.selector})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Templates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, template := range templates {
+				got = append(got, template.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Templates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_NotPropagated(t *testing.T) {
+	existing := func(annotations map[string]string) runtime.Object {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nginx",
+			Annotations: annotations}}
+	}
+	tests := []struct {
+		name     string
+		existing runtime.Object
+		obj      *unstructured.Unstructured
+		wantErr  string
+	}{
+		{
+			name:     "existing not stamped",
+			existing: existing(nil),
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+			}},
+			wantErr: "not propagated",
+		},
+		{
+			name:     "stamped by another binding",
+			existing: existing(map[string]string{v1beta1.ResourceBindingAnnotation: "default/other"}),
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+			}},
+			wantErr: "not propagated",
+		},
+		{
+			name:     "cluster scoped",
+			existing: existing(nil),
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "ClusterRole",
+				"metadata":   map[string]interface{}{"name": "admin"},
+			}},
+			wantErr: "not namespaced",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clu := &fakeCluster{
+				dynamic: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, tt.existing),
+				mapper:  testRESTMapper(),
+			}
+			Stamp(tt.obj, types.NamespacedName{Namespace: "default", Name: "nginx-configmap"})
+			err := Apply(context.TODO(), clu, tt.obj)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	template := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":              "nginx",
+			"namespace":         "default",
+			"resourceVersion":   "100",
+			"uid":               "8b0e6e7b-3b2a-4c4e-9f0a-4f9d1b1e2c3d",
+			"creationTimestamp": "2023-01-01T00:00:00Z",
+			"labels":            map[string]interface{}{"app": "nginx"},
+		},
+		"data":   map[string]interface{}{"key": "value"},
+		"status": map[string]interface{}{"phase": "Active"},
+	}}
+	want := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "nginx"},
+		},
+		"data": map[string]interface{}{"key": "value"},
+	}}
+	if got := Prepare(template); !reflect.DeepEqual(got, want) {
+		t.Errorf("Prepare() = %v, want %v", got, want)
+	}
+	if template.GetResourceVersion() != "100" {
+		t.Errorf("Prepare() modified the template")
+	}
+}
+
+func TestPrepare_Kinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		template map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "service",
+			template: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"type":       "NodePort",
+					"clusterIP":  "10.96.0.10",
+					"clusterIPs": []interface{}{"10.96.0.10"},
+					"ports": []interface{}{
+						map[string]interface{}{"port": int64(80), "nodePort": int64(30080)},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"type":  "NodePort",
+					"ports": []interface{}{map[string]interface{}{"port": int64(80)}},
+				},
+			},
+		},
+		{
+			name: "headless service",
+			template: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"clusterIP":  "None",
+					"clusterIPs": []interface{}{"None"},
+				},
+			},
+			want: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "nginx", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"clusterIP":  "None",
+					"clusterIPs": []interface{}{"None"},
+				},
+			},
+		},
+		{
+			name: "job",
+			template: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "pi", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"controller-uid": "8b0e6e7b"},
+					},
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"labels": map[string]interface{}{
+								"app":                                "pi",
+								"controller-uid":                     "8b0e6e7b",
+								"batch.kubernetes.io/controller-uid": "8b0e6e7b",
+								"job-name":                           "pi",
+							},
+						},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "pi", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"labels": map[string]interface{}{"app": "pi", "job-name": "pi"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "job with manual selector",
+			template: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "pi", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"manualSelector": true,
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"app": "pi"},
+					},
+				},
+			},
+			want: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "pi", "namespace": "default"},
+				"spec": map[string]interface{}{
+					"manualSelector": true,
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"app": "pi"},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Prepare(&unstructured.Unstructured{Object: tt.template})
+			if !reflect.DeepEqual(got.Object, tt.want) {
+				t.Errorf("Prepare() = %v, want %v", got.Object, tt.want)
+			}
+		})
+	}
+}
+
+func TestStamp(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "nginx"},
+		},
+	}}
+	Stamp(obj, types.NamespacedName{Namespace: "default", Name: "nginx-configmap"})
+	wantLabels := map[string]string{"app": "nginx", v1beta1.ManagedByLabel: FieldManager}
+	if got := obj.GetLabels(); !reflect.DeepEqual(got, wantLabels) {
+		t.Errorf("Stamp() labels = %v, want %v", got, wantLabels)
+	}
+	wantAnnotations := map[string]string{v1beta1.ResourceBindingAnnotation: "default/nginx-configmap"}
+	if got := obj.GetAnnotations(); !reflect.DeepEqual(got, wantAnnotations) {
+		t.Errorf("Stamp() annotations = %v, want %v", got, wantAnnotations)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"encoding/json"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Override applies the rules of the policies selecting the object and targeting the cluster
+// to the object in place. The policies are applied in the order of their names.
+func Override(obj *unstructured.Unstructured, clu *v1beta1.Cluster, policies []v1beta1.OverridePolicy) error {
+	sorted := make([]*v1beta1.OverridePolicy, 0, len(policies))
+	for i := range policies {
+		sorted = append(sorted, &policies[i])
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	gvk, namespace, name := obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName()
+	for _, policy := range sorted {
+		selected, err := MatchResources(policy.Spec.ResourceSelectors, obj)
+		if err != nil {
+			return fmt.Errorf("failed to match resources of override policy %s: %s", policy.Name, err)
+		}
+		if !selected {
+			continue
+		}
+		for i, rule := range policy.Spec.OverrideRules {
+			matched, err := MatchCluster(&rule.TargetCluster, clu)
+			if err != nil {
+				return fmt.Errorf("failed to match clusters of override policy %s: %s", policy.Name, err)
+			}
+			if !matched {
+				continue
+			}
+			if err = applyOverriders(obj, &rule.Overriders); err != nil {
+				return fmt.Errorf("failed to apply rule %d of override policy %s: %s", i, policy.Name, err)
+			}
+		}
+	}
+	if obj.GroupVersionKind() != gvk || obj.GetNamespace() != namespace || obj.GetName() != name {
+		return fmt.Errorf("overrides must not change the kind, namespace or name of %s %s/%s", gvk.Kind, namespace, name)
+	}
+	return nil
+}
+
+// MatchResources returns whether the object is selected by any of the selectors.
+func MatchResources(selectors []v1beta1.ResourceSelector, obj *unstructured.Unstructured) (bool, error) {
+	for _, rs := range selectors {
+		if rs.APIVersion != obj.GetAPIVersion() || rs.Kind != obj.GetKind() {
+			continue
+		}
+		if len(rs.Name) != 0 {
+			if rs.Name == obj.GetName() {
+				return true, nil
+			}
+			continue
+		}
+		if rs.LabelSelector == nil {
+			return true, nil
+		}
+		selector, err := metav1.LabelSelectorAsSelector(rs.LabelSelector)
+		if err != nil {
+			return false, err
+		}
+		if selector.Matches(labels.Set(obj.GetLabels())) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func applyOverriders(obj *unstructured.Unstructured, overriders *v1beta1.Overriders) error {
+	for _, fp := range overriders.FieldPaths {
+		var value interface{}
+		if err := utiljson.Unmarshal(fp.Value.Raw, &value); err != nil {
+			return fmt.Errorf("invalid value of field path %s: %s", fp.Path, err)
+		}
+		if err := setFieldPath(obj.Object, fp.Path, value); err != nil {
+			return err
+		}
+	}
+	if len(overriders.JSONPatch) == 0 && overriders.StrategicMerge == nil {
+		return nil
+	}
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	if len(overriders.JSONPatch) != 0 {
+		raw, err := json.Marshal(overriders.JSONPatch)
+		if err != nil {
+			return err
+		}
+		patch, err := jsonpatch.DecodePatch(raw)
+		if err != nil {
+			return fmt.Errorf("invalid json patch: %s", err)
+		}
+		if data, err = patch.Apply(data); err != nil {
+			return fmt.Errorf("failed to apply json patch: %s", err)
+		}
+	}
+	if overriders.StrategicMerge != nil {
+		if data, err = strategicMerge(obj, data, overriders.StrategicMerge.Raw); err != nil {
+			return fmt.Errorf("failed to apply strategic merge patch: %s", err)
+		}
+	}
+
+	object := map[string]interface{}{}
+	if err = utiljson.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	obj.Object = object
+	return nil
+}
+
+// strategicMerge applies a strategic merge patch if the kind has a registered Go type,
+// otherwise it applies a json merge patch.
+func strategicMerge(obj *unstructured.Unstructured, data, patch []byte) ([]byte, error) {
+	typed, err := scheme.Scheme.New(obj.GroupVersionKind())
+	if err != nil {
+		return jsonpatch.MergePatch(data, patch)
+	}
+	return strategicpatch.StrategicMergePatch(data, patch, typed)
+}
+
+// setFieldPath sets the value of the field at the dot separated path,
+// numeric elements index the lists and missing maps are created.
+func setFieldPath(object map[string]interface{}, path string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("field path must not be empty")
+	}
+	fields := strings.Split(path, ".")
+	var current interface{} = object
+	for i, field := range fields {
+		last := i == len(fields)-1
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if last {
+				node[field] = value
+				return nil
+			}
+			next, ok := node[field]
+			if !ok || next == nil {
+				next = map[string]interface{}{}
+				node[field] = next
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(field)
+			if err != nil || index < 0 || index >= len(node) {
+				return fmt.Errorf("invalid index %s of field path %s", field, path)
+			}
+			if last {
+				node[index] = value
+				return nil
+			}
+			current = node[index]
+		default:
+			return fmt.Errorf("field %s of field path %s is not a map or list", strings.Join(fields[:i], "."), path)
+		}
+	}
+	return nil
+}
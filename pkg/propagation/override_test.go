@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"reflect"
+	"testing"
+)
+
+func newDeployment() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "nginx"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "nginx", "image": "nginx:1.23"},
+						map[string]interface{}{"name": "sidecar", "image": "sidecar:1.0"},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func newOverridePolicy(name string, rules ...v1beta1.OverrideRule) v1beta1.OverridePolicy {
+	return v1beta1.OverridePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1beta1.OverridePolicySpec{
+			ResourceSelectors: []v1beta1.ResourceSelector{{
+				APIVersion:    "apps/v1",
+				Kind:          "Deployment",
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			}},
+			OverrideRules: rules,
+		},
+	}
+}
+
+func TestOverride(t *testing.T) {
+	clu := newCluster("prod-cn", "prod", "China")
+	tests := []struct {
+		name     string
+		policies []v1beta1.OverridePolicy
+		want     func(obj *unstructured.Unstructured)
+		wantErr  bool
+	}{
+		{
+			name: "field paths",
+			policies: []v1beta1.OverridePolicy{newOverridePolicy("replicas", v1beta1.OverrideRule{
+				Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "spec.replicas", Value: apiextensionsv1.JSON{Raw: []byte(`3`)}},
+					{Path: "spec.template.spec.containers.1.image", Value: apiextensionsv1.JSON{Raw: []byte(`"sidecar:2.0"`)}},
+					{Path: "spec.template.metadata.labels", Value: apiextensionsv1.JSON{Raw: []byte(`{"region":"cn"}`)}},
+				}},
+			})},
+			want: func(obj *unstructured.Unstructured) {
+				obj.Object["spec"].(map[string]interface{})["replicas"] = int64(3)
+				_ = unstructured.SetNestedField(obj.Object, map[string]interface{}{"region": "cn"}, "spec", "template", "metadata", "labels")
+				containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+				containers[1].(map[string]interface{})["image"] = "sidecar:2.0"
+				_ = unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")
+			},
+		},
+		{
+			name: "json patch and strategic merge",
+			policies: []v1beta1.OverridePolicy{newOverridePolicy("image", v1beta1.OverrideRule{
+				Overriders: v1beta1.Overriders{
+					JSONPatch: []v1beta1.JSONPatchOperation{
+						{Op: "replace", Path: "/spec/replicas", Value: &apiextensionsv1.JSON{Raw: []byte(`2`)}},
+					},
+					StrategicMerge: &apiextensionsv1.JSON{Raw: []byte(
+						`{"spec":{"template":{"spec":{"containers":[{"name":"nginx","image":"mirror.cn/nginx:1.23"}]}}}}`)},
+				},
+			})},
+			want: func(obj *unstructured.Unstructured) {
+				obj.Object["spec"].(map[string]interface{})["replicas"] = int64(2)
+				containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+				containers[0].(map[string]interface{})["image"] = "mirror.cn/nginx:1.23"
+				_ = unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")
+			},
+		},
+		{
+			name: "rules not targeting the cluster are skipped",
+			policies: []v1beta1.OverridePolicy{newOverridePolicy("germany", v1beta1.OverrideRule{
+				TargetCluster: v1beta1.ClusterAffinity{Regions: []v1beta1.Region{{Country: "Germany"}}},
+				Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "spec.replicas", Value: apiextensionsv1.JSON{Raw: []byte(`5`)}},
+				}},
+			})},
+			want: func(obj *unstructured.Unstructured) {},
+		},
+		{
+			name: "policies are applied in the order of names",
+			policies: []v1beta1.OverridePolicy{
+				newOverridePolicy("b", v1beta1.OverrideRule{Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "spec.replicas", Value: apiextensionsv1.JSON{Raw: []byte(`5`)}},
+				}}}),
+				newOverridePolicy("a", v1beta1.OverrideRule{Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "spec.replicas", Value: apiextensionsv1.JSON{Raw: []byte(`4`)}},
+				}}}),
+			},
+			want: func(obj *unstructured.Unstructured) {
+				obj.Object["spec"].(map[string]interface{})["replicas"] = int64(5)
+			},
+		},
+		{
+			name: "renaming is rejected",
+			policies: []v1beta1.OverridePolicy{newOverridePolicy("rename", v1beta1.OverrideRule{
+				Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "metadata.name", Value: apiextensionsv1.JSON{Raw: []byte(`"apache"`)}},
+				}},
+			})},
+			wantErr: true,
+		},
+		{
+			name: "invalid index",
+			policies: []v1beta1.OverridePolicy{newOverridePolicy("index", v1beta1.OverrideRule{
+				Overriders: v1beta1.Overriders{FieldPaths: []v1beta1.FieldPathOverrider{
+					{Path: "spec.template.spec.containers.2.image", Value: apiextensionsv1.JSON{Raw: []byte(`"busybox"`)}},
+				}},
+			})},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newDeployment()
+			err := Override(got, &clu, tt.policies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Override() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := newDeployment()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Override() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOverrideCustomResource(t *testing.T) {
+	clu := newCluster("prod-cn", "prod", "China")
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Database",
+		"metadata":   map[string]interface{}{"name": "db", "namespace": "default"},
+		"spec":       map[string]interface{}{"size": "small", "engine": "mysql"},
+	}}
+	policy := v1beta1.OverridePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "size", Namespace: "default"},
+		Spec: v1beta1.OverridePolicySpec{
+			ResourceSelectors: []v1beta1.ResourceSelector{{APIVersion: "example.com/v1", Kind: "Database", Name: "db"}},
+			OverrideRules: []v1beta1.OverrideRule{{
+				Overriders: v1beta1.Overriders{StrategicMerge: &apiextensionsv1.JSON{Raw: []byte(`{"spec":{"size":"large"}}`)}},
+			}},
+		},
+	}
+	if err := Override(obj, &clu, []v1beta1.OverridePolicy{policy}); err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+	want := map[string]interface{}{"size": "large", "engine": "mysql"}
+	if got := obj.Object["spec"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Override() spec = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sort"
+	"time"
+)
+
+// SelectClusters returns the clusters selected by the placement, sorted by name.
+// The disabled clusters are never selected. The taints not tolerated by the placement only block
+// new placements, the clusters already bound are kept unless they are evicted by a NoExecute taint.
+func SelectClusters(placement *v1beta1.Placement, clusters []v1beta1.Cluster, bound []string) ([]v1beta1.Cluster, error) {
+	now := time.Now()
+	var selected []v1beta1.Cluster
+	for _, clu := range clusters {
+		if clu.Spec.Disabled {
+			continue
+		}
+		matched, err := MatchCluster(&placement.ClusterAffinity, &clu)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		if _, evicted := utils.FindEvictingTaint(clu.Spec.Taints, placement.ClusterTolerations, now); evicted {
+			continue
+		}
+		if !containsString(bound, clu.Name) {
+			if _, untolerated := utils.FindUntoleratedTaint(clu.Spec.Taints, placement.ClusterTolerations,
+				corev1.TaintEffectNoSchedule, corev1.TaintEffectNoExecute); untolerated {
+				continue
+			}
+		}
+		selected = append(selected, clu)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected, nil
+}
+
+// MatchCluster returns whether the cluster matches all the non-empty rules of the affinity.
+func MatchCluster(affinity *v1beta1.ClusterAffinity, clu *v1beta1.Cluster) (bool, error) {
+	if len(affinity.ClusterNames) != 0 && !containsString(affinity.ClusterNames, clu.Name) {
+		return false, nil
+	}
+	if affinity.ClusterSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(affinity.ClusterSelector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(clu.Labels)) {
+			return false, nil
+		}
+	}
+	if len(affinity.Regions) != 0 && !matchRegions(affinity.Regions, &clu.Spec.Region) {
+		return false, nil
+	}
+	return true, nil
+}
+
+func matchRegions(regions []v1beta1.Region, region *v1beta1.Region) bool {
+	for _, r := range regions {
+		if matchField(r.Zone, region.Zone) && matchField(r.Country, region.Country) &&
+			matchField(r.Province, region.Province) && matchField(r.City, region.City) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchField(want, value string) bool {
+	return len(want) == 0 || want == value
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func newCluster(name, env, country string, taints ...corev1.Taint) v1beta1.Cluster {
+	return v1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"env": env}},
+		Spec: v1beta1.ClusterSpec{
+			Region: v1beta1.Region{Country: country},
+			Taints: taints,
+		},
+	}
+}
+
+func TestSelectClusters(t *testing.T) {
+	maintenance := corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}
+	draining := corev1.Taint{Key: "draining", Effect: corev1.TaintEffectNoExecute}
+	clusters := []v1beta1.Cluster{
+		newCluster("prod-cn", "prod", "China"),
+		newCluster("prod-de", "prod", "Germany"),
+		newCluster("dev-cn", "dev", "China"),
+		newCluster("prod-cn-maintenance", "prod", "China", maintenance),
+		newCluster("prod-cn-draining", "prod", "China", draining),
+	}
+	disabled := newCluster("prod-cn-disabled", "prod", "China")
+	disabled.Spec.Disabled = true
+	clusters = append(clusters, disabled)
+	tests := []struct {
+		name      string
+		placement v1beta1.Placement
+		bound     []string
+		want      []string
+	}{
+		{
+			name:      "empty placement selects all untainted clusters",
+			placement: v1beta1.Placement{},
+			want:      []string{"dev-cn", "prod-cn", "prod-de"},
+		},
+		{
+			name: "tolerations",
+			placement: v1beta1.Placement{
+				ClusterTolerations: []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}},
+			},
+			want: []string{"dev-cn", "prod-cn", "prod-cn-maintenance", "prod-de"},
+		},
+		{
+			name:      "no schedule taints keep the bound clusters",
+			placement: v1beta1.Placement{},
+			bound:     []string{"prod-cn", "prod-cn-maintenance"},
+			want:      []string{"dev-cn", "prod-cn", "prod-cn-maintenance", "prod-de"},
+		},
+		{
+			name:      "no execute taints evict the bound clusters",
+			placement: v1beta1.Placement{},
+			bound:     []string{"prod-cn", "prod-cn-draining"},
+			want:      []string{"dev-cn", "prod-cn", "prod-de"},
+		},
+		{
+			name: "no execute taints tolerated",
+			placement: v1beta1.Placement{
+				ClusterTolerations: []corev1.Toleration{{Key: "draining", Operator: corev1.TolerationOpExists}},
+			},
+			want: []string{"dev-cn", "prod-cn", "prod-cn-draining", "prod-de"},
+		},
+		{
+			name: "labels and regions",
+			placement: v1beta1.Placement{
+				ClusterAffinity: v1beta1.ClusterAffinity{
+					ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+					Regions:         []v1beta1.Region{{Country: "China"}},
+				},
+			},
+			want: []string{"prod-cn"},
+		},
+		{
+			name: "names",
+			placement: v1beta1.Placement{
+				ClusterAffinity: v1beta1.ClusterAffinity{
+					ClusterNames: []string{"prod-de", "dev-cn"},
+				},
+			},
+			want: []string{"dev-cn", "prod-de"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectClusters(&tt.placement, clusters, tt.bound)
+			if err != nil {
+				t.Fatalf("SelectClusters() error = %v", err)
+			}
+			var got []string
+			for _, clu := range selected {
+				got = append(got, clu.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectClusters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"strings"
+)
+
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Authenticator authenticates the caller of a request.
+type Authenticator interface {
+	// Authenticate returns the user of the request, and false if the request is not authenticated.
+	Authenticate(ctx context.Context, req *http.Request) (*authenticationv1.UserInfo, bool, error)
+}
+
+// Authorizer authorizes the user to access a resource.
+type Authorizer interface {
+	// Authorize returns true if the user is allowed to access the resource, or the reason why not.
+	Authorize(ctx context.Context, user *authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes) (bool, string, error)
+}
+
+// IdentityResolver resolves the identity impersonated in a member cluster for a hub user.
+type IdentityResolver interface {
+	Resolve(ctx context.Context, cluster string, user *authenticationv1.UserInfo) (rest.ImpersonationConfig, error)
+}
+
+// TokenReviewAuthenticator authenticates the bearer token of a request against the hub by TokenReview.
+type TokenReviewAuthenticator struct {
+	Client kubernetes.Interface
+	// Audiences are the audiences the token must be issued for, any audience of the hub if it is empty.
+	Audiences []string
+}
+
+// Authenticate authenticates the bearer token of the request.
+func (a *TokenReviewAuthenticator) Authenticate(ctx context.Context, req *http.Request) (*authenticationv1.UserInfo, bool, error) {
+	token := bearerToken(req)
+	if len(token) == 0 {
+		return nil, false, nil
+	}
+	review, err := a.Client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.Audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(review.Status.Error) != 0 && !review.Status.Authenticated {
+		return nil, false, errors.New(review.Status.Error)
+	}
+	if !review.Status.Authenticated {
+		return nil, false, nil
+	}
+	return &review.Status.User, true, nil
+}
+
+// bearerToken returns the bearer token in the Authorization header of the request.
+func bearerToken(req *http.Request) string {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// SubjectAccessReviewAuthorizer authorizes the users by SubjectAccessReview against the hub.
+type SubjectAccessReviewAuthorizer struct {
+	Client kubernetes.Interface
+}
+
+// Authorize authorizes the user to access the resource.
+func (a *SubjectAccessReviewAuthorizer) Authorize(ctx context.Context, user *authenticationv1.UserInfo,
+	attributes *authorizationv1.ResourceAttributes) (bool, string, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := a.Client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               user.Username,
+			Groups:             user.Groups,
+			UID:                user.UID,
+			Extra:              extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	return review.Status.Allowed && !review.Status.Denied, review.Status.Reason, nil
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/identity"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix is the prefix of the paths proxied to the member clusters,
+// it is followed by the name of the cluster, /proxy and the path in the cluster.
+var Prefix = "/apis/" + v1beta1.GroupVersion.String() + "/clusters/"
+
+// clusterResource is the resource authorized to proxy the requests.
+var clusterResource = schema.GroupResource{Group: v1beta1.Group, Resource: "clusters"}
+
+// Proxy forwards the requests of the authenticated and authorized callers to the API servers
+// of the member clusters with the credentials of the clusters in the pool. The caller needs the
+// permission of the verb of the request on the proxy subresource of the Cluster in the hub,
+// e.g. get clusters/proxy for a GET request, and create clusters/proxy for the upgrade requests
+// such as exec. Watch and upgrade requests are streamed.
+type Proxy struct {
+	Pool          pool.Interface
+	Authenticator Authenticator
+	Authorizer    Authorizer
+	// Identities resolves the identities the callers are impersonated as in the member clusters,
+	// the requests are refused if it is nil, unless AllowClusterIdentity is set.
+	Identities IdentityResolver
+	// AllowClusterIdentity allows the requests to be made as the identities of the clusters,
+	// which are usually administrators of the clusters, if Identities is nil.
+	AllowClusterIdentity bool
+
+	mu sync.Mutex
+	// transports caches the transports to every member cluster.
+	transports map[cluster.Interface]*transports
+}
+
+// transports are the transports to a member cluster.
+type transports struct {
+	transport http.RoundTripper
+	upgrade   proxy.UpgradeRequestRoundTripper
+}
+
+// ServeHTTP proxies the request to the member cluster.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, location, ok := parsePath(req.URL.Path)
+	if !ok {
+		writeError(w, apierrors.NewNotFound(schema.GroupResource{}, req.URL.Path))
+		return
+	}
+
+	user, ok, err := p.Authenticator.Authenticate(req.Context(), req)
+	if err != nil {
+		klog.Errorf("error authenticating proxy request: %v", err)
+		writeError(w, apierrors.NewUnauthorized(err.Error()))
+		return
+	}
+	if !ok {
+		writeError(w, apierrors.NewUnauthorized("the request is not authenticated"))
+		return
+	}
+	verb := requestVerb(req)
+	allowed, reason, err := p.Authorizer.Authorize(req.Context(), user, &authorizationv1.ResourceAttributes{
+		Verb:        verb,
+		Group:       v1beta1.Group,
+		Version:     v1beta1.Version,
+		Resource:    clusterResource.Resource,
+		Subresource: "proxy",
+		Name:        name,
+	})
+	if err != nil {
+		klog.Errorf("error authorizing proxy request of user %s: %v", user.Username, err)
+		writeError(w, apierrors.NewInternalError(err))
+		return
+	}
+	if !allowed {
+		writeError(w, apierrors.NewForbidden(clusterResource, name,
+			fmt.Errorf("user %q cannot %s the proxy of the cluster: %s", user.Username, verb, reason)))
+		return
+	}
+
+	if p.Identities == nil && !p.AllowClusterIdentity {
+		writeError(w, apierrors.NewForbidden(clusterResource, name,
+			fmt.Errorf("the proxy is not allowed to make requests as the identity of the cluster")))
+		return
+	}
+
+	clu := p.Pool.Cluster(name)
+	if clu == nil {
+		writeError(w, apierrors.NewNotFound(clusterResource, name))
+		return
+	}
+	// the upgrade requests dial the cluster on their own, they are refused while the circuit is open
+	if httpstream.IsUpgradeRequest(req) && clu.Circuit() == cluster.CircuitOpen {
+		writeError(w, apierrors.NewServiceUnavailable(fmt.Sprintf("cluster %s is unavailable", name)))
+		return
+	}
+	t, err := p.transportsFor(clu)
+	if err != nil {
+		writeError(w, apierrors.NewInternalError(err))
+		return
+	}
+	// the API servers of the members are served over TLS if their hosts have no scheme
+	server, _, err := rest.DefaultServerURL(clu.Config().Host, "", schema.GroupVersion{}, true)
+	if err != nil {
+		writeError(w, apierrors.NewInternalError(err))
+		return
+	}
+	target := *server
+	target.Path = path.Join("/", server.Path, location)
+	if strings.HasSuffix(location, "/") && !strings.HasSuffix(target.Path, "/") {
+		target.Path += "/"
+	}
+	target.RawQuery = req.URL.RawQuery
+
+	req = req.Clone(req.Context())
+	// the caller is authenticated by the hub, its credentials must not reach the member
+	req.Header.Del("Authorization")
+	for key := range req.Header {
+		if strings.HasPrefix(key, "Impersonate-") {
+			req.Header.Del(key)
+		}
+	}
+	if p.Identities != nil {
+		mapped, err := p.Identities.Resolve(req.Context(), name, user)
+		if err != nil {
+			if errors.Is(err, identity.ErrNotMapped) {
+				writeError(w, apierrors.NewForbidden(clusterResource, name,
+					fmt.Errorf("user %q has no identity in the cluster", user.Username)))
+				return
+			}
+			klog.Errorf("error resolving identity of user %s in cluster %s: %v", user.Username, name, err)
+			writeError(w, apierrors.NewInternalError(err))
+			return
+		}
+		impersonate(req, mapped)
+	}
+	handler := proxy.NewUpgradeAwareHandler(&target, t.transport, false, false, &responder{})
+	handler.UpgradeTransport = t.upgrade
+	handler.UseLocationHost = true
+	handler.ServeHTTP(w, req)
+}
+
+// transportsFor returns the transports to the member cluster, the transports of the
+// clusters removed or replaced in the pool are dropped.
+func (p *Proxy) transportsFor(clu cluster.Interface) (*transports, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.transports[clu]; ok {
+		return t, nil
+	}
+	if p.transports == nil {
+		p.transports = make(map[cluster.Interface]*transports)
+	}
+	for cached := range p.transports {
+		if p.Pool.Cluster(cached.Name()) != cached {
+			delete(p.transports, cached)
+		}
+	}
+
+	// the requests share the rate limiter and the circuit breaker with the clients of the cluster
+	config := clu.ClientConfig()
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := upgradeTransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.RateLimiter != nil {
+		rt = &rateLimitedTransport{limiter: config.RateLimiter, delegate: rt}
+	}
+	t := &transports{transport: rt, upgrade: upgrade}
+	p.transports[clu] = t
+	return t, nil
+}
+
+// upgradeTransportFor returns the transport of the upgrade requests to the cluster, which dials
+// HTTP/1.1 connections and authenticates them with the config, limited by its rate limiter.
+func upgradeTransportFor(config *rest.Config) (proxy.UpgradeRequestRoundTripper, error) {
+	transportConfig, err := config.TransportConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := transport.TLSConfigFor(transportConfig)
+	if err != nil {
+		return nil, err
+	}
+	rt := utilnet.SetOldTransportDefaults(&http.Transport{
+		Proxy:           config.Proxy,
+		TLSClientConfig: tlsConfig,
+		DialContext:     (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+	})
+	upgrader, err := transport.HTTPWrappersForConfig(transportConfig, proxy.MirrorRequest)
+	if err != nil {
+		return nil, err
+	}
+	if config.RateLimiter != nil {
+		upgrader = &rateLimitedTransport{limiter: config.RateLimiter, delegate: upgrader}
+	}
+	return proxy.NewUpgradeRequestRoundTripper(rt, upgrader), nil
+}
+
+// rateLimitedTransport waits for the rate limiter before the requests.
+type rateLimitedTransport struct {
+	limiter  flowcontrol.RateLimiter
+	delegate http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.delegate.RoundTrip(req)
+}
+
+// impersonate sets the impersonation headers of the identity to the request.
+func impersonate(req *http.Request, identity rest.ImpersonationConfig) {
+	req.Header.Set(transport.ImpersonateUserHeader, identity.UserName)
+	if len(identity.UID) != 0 {
+		req.Header.Set(transport.ImpersonateUIDHeader, identity.UID)
+	}
+	for _, group := range identity.Groups {
+		req.Header.Add(transport.ImpersonateGroupHeader, group)
+	}
+	for key, values := range identity.Extra {
+		for _, value := range values {
+			req.Header.Add(transport.ImpersonateUserExtraHeaderPrefix+url.PathEscape(key), value)
+		}
+	}
+}
+
+// parsePath returns the name of the cluster and the path in the cluster of a proxy path.
+func parsePath(p string) (string, string, bool) {
+	if !strings.HasPrefix(p, Prefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(p, Prefix), "/", 3)
+	if len(parts) < 2 || len(parts[0]) == 0 || parts[1] != "proxy" {
+		return "", "", false
+	}
+	location := "/"
+	if len(parts) == 3 {
+		location += parts[2]
+	}
+	return parts[0], location, true
+}
+
+// requestVerb returns the verb to authorize of the request method, the upgrade
+// requests are authorized as create because they may execute commands in the cluster.
+func requestVerb(req *http.Request) string {
+	if httpstream.IsUpgradeRequest(req) {
+		return "create"
+	}
+	switch req.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "get"
+	}
+}
+
+// writeError writes the status of the error as the API servers do.
+func writeError(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.Status()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	if err := json.NewEncoder(w).Encode(&status); err != nil {
+		klog.Errorf("error writing proxy error: %v", err)
+	}
+}
+
+// responder responds the errors proxying the requests.
+type responder struct{}
+
+func (r *responder) Error(w http.ResponseWriter, _ *http.Request, err error) {
+	klog.Errorf("error proxying request: %v", err)
+	writeError(w, apierrors.NewServiceUnavailable(err.Error()))
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type fakeCluster struct {
+	cluster.Interface
+	name   string
+	config *rest.Config
+}
+
+func (c *fakeCluster) Name() string                  { return c.name }
+func (c *fakeCluster) Config() *rest.Config          { return c.config }
+func (c *fakeCluster) ClientConfig() *rest.Config    { return c.config }
+func (c *fakeCluster) Circuit() cluster.CircuitState { return cluster.CircuitClosed }
+
+type fakePool struct {
+	pool.Interface
+	clusters map[string]cluster.Interface
+}
+
+func (p *fakePool) Cluster(name string) cluster.Interface { return p.clusters[name] }
+
+type fakeAuth struct {
+	// allowed are the verbs allowed on the proxy of the cluster "member".
+	allowed map[string]bool
+	// got is the last authorized attributes.
+	got *authorizationv1.ResourceAttributes
+}
+
+func (a *fakeAuth) Authenticate(_ context.Context, req *http.Request) (*authenticationv1.UserInfo, bool, error) {
+	if bearerToken(req) != "hub-token" {
+		return nil, false, nil
+	}
+	return &authenticationv1.UserInfo{Username: "alice"}, true, nil
+}
+
+func (a *fakeAuth) Authorize(_ context.Context, _ *authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes) (bool, string, error) {
+	a.got = attributes
+	return attributes.Name == "member" && a.allowed[attributes.Verb], "not allowed", nil
+}
+
+func TestProxy(t *testing.T) {
+	var upstream *http.Request
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		upstream = req
+		_, _ = w.Write([]byte(`{"kind":"PodList"}`))
+	}))
+	defer member.Close()
+
+	auth := &fakeAuth{allowed: map[string]bool{"get": true}}
+	p := &Proxy{
+		Pool: &fakePool{clusters: map[string]cluster.Interface{
+			"member": &fakeCluster{name: "member", config: &rest.Config{Host: member.URL, BearerToken: "member-token"}},
+		}},
+		Authenticator: auth,
+		Authorizer:    auth,
+
+		AllowClusterIdentity: true,
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		upgrade  bool
+		wantCode int
+	}{
+		{name: "not a proxy path", method: http.MethodGet, path: "/api/v1/pods", token: "hub-token", wantCode: http.StatusNotFound},
+		{name: "not authenticated", method: http.MethodGet, path: Prefix + "member/proxy/api/v1/pods", wantCode: http.StatusUnauthorized},
+		{name: "forbidden verb", method: http.MethodDelete, path: Prefix + "member/proxy/api/v1/namespaces/default/pods/nginx", token: "hub-token", wantCode: http.StatusForbidden},
+		{name: "upgrade requires create", method: http.MethodGet, path: Prefix + "member/proxy/api/v1/namespaces/default/pods/nginx/exec", token: "hub-token", upgrade: true, wantCode: http.StatusForbidden},
+		{name: "cluster not in pool", method: http.MethodGet, path: Prefix + "unknown/proxy/api/v1/pods", token: "hub-token", wantCode: http.StatusForbidden},
+		{name: "proxied", method: http.MethodGet, path: Prefix + "member/proxy/api/v1/pods?watch=true", token: "hub-token", wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if len(tt.token) != 0 {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "SPDY/3.1")
+			}
+			req.Header.Set("Impersonate-User", "system:admin")
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				if upstream != nil {
+					t.Errorf("request is forwarded to the member")
+				}
+				return
+			}
+			if upstream.URL.Path != "/api/v1/pods" || upstream.URL.RawQuery != "watch=true" {
+				t.Errorf("forwarded to %s", upstream.URL)
+			}
+			if got := upstream.Header.Get("Authorization"); got != "Bearer member-token" {
+				t.Errorf("forwarded Authorization = %q", got)
+			}
+			if got := upstream.Header.Get("Impersonate-User"); len(got) != 0 {
+				t.Errorf("forwarded Impersonate-User = %q", got)
+			}
+			if auth.got.Subresource != "proxy" || auth.got.Resource != "clusters" {
+				t.Errorf("authorized attributes = %+v", auth.got)
+			}
+		})
+	}
+}
+
+func TestProxy_ClusterIdentity(t *testing.T) {
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Errorf("request is forwarded to the member")
+	}))
+	defer member.Close()
+
+	auth := &fakeAuth{allowed: map[string]bool{"get": true}}
+	p := &Proxy{
+		Pool: &fakePool{clusters: map[string]cluster.Interface{
+			"member": &fakeCluster{name: "member", config: &rest.Config{Host: member.URL, BearerToken: "member-token"}},
+		}},
+		Authenticator: auth,
+		Authorizer:    auth,
+	}
+	req := httptest.NewRequest(http.MethodGet, Prefix+"member/proxy/api/v1/pods", nil)
+	req.Header.Set("Authorization", "Bearer hub-token")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+}
+
+// countingLimiter counts the requests it waits for.
+type countingLimiter struct {
+	flowcontrol.RateLimiter
+	waited int
+}
+
+func (l *countingLimiter) Wait(context.Context) error {
+	l.waited++
+	return nil
+}
+
+func TestProxy_ClientConfig(t *testing.T) {
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"kind":"PodList"}`))
+	}))
+	defer member.Close()
+
+	limiter := &countingLimiter{}
+	var wrapped int
+	config := &rest.Config{Host: member.URL, BearerToken: "member-token", RateLimiter: limiter}
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			wrapped++
+			return rt.RoundTrip(req)
+		})
+	})
+	auth := &fakeAuth{allowed: map[string]bool{"get": true}}
+	p := &Proxy{
+		Pool: &fakePool{clusters: map[string]cluster.Interface{
+			"member": &fakeCluster{name: "member", config: config},
+		}},
+		Authenticator: auth,
+		Authorizer:    auth,
+
+		AllowClusterIdentity: true,
+	}
+	req := httptest.NewRequest(http.MethodGet, Prefix+"member/proxy/api/v1/pods", nil)
+	req.Header.Set("Authorization", "Bearer hub-token")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if limiter.waited != 1 || wrapped != 1 {
+		t.Errorf("waited %d times for the limiter and wrapped %d times, want the client config used", limiter.waited, wrapped)
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRequestVerb(t *testing.T) {
+	tests := []struct {
+		method  string
+		upgrade bool
+		want    string
+	}{
+		{method: http.MethodGet, want: "get"},
+		{method: http.MethodPost, want: "create"},
+		{method: http.MethodPut, want: "update"},
+		{method: http.MethodPatch, want: "patch"},
+		{method: http.MethodDelete, want: "delete"},
+		{method: http.MethodGet, upgrade: true, want: "create"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, Prefix+"member/proxy/api/v1/namespaces/default/pods/nginx/exec", nil)
+		if tt.upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		if got := requestVerb(req); got != tt.want {
+			t.Errorf("requestVerb(%s, upgrade %v) = %q, want %q", tt.method, tt.upgrade, got, tt.want)
+		}
+	}
+}
+
+type fakeResolver struct{}
+
+func (r *fakeResolver) Resolve(_ context.Context, _ string, user *authenticationv1.UserInfo) (rest.ImpersonationConfig, error) {
+	return rest.ImpersonationConfig{UserName: user.Username, Groups: []string{"dev", "ops"}}, nil
+}
+
+func TestProxy_Impersonation(t *testing.T) {
+	var upstream *http.Request
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		upstream = req
+	}))
+	defer member.Close()
+
+	auth := &fakeAuth{allowed: map[string]bool{"get": true}}
+	p := &Proxy{
+		Pool: &fakePool{clusters: map[string]cluster.Interface{
+			"member": &fakeCluster{name: "member", config: &rest.Config{Host: member.URL, BearerToken: "member-token"}},
+		}},
+		Authenticator: auth,
+		Authorizer:    auth,
+		Identities:    &fakeResolver{},
+	}
+	req := httptest.NewRequest(http.MethodGet, Prefix+"member/proxy/api/v1/pods", nil)
+	req.Header.Set("Authorization", "Bearer hub-token")
+	req.Header.Set("Impersonate-User", "system:admin")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := upstream.Header.Get("Impersonate-User"); got != "alice" {
+		t.Errorf("forwarded Impersonate-User = %q, want alice", got)
+	}
+	if got := upstream.Header.Values("Impersonate-Group"); !reflect.DeepEqual(got, []string{"dev", "ops"}) {
+		t.Errorf("forwarded Impersonate-Group = %v", got)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path         string
+		wantName     string
+		wantLocation string
+		wantOK       bool
+	}{
+		{path: Prefix + "member/proxy/api/v1/pods", wantName: "member", wantLocation: "/api/v1/pods", wantOK: true},
+		{path: Prefix + "member/proxy", wantName: "member", wantLocation: "/", wantOK: true},
+		{path: Prefix + "member/proxy/", wantName: "member", wantLocation: "/", wantOK: true},
+		{path: Prefix + "member/status"},
+		{path: Prefix + "/proxy/api"},
+		{path: "/api/v1/pods"},
+	}
+	for _, tt := range tests {
+		name, location, ok := parsePath(tt.path)
+		if name != tt.wantName || location != tt.wantLocation || ok != tt.wantOK {
+			t.Errorf("parsePath(%q) = %q, %q, %v", tt.path, name, location, ok)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"k8s.io/klog/v2"
+	"net/http"
+	"time"
+)
+
+// Server serves the proxy over HTTPS.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// CertFile and KeyFile are the serving certificate and key of the server.
+	CertFile string
+	KeyFile  string
+	Proxy    *Proxy
+}
+
+// Start serves the proxy until the context is done.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{Addr: s.Addr, Handler: s.Proxy, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("error shutting down proxy server: %v", err)
+		}
+	}()
+	klog.Infof("serving cluster proxy on %s", s.Addr)
+	if err := srv.ListenAndServeTLS(s.CertFile, s.KeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection returns false, every replica serves the proxy.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// Reviewer returns true if the operator is allowed to access the resource in a member cluster.
+type Reviewer func(ctx context.Context, attributes authorizationv1.SelfSubjectAccessReviewSpec) (bool, error)
+
+// SelfSubjectAccessReviewer reviews the access of the identity of the client by SelfSubjectAccessReview.
+func SelfSubjectAccessReviewer(c client.Client) Reviewer {
+	return func(ctx context.Context, spec authorizationv1.SelfSubjectAccessReviewSpec) (bool, error) {
+		review := &authorizationv1.SelfSubjectAccessReview{Spec: spec}
+		if err := c.Create(ctx, review); err != nil {
+			return false, err
+		}
+		return review.Status.Allowed && !review.Status.Denied, nil
+	}
+}
+
+// CheckEscalation returns an error if propagating the object to the member cluster would grant
+// permissions the operator does not hold in it, so the operator can never escalate its own
+// permissions, or grant others more than it has, by propagating the RBAC objects from the hub.
+// A role is checked by its rules, and a binding by the rules of the role it binds, which is read
+// from the member cluster.
+func CheckEscalation(ctx context.Context, obj client.Object, reader client.Reader, review Reviewer) error {
+	checker := &checker{review: review, reviewed: make(map[string]bool)}
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRole:
+		return checker.check(ctx, "", o.Rules)
+	case *rbacv1.Role:
+		return checker.check(ctx, o.Namespace, o.Rules)
+	case *rbacv1.ClusterRoleBinding:
+		rules, err := boundRules(ctx, reader, "", o.RoleRef)
+		if err != nil {
+			return err
+		}
+		return checker.check(ctx, "", rules)
+	case *rbacv1.RoleBinding:
+		rules, err := boundRules(ctx, reader, o.Namespace, o.RoleRef)
+		if err != nil {
+			return err
+		}
+		return checker.check(ctx, o.Namespace, rules)
+	}
+	return fmt.Errorf("unsupported RBAC object %T", obj)
+}
+
+// boundRules returns the rules of the role referenced by a binding in the namespace.
+func boundRules(ctx context.Context, reader client.Reader, namespace string, ref rbacv1.RoleRef) ([]rbacv1.PolicyRule, error) {
+	switch ref.Kind {
+	case "ClusterRole":
+		role := &rbacv1.ClusterRole{}
+		if err := reader.Get(ctx, types.NamespacedName{Name: ref.Name}, role); err != nil {
+			return nil, fmt.Errorf("failed to get bound cluster role %s: %s", ref.Name, err)
+		}
+		return role.Rules, nil
+	case "Role":
+		role := &rbacv1.Role{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, role); err != nil {
+			return nil, fmt.Errorf("failed to get bound role %s: %s", ref.Name, err)
+		}
+		return role.Rules, nil
+	}
+	return nil, fmt.Errorf("unsupported role kind %s", ref.Kind)
+}
+
+type checker struct {
+	review   Reviewer
+	reviewed map[string]bool
+}
+
+// check returns an error if the operator does not hold any permission of the rules in the namespace.
+func (c *checker) check(ctx context.Context, namespace string, rules []rbacv1.PolicyRule) error {
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			for _, path := range rule.NonResourceURLs {
+				if err := c.allowed(ctx, authorizationv1.SelfSubjectAccessReviewSpec{
+					NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: path, Verb: verb},
+				}, fmt.Sprintf("%s non-resource URL %s", verb, path)); err != nil {
+					return err
+				}
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					subresource := ""
+					if n := strings.Index(resource, "/"); n >= 0 {
+						resource, subresource = resource[:n], resource[n+1:]
+					}
+					names := rule.ResourceNames
+					if len(names) == 0 {
+						names = []string{""}
+					}
+					for _, name := range names {
+						attributes := &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Group: group,
+							Resource: resource, Subresource: subresource, Name: name}
+						if err := c.allowed(ctx, authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+							describe(attributes)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// allowed returns an error if the operator is not allowed the access described, the reviews
+// are cached by their descriptions, which identify the attributes reviewed.
+func (c *checker) allowed(ctx context.Context, spec authorizationv1.SelfSubjectAccessReviewSpec, description string) error {
+	allowed, ok := c.reviewed[description]
+	if !ok {
+		var err error
+		if allowed, err = c.review(ctx, spec); err != nil {
+			return fmt.Errorf("failed to review %s: %s", description, err)
+		}
+		c.reviewed[description] = allowed
+	}
+	if !allowed {
+		return fmt.Errorf("escalation refused: the operator is not allowed to %s", description)
+	}
+	return nil
+}
+
+func describe(attributes *authorizationv1.ResourceAttributes) string {
+	resource := attributes.Resource
+	if len(attributes.Subresource) != 0 {
+		resource += "/" + attributes.Subresource
+	}
+	if len(attributes.Group) != 0 {
+		resource += "." + attributes.Group
+	}
+	if len(attributes.Name) != 0 {
+		resource += " " + attributes.Name
+	}
+	if len(attributes.Namespace) != 0 {
+		resource += " in namespace " + attributes.Namespace
+	}
+	return attributes.Verb + " " + resource
+}
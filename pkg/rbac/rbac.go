@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"hash/fnv"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	// FederatedLabel selects the RBAC objects in the hub propagated to the member clusters, its value is true.
+	FederatedLabel = "sumengzs.cn/federated-rbac"
+	// ClusterSetAnnotation restricts the propagation of a federated RBAC object to the members of the ClusterSet.
+	ClusterSetAnnotation = "sumengzs.cn/cluster-set"
+	// TargetNamespaceAnnotation is the namespace a federated Role or RoleBinding is propagated to,
+	// it is the namespace of the object in the hub by default.
+	TargetNamespaceAnnotation = "sumengzs.cn/target-namespace"
+	// SourceAnnotation is the annotation of the propagated RBAC objects in the member clusters,
+	// its value is the namespace/name of the federated object in the hub.
+	SourceAnnotation = "sumengzs.cn/federated-rbac-source"
+	// ManagedBy is the value of the managed-by label of the propagated RBAC objects.
+	ManagedBy = "federated-rbac"
+	// Finalizer holds the federated object in the hub until the objects propagated from it are removed
+	// from all the member clusters.
+	Finalizer = "sumengzs.cn/federated-rbac"
+)
+
+// StatusName returns the name of the FederatedRBACStatus of the federated object of the kind in the hub.
+// The names of RBAC objects may contain characters invalid in the names of other objects, so the name
+// of the status is the kind followed by a hash of the key of the object.
+func StatusName(kind string, key types.NamespacedName) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key.String()))
+	return fmt.Sprintf("%s-%016x", strings.ToLower(kind), h.Sum64())
+}
+
+// IsFederated returns true if the object in the hub is propagated to the member clusters.
+func IsFederated(obj client.Object) bool {
+	return obj.GetLabels()[FederatedLabel] == "true"
+}
+
+// IsPropagated returns true if the object in a member cluster is propagated from the hub.
+func IsPropagated(obj client.Object) bool {
+	return obj.GetLabels()[v1beta1.ManagedByLabel] == ManagedBy
+}
+
+// Source returns the key of the federated object in the hub the object in a member cluster is propagated from.
+func Source(obj client.Object) (types.NamespacedName, bool) {
+	source, ok := obj.GetAnnotations()[SourceAnnotation]
+	if !ok || !IsPropagated(obj) {
+		return types.NamespacedName{}, false
+	}
+	if n := strings.Index(source, "/"); n >= 0 {
+		return types.NamespacedName{Namespace: source[:n], Name: source[n+1:]}, true
+	}
+	return types.NamespacedName{Name: source}, true
+}
+
+// Desired returns the object propagated to the member clusters for the federated object in the hub.
+// It carries the labels of the federated object, and the annotations identifying its source.
+func Desired(obj client.Object) (client.Object, error) {
+	var desired client.Object
+	switch o := obj.(type) {
+	case *rbacv1.ClusterRole:
+		if o.AggregationRule != nil {
+			return nil, fmt.Errorf("aggregated cluster roles are not propagated")
+		}
+		desired = &rbacv1.ClusterRole{Rules: o.Rules}
+	case *rbacv1.ClusterRoleBinding:
+		desired = &rbacv1.ClusterRoleBinding{RoleRef: o.RoleRef, Subjects: o.Subjects}
+	case *rbacv1.Role:
+		desired = &rbacv1.Role{Rules: o.Rules}
+	case *rbacv1.RoleBinding:
+		desired = &rbacv1.RoleBinding{RoleRef: o.RoleRef, Subjects: o.Subjects}
+	default:
+		return nil, fmt.Errorf("unsupported RBAC object %T", obj)
+	}
+	if strings.HasPrefix(obj.GetName(), "system:") {
+		return nil, fmt.Errorf("objects of the system are not propagated")
+	}
+
+	desired.SetName(obj.GetName())
+	if len(obj.GetNamespace()) != 0 {
+		namespace := obj.GetNamespace()
+		if target := obj.GetAnnotations()[TargetNamespaceAnnotation]; len(target) != 0 {
+			namespace = target
+		}
+		desired.SetNamespace(namespace)
+	}
+	labels := make(map[string]string)
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+	delete(labels, FederatedLabel)
+	labels[v1beta1.ManagedByLabel] = ManagedBy
+	desired.SetLabels(labels)
+	desired.SetAnnotations(map[string]string{
+		SourceAnnotation: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}.String(),
+	})
+	return desired, nil
+}
+
+// InSync returns true if the object in the member cluster has the content of the desired object.
+func InSync(desired, current client.Object) bool {
+	if !equality.Semantic.DeepEqual(desired.GetLabels(), current.GetLabels()) ||
+		desired.GetAnnotations()[SourceAnnotation] != current.GetAnnotations()[SourceAnnotation] {
+		return false
+	}
+	switch d := desired.(type) {
+	case *rbacv1.ClusterRole:
+		c, ok := current.(*rbacv1.ClusterRole)
+		return ok && equality.Semantic.DeepEqual(d.Rules, c.Rules) && c.AggregationRule == nil
+	case *rbacv1.ClusterRoleBinding:
+		c, ok := current.(*rbacv1.ClusterRoleBinding)
+		return ok && equality.Semantic.DeepEqual(d.RoleRef, c.RoleRef) && equality.Semantic.DeepEqual(d.Subjects, c.Subjects)
+	case *rbacv1.Role:
+		c, ok := current.(*rbacv1.Role)
+		return ok && equality.Semantic.DeepEqual(d.Rules, c.Rules)
+	case *rbacv1.RoleBinding:
+		c, ok := current.(*rbacv1.RoleBinding)
+		return ok && equality.Semantic.DeepEqual(d.RoleRef, c.RoleRef) && equality.Semantic.DeepEqual(d.Subjects, c.Subjects)
+	}
+	return false
+}
+
+// Copy copies the content of the desired object into the object in the member cluster,
+// the annotations of the object other than the source are kept.
+func Copy(desired, current client.Object) {
+	current.SetLabels(desired.GetLabels())
+	annotations := current.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[SourceAnnotation] = desired.GetAnnotations()[SourceAnnotation]
+	current.SetAnnotations(annotations)
+	switch d := desired.(type) {
+	case *rbacv1.ClusterRole:
+		c := current.(*rbacv1.ClusterRole)
+		c.Rules, c.AggregationRule = d.Rules, nil
+	case *rbacv1.ClusterRoleBinding:
+		c := current.(*rbacv1.ClusterRoleBinding)
+		c.RoleRef, c.Subjects = d.RoleRef, d.Subjects
+	case *rbacv1.Role:
+		current.(*rbacv1.Role).Rules = d.Rules
+	case *rbacv1.RoleBinding:
+		c := current.(*rbacv1.RoleBinding)
+		c.RoleRef, c.Subjects = d.RoleRef, d.Subjects
+	}
+}
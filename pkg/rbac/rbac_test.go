@@ -0,0 +1,226 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+func TestDesired(t *testing.T) {
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+	tests := []struct {
+		name    string
+		obj     client.Object
+		want    client.Object
+		wantErr bool
+	}{
+		{
+			name: "cluster role",
+			obj: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "viewer", ResourceVersion: "7",
+					Labels: map[string]string{FederatedLabel: "true", "team": "platform"}},
+				Rules: rules,
+			},
+			want: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "viewer",
+					Labels:      map[string]string{v1beta1.ManagedByLabel: ManagedBy, "team": "platform"},
+					Annotations: map[string]string{SourceAnnotation: "/viewer"}},
+				Rules: rules,
+			},
+		},
+		{
+			name: "role binding to target namespace",
+			obj: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: "hub",
+					Labels:      map[string]string{FederatedLabel: "true"},
+					Annotations: map[string]string{TargetNamespaceAnnotation: "apps"}},
+				RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "viewer"},
+				Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "dev"}},
+			},
+			want: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: "apps",
+					Labels:      map[string]string{v1beta1.ManagedByLabel: ManagedBy},
+					Annotations: map[string]string{SourceAnnotation: "hub/viewers"}},
+				RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "viewer"},
+				Subjects: []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "dev"}},
+			},
+		},
+		{
+			name: "aggregated cluster role",
+			obj: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "aggregated"},
+				AggregationRule: &rbacv1.AggregationRule{}},
+			wantErr: true,
+		},
+		{
+			name:    "system object",
+			obj:     &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "system:node"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Desired(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Desired() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("Desired() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			source, ok := Source(got)
+			if !ok || source != (types.NamespacedName{Namespace: tt.obj.GetNamespace(), Name: tt.obj.GetName()}) {
+				t.Errorf("Source() got = %v, %v", source, ok)
+			}
+		})
+	}
+}
+
+func TestInSync(t *testing.T) {
+	desired, err := Desired(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Labels: map[string]string{FederatedLabel: "true"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "viewer"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "dev"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(*rbacv1.ClusterRoleBinding)
+		want   bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(*rbacv1.ClusterRoleBinding) {},
+			want:   true,
+		},
+		{
+			name: "other annotation",
+			modify: func(b *rbacv1.ClusterRoleBinding) {
+				b.Annotations["kubectl.kubernetes.io/last-applied-configuration"] = "{}"
+			},
+			want: true,
+		},
+		{
+			name: "subject added",
+			modify: func(b *rbacv1.ClusterRoleBinding) {
+				b.Subjects = append(b.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "mallory"})
+			},
+		},
+		{
+			name:   "label removed",
+			modify: func(b *rbacv1.ClusterRoleBinding) { b.Labels = nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := desired.DeepCopyObject().(*rbacv1.ClusterRoleBinding)
+			tt.modify(current)
+			if got := InSync(desired, current); got != tt.want {
+				t.Fatalf("InSync() = %v, want %v", got, tt.want)
+			}
+			Copy(desired, current)
+			if !InSync(desired, current) {
+				t.Errorf("InSync() after Copy() = false")
+			}
+		})
+	}
+}
+
+func TestCheckEscalation(t *testing.T) {
+	reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "log-reader", Namespace: "apps"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}, Verbs: []string{"get", "list"}},
+			},
+		},
+	).Build()
+	// the operator may read pods and their logs, and get the healthz endpoint
+	review := func(_ context.Context, spec authorizationv1.SelfSubjectAccessReviewSpec) (bool, error) {
+		if a := spec.NonResourceAttributes; a != nil {
+			return a.Path == "/healthz" && a.Verb == "get", nil
+		}
+		a := spec.ResourceAttributes
+		return a.Group == "" && a.Resource == "pods" && (a.Verb == "get" || a.Verb == "list"), nil
+	}
+	tests := []struct {
+		name    string
+		obj     client.Object
+		wantErr string
+	}{
+		{
+			name: "role within permissions",
+			obj: &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "apps"},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web"}, Verbs: []string{"get"}},
+					{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+				}},
+		},
+		{
+			name: "cluster role beyond permissions",
+			obj: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "secrets"},
+				Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}},
+			wantErr: "escalation refused: the operator is not allowed to get secrets",
+		},
+		{
+			name: "binding to role within permissions",
+			obj: &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "log-readers", Namespace: "apps"},
+				RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "log-reader"}},
+		},
+		{
+			name: "binding to cluster role beyond permissions",
+			obj: &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+				RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"}},
+			wantErr: "escalation refused: the operator is not allowed to * *.*",
+		},
+		{
+			name: "binding to missing role",
+			obj: &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "apps"},
+				RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "missing"}},
+			wantErr: "failed to get bound role missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckEscalation(context.TODO(), tt.obj, reader, review)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Errorf("CheckEscalation() error = %v", err)
+			}
+			if len(tt.wantErr) != 0 && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("CheckEscalation() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/propagation"
+	"github.com/sumengzs/multi-cluster/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"math"
+	"sort"
+)
+
+// maxWeight bounds the weights so the division never overflows.
+const maxWeight = math.MaxInt32
+
+// Replicas returns the replicas of the workload and the resources requested by a replica.
+// found is false if the resource has no spec.replicas.
+func Replicas(obj *unstructured.Unstructured) (replicas int32, requests corev1.ResourceList, found bool, err error) {
+	value, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil || !found {
+		return 0, nil, false, err
+	}
+	template := &corev1.PodTemplateSpec{}
+	if raw, ok, _ := unstructured.NestedMap(obj.Object, "spec", "template"); ok {
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(raw, template); err != nil {
+			return 0, nil, false, fmt.Errorf("invalid pod template: %s", err)
+		}
+	}
+	return int32(value), status.PodRequests(&corev1.Pod{Spec: template.Spec}), true, nil
+}
+
+// Schedule returns the clusters the workload with the replicas is scheduled to. The clusters
+// are the candidates selected by the placement, sorted by name. requests are the resources
+// requested by a replica, and scheduled are the clusters the workload is currently scheduled to,
+// both used by the AvailableCapacity division preference.
+func Schedule(placement *v1beta1.Placement, replicas int32, requests corev1.ResourceList, clusters []v1beta1.Cluster,
+	scheduled []v1beta1.TargetCluster) ([]v1beta1.TargetCluster, error) {
+	strategy := placement.ReplicaScheduling
+	divided := strategy != nil && strategy.Type == v1beta1.ReplicaSchedulingDivided
+
+	weights := make(map[string]int64, len(clusters))
+	for _, clu := range clusters {
+		weights[clu.Name] = 1
+	}
+	if divided {
+		var err error
+		if weights, err = divisionWeights(strategy, requests, clusters, scheduled); err != nil {
+			return nil, err
+		}
+	}
+	clusters, err := spread(placement.SpreadConstraints, clusters, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	// a divided workload scaled to zero is kept in all the clusters
+	if !divided || replicas == 0 {
+		targets := make([]v1beta1.TargetCluster, 0, len(clusters))
+		for _, clu := range clusters {
+			targets = append(targets, v1beta1.TargetCluster{Name: clu.Name, Replicas: replicas})
+		}
+		return targets, nil
+	}
+	if strategy.DivisionPreference == v1beta1.ReplicaDivisionAvailableCapacity {
+		var capacity int64
+		for _, clu := range clusters {
+			capacity += weights[clu.Name]
+		}
+		if capacity < int64(replicas) {
+			return nil, fmt.Errorf("insufficient capacity, the clusters can hold %d of %d replicas", capacity, replicas)
+		}
+	}
+	return divide(replicas, clusters, weights)
+}
+
+// divisionWeights returns the weights of the clusters by the division preference. The available
+// resources of the clusters exclude the running replicas of the workload, so the replicas currently
+// scheduled to the clusters are added back to their capacity.
+func divisionWeights(strategy *v1beta1.ReplicaSchedulingStrategy, requests corev1.ResourceList, clusters []v1beta1.Cluster,
+	scheduled []v1beta1.TargetCluster) (map[string]int64, error) {
+	current := make(map[string]int64, len(scheduled))
+	for _, target := range scheduled {
+		current[target.Name] = int64(target.Replicas)
+	}
+	weights := make(map[string]int64, len(clusters))
+	for i := range clusters {
+		clu := &clusters[i]
+		switch {
+		case strategy.DivisionPreference == v1beta1.ReplicaDivisionAvailableCapacity:
+			weights[clu.Name] = AvailableReplicas(clu, requests) + current[clu.Name]
+		case len(strategy.StaticWeights) == 0:
+			weights[clu.Name] = 1
+		default:
+			for _, sw := range strategy.StaticWeights {
+				matched, err := propagation.MatchCluster(&sw.TargetCluster, clu)
+				if err != nil {
+					return nil, err
+				}
+				if matched {
+					weights[clu.Name] = sw.Weight
+					break
+				}
+			}
+		}
+		if weights[clu.Name] > maxWeight {
+			weights[clu.Name] = maxWeight
+		}
+	}
+	return weights, nil
+}
+
+// AvailableReplicas returns the number of replicas the available resources of the cluster can hold.
+func AvailableReplicas(clu *v1beta1.Cluster, requests corev1.ResourceList) int64 {
+	if clu.Status.ResourceSummary == nil {
+		return 0
+	}
+	available := clu.Status.ResourceSummary.Available
+	replicas := int64(math.MaxInt64)
+	for name, request := range requests {
+		if request.IsZero() {
+			continue
+		}
+		quantity, ok := available[name]
+		if !ok {
+			return 0
+		}
+		if n := quantity.MilliValue() / request.MilliValue(); n < replicas {
+			replicas = n
+		}
+	}
+	if replicas < 0 {
+		return 0
+	}
+	return replicas
+}
+
+// spread applies the constraints in order, every constraint groups the clusters by
+// a field of their regions and keeps the groups with the most weight.
+func spread(constraints []v1beta1.SpreadConstraint, clusters []v1beta1.Cluster, weights map[string]int64) ([]v1beta1.Cluster, error) {
+	for _, constraint := range constraints {
+		groups := make(map[string]int64)
+		for _, clu := range clusters {
+			if value := regionField(&clu.Spec.Region, constraint.SpreadByField); len(value) != 0 {
+				groups[value] += weights[clu.Name]
+			}
+		}
+		if int32(len(groups)) < constraint.MinGroups {
+			return nil, fmt.Errorf("spread by %s requires at least %d groups, but only %d are available",
+				constraint.SpreadByField, constraint.MinGroups, len(groups))
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if groups[names[i]] != groups[names[j]] {
+				return groups[names[i]] > groups[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		if constraint.MaxGroups > 0 && int32(len(names)) > constraint.MaxGroups {
+			names = names[:constraint.MaxGroups]
+		}
+		selected := make(map[string]bool, len(names))
+		for _, name := range names {
+			selected[name] = true
+		}
+
+		var spread []v1beta1.Cluster
+		for _, clu := range clusters {
+			if selected[regionField(&clu.Spec.Region, constraint.SpreadByField)] {
+				spread = append(spread, clu)
+			}
+		}
+		clusters = spread
+	}
+	return clusters, nil
+}
+
+func regionField(region *v1beta1.Region, field v1beta1.SpreadField) string {
+	switch field {
+	case v1beta1.SpreadByZone:
+		return region.Zone
+	case v1beta1.SpreadByCountry:
+		return region.Country
+	case v1beta1.SpreadByProvince:
+		return region.Province
+	case v1beta1.SpreadByCity:
+		return region.City
+	}
+	return ""
+}
+
+// divide divides the replicas in proportion to the weights by the largest remainder method,
+// the clusters assigned no replicas are omitted.
+func divide(replicas int32, clusters []v1beta1.Cluster, weights map[string]int64) ([]v1beta1.TargetCluster, error) {
+	var total int64
+	for _, clu := range clusters {
+		total += weights[clu.Name]
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no cluster is available to divide the replicas")
+	}
+
+	type share struct {
+		name      string
+		weight    int64
+		replicas  int64
+		remainder int64
+	}
+	shares := make([]share, 0, len(clusters))
+	assigned := int64(0)
+	for _, clu := range clusters {
+		weight := weights[clu.Name]
+		s := share{
+			name:      clu.Name,
+			weight:    weight,
+			replicas:  int64(replicas) * weight / total,
+			remainder: int64(replicas) * weight % total,
+		}
+		assigned += s.replicas
+		shares = append(shares, s)
+	}
+	sort.SliceStable(shares, func(i, j int) bool {
+		if shares[i].remainder != shares[j].remainder {
+			return shares[i].remainder > shares[j].remainder
+		}
+		if shares[i].weight != shares[j].weight {
+			return shares[i].weight > shares[j].weight
+		}
+		return shares[i].name < shares[j].name
+	})
+	for i := 0; assigned < int64(replicas); i++ {
+		shares[i].replicas++
+		assigned++
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].name < shares[j].name
+	})
+	var targets []v1beta1.TargetCluster
+	for _, s := range shares {
+		if s.replicas > 0 {
+			targets = append(targets, v1beta1.TargetCluster{Name: s.name, Replicas: int32(s.replicas)})
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"reflect"
+	"testing"
+)
+
+func newCluster(name, country, cpu string) v1beta1.Cluster {
+	clu := v1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"country": country}},
+		Spec:       v1beta1.ClusterSpec{Region: v1beta1.Region{Country: country}},
+	}
+	if len(cpu) != 0 {
+		clu.Status.ResourceSummary = &v1beta1.ResourceSummary{ResourceUsage: v1beta1.ResourceUsage{
+			Available: corev1.ResourceList{
+				corev1.ResourceCPU:  resource.MustParse(cpu),
+				corev1.ResourcePods: resource.MustParse("110"),
+			},
+		}}
+	}
+	return clu
+}
+
+func TestSchedule(t *testing.T) {
+	clusters := []v1beta1.Cluster{
+		newCluster("a", "China", "2"),
+		newCluster("b", "China", "4"),
+		newCluster("c", "Germany", "8"),
+	}
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:  resource.MustParse("500m"),
+		corev1.ResourcePods: resource.MustParse("1"),
+	}
+	divided := func(preference v1beta1.ReplicaDivisionPreference, weights ...v1beta1.StaticClusterWeight) *v1beta1.ReplicaSchedulingStrategy {
+		return &v1beta1.ReplicaSchedulingStrategy{
+			Type:               v1beta1.ReplicaSchedulingDivided,
+			DivisionPreference: preference,
+			StaticWeights:      weights,
+		}
+	}
+	tests := []struct {
+		name      string
+		placement v1beta1.Placement
+		replicas  int32
+		scheduled []v1beta1.TargetCluster
+		want      []v1beta1.TargetCluster
+		wantErr   bool
+	}{
+		{
+			name:      "duplicated",
+			placement: v1beta1.Placement{},
+			replicas:  3,
+			want:      []v1beta1.TargetCluster{{Name: "a", Replicas: 3}, {Name: "b", Replicas: 3}, {Name: "c", Replicas: 3}},
+		},
+		{
+			name:      "divided equally",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionWeighted)},
+			replicas:  10,
+			want:      []v1beta1.TargetCluster{{Name: "a", Replicas: 4}, {Name: "b", Replicas: 3}, {Name: "c", Replicas: 3}},
+		},
+		{
+			name: "static weights",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionWeighted,
+				v1beta1.StaticClusterWeight{TargetCluster: v1beta1.ClusterAffinity{ClusterNames: []string{"c"}}, Weight: 2},
+				v1beta1.StaticClusterWeight{TargetCluster: v1beta1.ClusterAffinity{Regions: []v1beta1.Region{{Country: "China"}}}, Weight: 1},
+			)},
+			replicas: 9,
+			want:     []v1beta1.TargetCluster{{Name: "a", Replicas: 2}, {Name: "b", Replicas: 2}, {Name: "c", Replicas: 5}},
+		},
+		{
+			name: "clusters matching no weight are not scheduled",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionWeighted,
+				v1beta1.StaticClusterWeight{TargetCluster: v1beta1.ClusterAffinity{ClusterNames: []string{"a", "b"}}, Weight: 1},
+			)},
+			replicas: 1,
+			want:     []v1beta1.TargetCluster{{Name: "a", Replicas: 1}},
+		},
+		{
+			name:      "available capacity",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionAvailableCapacity)},
+			replicas:  7,
+			want:      []v1beta1.TargetCluster{{Name: "a", Replicas: 1}, {Name: "b", Replicas: 2}, {Name: "c", Replicas: 4}},
+		},
+		{
+			name:      "insufficient capacity",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionAvailableCapacity)},
+			replicas:  29,
+			wantErr:   true,
+		},
+		{
+			name:      "available capacity with the scheduled replicas",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionAvailableCapacity)},
+			replicas:  33,
+			scheduled: []v1beta1.TargetCluster{{Name: "a", Replicas: 2}, {Name: "c", Replicas: 3}},
+			want:      []v1beta1.TargetCluster{{Name: "a", Replicas: 6}, {Name: "b", Replicas: 8}, {Name: "c", Replicas: 19}},
+		},
+		{
+			name:      "insufficient capacity with the scheduled replicas",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionAvailableCapacity)},
+			replicas:  34,
+			scheduled: []v1beta1.TargetCluster{{Name: "a", Replicas: 2}, {Name: "c", Replicas: 3}},
+			wantErr:   true,
+		},
+		{
+			name: "spread to the country with the most capacity",
+			placement: v1beta1.Placement{
+				ReplicaScheduling: divided(v1beta1.ReplicaDivisionAvailableCapacity),
+				SpreadConstraints: []v1beta1.SpreadConstraint{{SpreadByField: v1beta1.SpreadByCountry, MaxGroups: 1}},
+			},
+			replicas: 6,
+			want:     []v1beta1.TargetCluster{{Name: "c", Replicas: 6}},
+		},
+		{
+			name: "too few groups",
+			placement: v1beta1.Placement{
+				SpreadConstraints: []v1beta1.SpreadConstraint{{SpreadByField: v1beta1.SpreadByCountry, MinGroups: 3}},
+			},
+			replicas: 1,
+			wantErr:  true,
+		},
+		{
+			name:      "scaled to zero",
+			placement: v1beta1.Placement{ReplicaScheduling: divided(v1beta1.ReplicaDivisionWeighted)},
+			replicas:  0,
+			want:      []v1beta1.TargetCluster{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Schedule(&tt.placement, tt.replicas, requests, clusters, tt.scheduled)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Schedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Schedule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":      "nginx",
+							"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "100m"}},
+						},
+					},
+				},
+			},
+		},
+	}}
+	replicas, requests, found, err := Replicas(deployment)
+	if err != nil || !found {
+		t.Fatalf("Replicas() found = %v, error = %v", found, err)
+	}
+	if replicas != 3 {
+		t.Errorf("Replicas() replicas = %d, want 3", replicas)
+	}
+	want := corev1.ResourceList{
+		corev1.ResourceCPU:  resource.MustParse("100m"),
+		corev1.ResourcePods: resource.MustParse("1"),
+	}
+	if !equality.Semantic.DeepEqual(requests, want) {
+		t.Errorf("Replicas() requests = %v, want %v", requests, want)
+	}
+
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}}
+	if _, _, found, err = Replicas(configMap); err != nil || found {
+		t.Errorf("Replicas() of ConfigMap found = %v, error = %v", found, err)
+	}
+}
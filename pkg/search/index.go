@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Query selects the resources indexed across the member clusters.
+type Query struct {
+	// GroupKind of the resources, the resources of all the indexed kinds are selected if the kind is empty.
+	GroupKind schema.GroupKind
+	// Clusters are the names of the clusters to search, all the clusters are searched if it is empty.
+	Clusters []string
+	// Namespace of the resources, the resources in all the namespaces are selected if it is empty.
+	Namespace string
+	// NamePrefix is the prefix of the names of the resources.
+	NamePrefix string
+	// LabelSelector selects the resources by their labels.
+	LabelSelector labels.Selector
+	// FieldSelector selects the resources by the values of their fields, e.g. spec.nodeName=node-1.
+	FieldSelector fields.Selector
+	// Limit is the maximum number of the results, no limit if it is zero.
+	Limit int
+}
+
+// Result is a resource found in a member cluster.
+type Result struct {
+	// Cluster is the name of the cluster the resource is in.
+	Cluster string `json:"cluster"`
+	// Object is the resource.
+	Object *unstructured.Unstructured `json:"object"`
+}
+
+// Searcher searches the resources across the member clusters.
+type Searcher interface {
+	// Search returns the resources selected by the query ordered by cluster, namespace and name.
+	Search(ctx context.Context, query *Query) ([]Result, error)
+}
+
+// key identifies a resource of a kind in the index.
+type key struct {
+	cluster   string
+	namespace string
+	name      string
+}
+
+// kindIndex indexes the resources of a kind.
+type kindIndex struct {
+	objects map[key]*unstructured.Unstructured
+	// labels indexes the keys of the resources by their labels in the form of key=value.
+	labels map[string]map[key]bool
+}
+
+// Index is an in-memory index of the resources in the member clusters, keyed by
+// cluster, namespace and name, and by labels. It is safe for concurrent use.
+type Index struct {
+	mu    sync.RWMutex
+	kinds map[schema.GroupKind]*kindIndex
+}
+
+var _ Searcher = &Index{}
+
+// NewIndex returns an empty Index.
+func NewIndex() *Index {
+	return &Index{kinds: make(map[schema.GroupKind]*kindIndex)}
+}
+
+// Upsert adds or updates the resource in the cluster, the managed fields are not indexed.
+func (i *Index) Upsert(cluster string, obj *unstructured.Unstructured) {
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+	gk := obj.GroupVersionKind().GroupKind()
+	k := key{cluster: cluster, namespace: obj.GetNamespace(), name: obj.GetName()}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ki, ok := i.kinds[gk]
+	if !ok {
+		ki = &kindIndex{objects: make(map[key]*unstructured.Unstructured), labels: make(map[string]map[key]bool)}
+		i.kinds[gk] = ki
+	}
+	ki.remove(k)
+	ki.objects[k] = obj
+	for name, value := range obj.GetLabels() {
+		label := name + "=" + value
+		if ki.labels[label] == nil {
+			ki.labels[label] = make(map[key]bool)
+		}
+		ki.labels[label][k] = true
+	}
+}
+
+// Delete removes the resource in the cluster.
+func (i *Index) Delete(cluster string, gk schema.GroupKind, namespace, name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ki, ok := i.kinds[gk]; ok {
+		ki.remove(key{cluster: cluster, namespace: namespace, name: name})
+	}
+}
+
+// RemoveCluster removes all the resources in the cluster.
+func (i *Index) RemoveCluster(cluster string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, ki := range i.kinds {
+		for k := range ki.objects {
+			if k.cluster == cluster {
+				ki.remove(k)
+			}
+		}
+	}
+}
+
+// Search returns the resources selected by the query ordered by cluster, namespace and name.
+func (i *Index) Search(_ context.Context, query *Query) ([]Result, error) {
+	var fieldRequirements fields.Requirements
+	if query.FieldSelector != nil {
+		fieldRequirements = query.FieldSelector.Requirements()
+		for _, requirement := range fieldRequirements {
+			switch requirement.Operator {
+			case selection.Equals, selection.DoubleEquals, selection.NotEquals:
+			default:
+				return nil, fmt.Errorf("unsupported operator %s of field %s", requirement.Operator, requirement.Field)
+			}
+		}
+	}
+	clusters := make(map[string]bool)
+	for _, cluster := range query.Clusters {
+		clusters[cluster] = true
+	}
+
+	i.mu.RLock()
+	var results []Result
+	for gk, ki := range i.kinds {
+		if !query.GroupKind.Empty() && gk != query.GroupKind {
+			continue
+		}
+		for k, obj := range ki.candidates(query.LabelSelector) {
+			if (len(clusters) != 0 && !clusters[k.cluster]) ||
+				(len(query.Namespace) != 0 && k.namespace != query.Namespace) ||
+				!strings.HasPrefix(k.name, query.NamePrefix) ||
+				(query.LabelSelector != nil && !query.LabelSelector.Matches(labels.Set(obj.GetLabels()))) ||
+				!matchFields(obj, fieldRequirements) {
+				continue
+			}
+			results = append(results, Result{Cluster: k.cluster, Object: obj})
+		}
+	}
+	i.mu.RUnlock()
+
+	sort.Slice(results, func(a, b int) bool {
+		x, y := results[a], results[b]
+		if x.Cluster != y.Cluster {
+			return x.Cluster < y.Cluster
+		}
+		if x.Object.GetNamespace() != y.Object.GetNamespace() {
+			return x.Object.GetNamespace() < y.Object.GetNamespace()
+		}
+		if x.Object.GetName() != y.Object.GetName() {
+			return x.Object.GetName() < y.Object.GetName()
+		}
+		return x.Object.GetKind() < y.Object.GetKind()
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	// the indexed objects are replaced rather than modified, so copying them out of the lock is safe
+	for n := range results {
+		results[n].Object = results[n].Object.DeepCopy()
+	}
+	return results, nil
+}
+
+// candidates returns the resources which may match the selector, narrowed by
+// the label index with the most selective equality requirement of the selector.
+func (ki *kindIndex) candidates(selector labels.Selector) map[key]*unstructured.Unstructured {
+	if selector == nil {
+		return ki.objects
+	}
+	requirements, _ := selector.Requirements()
+	var narrowest map[key]bool
+	found := false
+	for _, requirement := range requirements {
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+		default:
+			continue
+		}
+		values := requirement.Values()
+		if values.Len() != 1 {
+			continue
+		}
+		keys := ki.labels[requirement.Key()+"="+values.List()[0]]
+		if !found || len(keys) < len(narrowest) {
+			narrowest, found = keys, true
+		}
+	}
+	if !found {
+		return ki.objects
+	}
+	candidates := make(map[key]*unstructured.Unstructured, len(narrowest))
+	for k := range narrowest {
+		candidates[k] = ki.objects[k]
+	}
+	return candidates
+}
+
+// remove removes the resource from the index of the kind.
+func (ki *kindIndex) remove(k key) {
+	obj, ok := ki.objects[k]
+	if !ok {
+		return
+	}
+	for name, value := range obj.GetLabels() {
+		label := name + "=" + value
+		delete(ki.labels[label], k)
+		if len(ki.labels[label]) == 0 {
+			delete(ki.labels, label)
+		}
+	}
+	delete(ki.objects, k)
+}
+
+// matchFields returns true if the fields of the resource match all the requirements,
+// the field of a requirement is a path of the field separated by dots, and a missing
+// field has an empty value.
+func matchFields(obj *unstructured.Unstructured, requirements fields.Requirements) bool {
+	for _, requirement := range requirements {
+		value := ""
+		if field, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(requirement.Field, ".")...); err == nil && found {
+			value = fmt.Sprint(field)
+		}
+		if (requirement.Operator == selection.NotEquals) == (value == requirement.Value) {
+			return false
+		}
+	}
+	return true
+}
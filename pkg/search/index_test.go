@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"reflect"
+	"testing"
+)
+
+func newPod(namespace, name, node string, podLabels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"spec":       map[string]interface{}{"nodeName": node},
+	}}
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(podLabels)
+	return obj
+}
+
+func newTestIndex() *Index {
+	index := NewIndex()
+	index.Upsert("a", newPod("default", "nginx-1", "node-1", map[string]string{"app": "nginx"}))
+	index.Upsert("a", newPod("default", "redis-1", "node-1", map[string]string{"app": "redis"}))
+	index.Upsert("b", newPod("default", "nginx-2", "node-2", map[string]string{"app": "nginx", "tier": "web"}))
+	index.Upsert("b", newPod("kube-system", "coredns", "node-2", nil))
+	deployment := &unstructured.Unstructured{}
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetNamespace("default")
+	deployment.SetName("nginx")
+	deployment.SetLabels(map[string]string{"app": "nginx"})
+	index.Upsert("a", deployment)
+	return index
+}
+
+func names(results []Result) []string {
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Cluster+"/"+result.Object.GetName())
+	}
+	return got
+}
+
+func TestIndex_Search(t *testing.T) {
+	pods := schema.GroupKind{Kind: "Pod"}
+	tests := []struct {
+		name    string
+		query   *Query
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "all kinds",
+			query: &Query{NamePrefix: "nginx"},
+			want:  []string{"a/nginx", "a/nginx-1", "b/nginx-2"},
+		},
+		{
+			name:  "label selector",
+			query: &Query{GroupKind: pods, LabelSelector: labels.SelectorFromSet(labels.Set{"app": "nginx"})},
+			want:  []string{"a/nginx-1", "b/nginx-2"},
+		},
+		{
+			name:  "label selector without equality",
+			query: &Query{GroupKind: pods, LabelSelector: mustParseLabels(t, "app,app!=redis")},
+			want:  []string{"a/nginx-1", "b/nginx-2"},
+		},
+		{
+			name:  "field selector",
+			query: &Query{GroupKind: pods, FieldSelector: fields.OneTermEqualSelector("spec.nodeName", "node-2")},
+			want:  []string{"b/nginx-2", "b/coredns"},
+		},
+		{
+			name:  "field selector not equal",
+			query: &Query{GroupKind: pods, FieldSelector: fields.OneTermNotEqualSelector("metadata.namespace", "default")},
+			want:  []string{"b/coredns"},
+		},
+		{
+			name:  "clusters and namespace",
+			query: &Query{GroupKind: pods, Clusters: []string{"b"}, Namespace: "default"},
+			want:  []string{"b/nginx-2"},
+		},
+		{
+			name:  "limit",
+			query: &Query{GroupKind: pods, Limit: 1},
+			want:  []string{"a/nginx-1"},
+		},
+		{
+			name:  "no match",
+			query: &Query{GroupKind: pods, LabelSelector: labels.SelectorFromSet(labels.Set{"app": "mysql"})},
+		},
+	}
+	index := newTestIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := index.Search(context.TODO(), tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Search() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := names(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	index := newTestIndex()
+	pods := schema.GroupKind{Kind: "Pod"}
+	nginx := labels.SelectorFromSet(labels.Set{"app": "nginx"})
+
+	index.Upsert("a", newPod("default", "nginx-1", "node-1", map[string]string{"app": "web"}))
+	results, _ := index.Search(context.TODO(), &Query{GroupKind: pods, LabelSelector: nginx})
+	if got, want := names(results), []string{"b/nginx-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() after relabeling = %v, want %v", got, want)
+	}
+
+	index.Delete("b", pods, "default", "nginx-2")
+	results, _ = index.Search(context.TODO(), &Query{GroupKind: pods, LabelSelector: nginx})
+	if len(results) != 0 {
+		t.Errorf("Search() after deleting = %v, want none", names(results))
+	}
+
+	index.RemoveCluster("a")
+	results, _ = index.Search(context.TODO(), &Query{})
+	if got, want := names(results), []string{"b/coredns"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() after removing cluster = %v, want %v", got, want)
+	}
+}
+
+func TestParseResources(t *testing.T) {
+	got, err := ParseResources("v1/Pod, apps/v1/Deployment")
+	if err != nil {
+		t.Fatalf("ParseResources() error = %v", err)
+	}
+	want := []schema.GroupVersionKind{
+		{Version: "v1", Kind: "Pod"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseResources() = %v, want %v", got, want)
+	}
+	for _, s := range []string{"Pod", "v1/", "a/b/c/Pod"} {
+		if _, err := ParseResources(s); err == nil {
+			t.Errorf("ParseResources(%q) expects an error", s)
+		}
+	}
+}
+
+func mustParseLabels(t *testing.T, s string) labels.Selector {
+	selector, err := labels.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return selector
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	"github.com/sumengzs/multi-cluster/pkg/pool"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"strings"
+	"time"
+)
+
+// Indexer subscribes to the resources of the kinds in the cache of every started
+// member cluster in the pool, and keeps them in the index. The resources of the
+// clusters removed or replaced in the pool are removed from the index, while the
+// ones of the stopped clusters are kept as they were last seen.
+type Indexer struct {
+	Pool  pool.Interface
+	Index *Index
+	// Resources are the kinds of the indexed resources.
+	Resources []schema.GroupVersionKind
+	// SyncPeriod is the period to subscribe to the newly started member clusters.
+	SyncPeriod time.Duration
+
+	watcher *pool.Watcher
+	// indexed are the clusters subscribed to by name.
+	indexed map[string]cluster.Interface
+}
+
+// Start subscribes to the member clusters until the context is done.
+func (i *Indexer) Start(ctx context.Context) error {
+	i.watcher = pool.NewWatcher(i.Pool)
+	i.indexed = make(map[string]cluster.Interface)
+	wait.UntilWithContext(ctx, i.sync, i.SyncPeriod)
+	return nil
+}
+
+// NeedLeaderElection returns false, every replica indexes the resources it serves.
+func (i *Indexer) NeedLeaderElection() bool {
+	return false
+}
+
+func (i *Indexer) sync(ctx context.Context) {
+	clusters := i.Pool.Clusters()
+	for name, clu := range i.indexed {
+		if clusters[name] != clu {
+			i.Index.RemoveCluster(name)
+			delete(i.indexed, name)
+		}
+	}
+	for name, clu := range clusters {
+		if clu.Status() < cluster.Started {
+			continue
+		}
+		i.indexed[name] = clu
+		for _, gvk := range i.Resources {
+			gvk, clu := gvk, clu
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(gvk)
+			if err := i.watcher.Watch(ctx, clu, gvk, obj, func(obj metav1.Object) {
+				i.update(ctx, clu, gvk, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()})
+			}); err != nil {
+				klog.Errorf("error indexing %s in cluster %s: %v", gvk, name, err)
+			}
+		}
+	}
+}
+
+// update indexes the resource as it is in the cache of the member cluster.
+func (i *Indexer) update(ctx context.Context, clu cluster.Interface, gvk schema.GroupVersionKind, key types.NamespacedName) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := clu.Cache().Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			i.Index.Delete(clu.Name(), gvk.GroupKind(), key.Namespace, key.Name)
+			return
+		}
+		klog.Errorf("error getting %s %s from cluster %s: %v", gvk.Kind, key, clu.Name(), err)
+		return
+	}
+	i.Index.Upsert(clu.Name(), obj)
+}
+
+// ParseResources parses the comma separated kinds in the form of apiVersion/kind,
+// e.g. v1/Pod,apps/v1/Deployment.
+func ParseResources(s string) ([]schema.GroupVersionKind, error) {
+	var resources []schema.GroupVersionKind
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		n := strings.LastIndex(item, "/")
+		if n <= 0 || n == len(item)-1 {
+			return nil, fmt.Errorf("invalid resource %q, expecting apiVersion/kind", item)
+		}
+		gv, err := schema.ParseGroupVersion(item[:n])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource %q: %s", item, err)
+		}
+		resources = append(resources, gv.WithKind(item[n+1:]))
+	}
+	return resources, nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/proxy"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Path is the path of the search API.
+const Path = "/apis/search/v1/resources"
+
+// Subresource is the subresource of the Cluster in the hub the callers are authorized to search.
+const Subresource = "search"
+
+// Response is the response of the search API.
+type Response struct {
+	Items []Result `json:"items"`
+}
+
+// Handler returns the HTTP handler of the search API, which accepts the query parameters:
+//   - group and kind of the resources, e.g. group=apps&kind=Deployment;
+//   - cluster, repeated for every cluster to search;
+//   - namespace of the resources;
+//   - prefix of the names of the resources;
+//   - labelSelector and fieldSelector in the form of kubectl selectors;
+//   - limit of the number of the results.
+//
+// The callers are authenticated by the authenticator, and only the resources in the clusters
+// they are allowed to get the search subresource of, e.g. get clusters/search, are returned.
+func Handler(searcher Searcher, authenticator proxy.Authenticator, authorizer proxy.Authorizer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok, err := authenticator.Authenticate(req.Context(), req)
+		if err != nil {
+			klog.Errorf("error authenticating search request: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !ok {
+			http.Error(w, "the request is not authenticated", http.StatusUnauthorized)
+			return
+		}
+		query, err := parseQuery(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the results are limited after the clusters the user is not allowed to search are filtered out
+		limit := query.Limit
+		query.Limit = 0
+		results, err := searcher.Search(req.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if results, err = authorized(req.Context(), authorizer, user, results); err != nil {
+			klog.Errorf("error authorizing search request of user %s: %v", user.Username, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if limit > 0 && len(results) > limit {
+			results = results[:limit]
+		}
+		if results == nil {
+			results = []Result{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&Response{Items: results}); err != nil {
+			klog.Errorf("error writing search response: %v", err)
+		}
+	})
+	return mux
+}
+
+// authorized returns the results in the clusters the user is allowed to search.
+func authorized(ctx context.Context, authorizer proxy.Authorizer, user *authenticationv1.UserInfo,
+	results []Result) ([]Result, error) {
+	allowed := make(map[string]bool)
+	var filtered []Result
+	for _, result := range results {
+		ok, checked := allowed[result.Cluster]
+		if !checked {
+			var err error
+			if ok, _, err = authorizer.Authorize(ctx, user, &authorizationv1.ResourceAttributes{
+				Verb:        "get",
+				Group:       v1beta1.Group,
+				Version:     v1beta1.Version,
+				Resource:    "clusters",
+				Subresource: Subresource,
+				Name:        result.Cluster,
+			}); err != nil {
+				return nil, err
+			}
+			allowed[result.Cluster] = ok
+		}
+		if ok {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered, nil
+}
+
+func parseQuery(req *http.Request) (*Query, error) {
+	values := req.URL.Query()
+	query := &Query{
+		GroupKind:  schema.GroupKind{Group: values.Get("group"), Kind: values.Get("kind")},
+		Clusters:   values["cluster"],
+		Namespace:  values.Get("namespace"),
+		NamePrefix: values.Get("prefix"),
+	}
+	var err error
+	if s := values.Get("labelSelector"); len(s) != 0 {
+		if query.LabelSelector, err = labels.Parse(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := values.Get("fieldSelector"); len(s) != 0 {
+		if query.FieldSelector, err = fields.ParseSelector(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := values.Get("limit"); len(s) != 0 {
+		if query.Limit, err = strconv.Atoi(s); err != nil || query.Limit < 0 {
+			return nil, errors.New("limit must be a non-negative integer")
+		}
+	}
+	return query, nil
+}
+
+// Server serves the search API over HTTPS to the authenticated and authorized callers.
+type Server struct {
+	// Addr is the address the server binds to.
+	Addr string
+	// CertFile and KeyFile are the serving certificate and key of the server.
+	CertFile      string
+	KeyFile       string
+	Searcher      Searcher
+	Authenticator proxy.Authenticator
+	Authorizer    proxy.Authorizer
+}
+
+// Start serves the search API until the context is done.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           Handler(s.Searcher, s.Authenticator, s.Authorizer),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("error shutting down search server: %v", err)
+		}
+	}()
+	klog.Infof("serving search API on %s", s.Addr)
+	if err := srv.ListenAndServeTLS(s.CertFile, s.KeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection returns false, every replica serves the search API.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
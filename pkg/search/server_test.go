@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeAuth authenticates the bearer token alice, and allows alice to search the cluster b.
+type fakeAuth struct{}
+
+func (fakeAuth) Authenticate(_ context.Context, req *http.Request) (*authenticationv1.UserInfo, bool, error) {
+	if req.Header.Get("Authorization") != "Bearer alice" {
+		return nil, false, nil
+	}
+	return &authenticationv1.UserInfo{Username: "alice"}, true, nil
+}
+
+func (fakeAuth) Authorize(_ context.Context, user *authenticationv1.UserInfo,
+	attributes *authorizationv1.ResourceAttributes) (bool, string, error) {
+	allowed := user.Username == "alice" && attributes.Verb == "get" && attributes.Resource == "clusters" &&
+		attributes.Subresource == Subresource && attributes.Name == "b"
+	return allowed, "not allowed", nil
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(newTestIndex(), fakeAuth{}, fakeAuth{})
+	tests := []struct {
+		name      string
+		url       string
+		token     string
+		wantCode  int
+		wantItems int
+	}{
+		{name: "search", url: Path + "?kind=Pod&labelSelector=app%3Dnginx", token: "alice", wantCode: http.StatusOK, wantItems: 1},
+		{name: "not allowed cluster", url: Path + "?kind=Pod&cluster=a", token: "alice", wantCode: http.StatusOK},
+		{name: "limit after authorization", url: Path + "?prefix=nginx&limit=1", token: "alice", wantCode: http.StatusOK, wantItems: 1},
+		{name: "no results", url: Path + "?group=apps&kind=Deployment&prefix=redis", token: "alice", wantCode: http.StatusOK},
+		{name: "not authenticated", url: Path + "?kind=Pod", wantCode: http.StatusUnauthorized},
+		{name: "invalid label selector", url: Path + "?labelSelector=app%3D%3D%3D", token: "alice", wantCode: http.StatusBadRequest},
+		{name: "invalid limit", url: Path + "?limit=-1", token: "alice", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if len(tt.token) != 0 {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			response := &Response{}
+			if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+				t.Fatalf("error decoding response: %v", err)
+			}
+			if len(response.Items) != tt.wantItems {
+				t.Errorf("items = %d, want %d", len(response.Items), tt.wantItems)
+			}
+		})
+	}
+}
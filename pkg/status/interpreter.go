@@ -0,0 +1,215 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Reflection is the status of a resource in a member cluster reflected by an interpreter.
+type Reflection struct {
+	// Status is the part of the status of the resource worth aggregating.
+	Status map[string]interface{}
+	// Ready is true if the resource is rolled out and healthy.
+	Ready bool
+	// Message is the reason why the resource is not ready.
+	Message string
+}
+
+// Interpreter reflects the status of a resource of a kind.
+type Interpreter func(obj *unstructured.Unstructured) (*Reflection, error)
+
+var interpreters = map[schema.GroupKind]Interpreter{
+	{Group: appsv1.GroupName, Kind: "Deployment"}:  interpretDeployment,
+	{Group: appsv1.GroupName, Kind: "StatefulSet"}: interpretStatefulSet,
+	{Group: batchv1.GroupName, Kind: "Job"}:        interpretJob,
+	{Group: corev1.GroupName, Kind: "Service"}:     interpretService,
+}
+
+// Interpret reflects the status of the resource by the interpreter of its kind.
+// The resources of the other kinds reflect their whole status, and are ready if
+// they have no Ready condition or the condition is true.
+func Interpret(obj *unstructured.Unstructured) (*Reflection, error) {
+	if interpreter, ok := interpreters[obj.GroupVersionKind().GroupKind()]; ok {
+		return interpreter(obj)
+	}
+	status, _, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil {
+		return nil, err
+	}
+	reflection := &Reflection{Status: status, Ready: true}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] != string(metav1.ConditionTrue) {
+			reflection.Ready = false
+			reflection.Message, _ = condition["message"].(string)
+		}
+	}
+	return reflection, nil
+}
+
+func interpretDeployment(obj *unstructured.Unstructured) (*Reflection, error) {
+	deployment := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+		return nil, err
+	}
+	reflection, err := newReflection(&appsv1.DeploymentStatus{
+		Replicas:            deployment.Status.Replicas,
+		UpdatedReplicas:     deployment.Status.UpdatedReplicas,
+		ReadyReplicas:       deployment.Status.ReadyReplicas,
+		AvailableReplicas:   deployment.Status.AvailableReplicas,
+		UnavailableReplicas: deployment.Status.UnavailableReplicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := replicasOrDefault(deployment.Spec.Replicas)
+	status := &deployment.Status
+	switch {
+	case deployment.Generation > status.ObservedGeneration:
+		reflection.Message = "waiting for the spec update to be observed"
+	case status.UpdatedReplicas < replicas:
+		reflection.Message = fmt.Sprintf("%d of %d replicas are updated", status.UpdatedReplicas, replicas)
+	case status.Replicas > status.UpdatedReplicas:
+		reflection.Message = fmt.Sprintf("%d old replicas are pending termination", status.Replicas-status.UpdatedReplicas)
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		reflection.Message = fmt.Sprintf("%d of %d updated replicas are available", status.AvailableReplicas, status.UpdatedReplicas)
+	default:
+		reflection.Ready = true
+	}
+	return reflection, nil
+}
+
+func interpretStatefulSet(obj *unstructured.Unstructured) (*Reflection, error) {
+	sts := &appsv1.StatefulSet{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, sts); err != nil {
+		return nil, err
+	}
+	reflection, err := newReflection(&appsv1.StatefulSetStatus{
+		Replicas:          sts.Status.Replicas,
+		ReadyReplicas:     sts.Status.ReadyReplicas,
+		CurrentReplicas:   sts.Status.CurrentReplicas,
+		UpdatedReplicas:   sts.Status.UpdatedReplicas,
+		AvailableReplicas: sts.Status.AvailableReplicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := replicasOrDefault(sts.Spec.Replicas)
+	status := &sts.Status
+	var partition int32
+	if rolling := sts.Spec.UpdateStrategy.RollingUpdate; rolling != nil && rolling.Partition != nil {
+		partition = *rolling.Partition
+	}
+	switch {
+	case status.ObservedGeneration == 0 || sts.Generation > status.ObservedGeneration:
+		reflection.Message = "waiting for the spec update to be observed"
+	case status.ReadyReplicas < replicas:
+		reflection.Message = fmt.Sprintf("%d of %d replicas are ready", status.ReadyReplicas, replicas)
+	case sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType:
+		reflection.Ready = true
+	case partition > 0 && status.UpdatedReplicas < replicas-partition:
+		reflection.Message = fmt.Sprintf("%d of %d replicas above the partition are updated",
+			status.UpdatedReplicas, replicas-partition)
+	case partition == 0 && status.UpdateRevision != status.CurrentRevision:
+		reflection.Message = fmt.Sprintf("waiting for the replicas to be updated to revision %s", status.UpdateRevision)
+	default:
+		reflection.Ready = true
+	}
+	return reflection, nil
+}
+
+func interpretJob(obj *unstructured.Unstructured) (*Reflection, error) {
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job); err != nil {
+		return nil, err
+	}
+	reflection, err := newReflection(&batchv1.JobStatus{
+		StartTime:      job.Status.StartTime,
+		CompletionTime: job.Status.CompletionTime,
+		Active:         job.Status.Active,
+		Succeeded:      job.Status.Succeeded,
+		Failed:         job.Status.Failed,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reflection.Message = fmt.Sprintf("%d active, %d succeeded and %d failed pods", job.Status.Active,
+		job.Status.Succeeded, job.Status.Failed)
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			reflection.Ready, reflection.Message = true, ""
+		case batchv1.JobFailed:
+			reflection.Message = fmt.Sprintf("job failed: %s", condition.Message)
+		}
+	}
+	return reflection, nil
+}
+
+func interpretService(obj *unstructured.Unstructured) (*Reflection, error) {
+	svc := &corev1.Service{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, svc); err != nil {
+		return nil, err
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return &Reflection{Ready: true}, nil
+	}
+	reflection, err := newReflection(&corev1.ServiceStatus{LoadBalancer: svc.Status.LoadBalancer})
+	if err != nil {
+		return nil, err
+	}
+	if len(svc.Status.LoadBalancer.Ingress) == 0 {
+		reflection.Message = "waiting for the load balancer ingress"
+	} else {
+		reflection.Ready = true
+	}
+	return reflection, nil
+}
+
+// newReflection returns a reflection of the status, which is not ready.
+func newReflection(status interface{}) (*Reflection, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return nil, err
+	}
+	return &Reflection{Status: object}, nil
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"reflect"
+	"testing"
+)
+
+func newObject(apiVersion, kind string, generation int64, spec, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default", "generation": generation},
+		"spec":       spec,
+		"status":     status,
+	}}
+}
+
+func TestInterpret(t *testing.T) {
+	tests := []struct {
+		name        string
+		obj         *unstructured.Unstructured
+		wantReady   bool
+		wantMessage string
+		wantStatus  map[string]interface{}
+	}{
+		{
+			name: "deployment available",
+			obj: newObject("apps/v1", "Deployment", 2, map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2),
+				"readyReplicas": int64(2), "availableReplicas": int64(2),
+			}),
+			wantReady: true,
+			wantStatus: map[string]interface{}{
+				"replicas": int64(2), "updatedReplicas": int64(2), "readyReplicas": int64(2), "availableReplicas": int64(2),
+			},
+		},
+		{
+			name: "deployment rolling out",
+			obj: newObject("apps/v1", "Deployment", 2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+				"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(1),
+			}),
+			wantMessage: "1 of 3 replicas are updated",
+		},
+		{
+			name: "deployment spec not observed",
+			obj: newObject("apps/v1", "Deployment", 3, map[string]interface{}{}, map[string]interface{}{
+				"observedGeneration": int64(2),
+			}),
+			wantMessage: "waiting for the spec update to be observed",
+		},
+		{
+			name: "statefulset updating",
+			obj: newObject("apps/v1", "StatefulSet", 1, map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+				"observedGeneration": int64(1), "replicas": int64(2), "readyReplicas": int64(2),
+				"currentRevision": "web-1", "updateRevision": "web-2",
+			}),
+			wantMessage: "waiting for the replicas to be updated to revision web-2",
+		},
+		{
+			name: "job complete",
+			obj: newObject("batch/v1", "Job", 1, map[string]interface{}{}, map[string]interface{}{
+				"succeeded":  int64(1),
+				"conditions": []interface{}{map[string]interface{}{"type": "Complete", "status": "True"}},
+			}),
+			wantReady: true,
+		},
+		{
+			name: "job failed",
+			obj: newObject("batch/v1", "Job", 1, map[string]interface{}{}, map[string]interface{}{
+				"failed": int64(6),
+				"conditions": []interface{}{map[string]interface{}{
+					"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit",
+				}},
+			}),
+			wantMessage: "job failed: Job has reached the specified backoff limit",
+		},
+		{
+			name: "load balancer pending",
+			obj: newObject("v1", "Service", 0, map[string]interface{}{"type": "LoadBalancer"}, map[string]interface{}{
+				"loadBalancer": map[string]interface{}{},
+			}),
+			wantMessage: "waiting for the load balancer ingress",
+		},
+		{
+			name:      "cluster ip service",
+			obj:       newObject("v1", "Service", 0, map[string]interface{}{"type": "ClusterIP"}, map[string]interface{}{}),
+			wantReady: true,
+		},
+		{
+			name: "custom resource not ready",
+			obj: newObject("example.com/v1", "Database", 1, map[string]interface{}{}, map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "False", "message": "provisioning"}},
+			}),
+			wantMessage: "provisioning",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpret(tt.obj)
+			if err != nil {
+				t.Fatalf("Interpret() error = %v", err)
+			}
+			if got.Ready != tt.wantReady {
+				t.Errorf("Interpret() ready = %v, want %v", got.Ready, tt.wantReady)
+			}
+			if len(tt.wantMessage) != 0 && got.Message != tt.wantMessage {
+				t.Errorf("Interpret() message = %q, want %q", got.Message, tt.wantMessage)
+			}
+			if tt.wantStatus != nil && !reflect.DeepEqual(got.Status, tt.wantStatus) {
+				t.Errorf("Interpret() status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
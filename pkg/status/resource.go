@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"github.com/sumengzs/multi-cluster/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sort"
+)
+
+// Summary collects the node and resource summary of the member cluster.
+// Nodes and pods are listed from the cache of the member cluster, so the informers are
+// started on the first call and the later calls do not request the member api server.
+func Summary(ctx context.Context, clu cluster.Interface, nodePoolLabel string) (*v1beta1.NodeSummary, *v1beta1.ResourceSummary, error) {
+	nodes := &corev1.NodeList{}
+	if err := clu.Cache().List(ctx, nodes); err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %s", err)
+	}
+	pods := &corev1.PodList{}
+	if err := clu.Cache().List(ctx, pods); err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods: %s", err)
+	}
+	return NodeSummary(nodes.Items), ResourceSummary(nodes.Items, pods.Items, nodePoolLabel), nil
+}
+
+// NodeSummary returns the total and ready number of the nodes, the capacity of all nodes
+// and the allocatable resources of the ready nodes.
+func NodeSummary(nodes []corev1.Node) *v1beta1.NodeSummary {
+	summary := &v1beta1.NodeSummary{Capacity: corev1.ResourceList{}, Allocatable: corev1.ResourceList{}}
+	for i := range nodes {
+		summary.TotalNum++
+		addResourceList(summary.Capacity, nodes[i].Status.Capacity)
+		if nodeReady(&nodes[i]) {
+			summary.ReadyNum++
+			addResourceList(summary.Allocatable, nodes[i].Status.Allocatable)
+		}
+	}
+	return summary
+}
+
+// ResourceSummary returns the resource usage of the ready and schedulable nodes,
+// and the usage of every node pool grouped by nodePoolLabel if it is not empty.
+func ResourceSummary(nodes []corev1.Node, pods []corev1.Pod, nodePoolLabel string) *v1beta1.ResourceSummary {
+	total := &usage{}
+	pools := make(map[string]*usage)
+	nodeUsages := make(map[string][]*usage, len(nodes))
+
+	for i := range nodes {
+		node := &nodes[i]
+		if !nodeReady(node) || node.Spec.Unschedulable {
+			continue
+		}
+		usages := []*usage{total}
+		if pool, ok := node.Labels[nodePoolLabel]; ok && len(nodePoolLabel) != 0 {
+			if _, ok = pools[pool]; !ok {
+				pools[pool] = &usage{}
+			}
+			usages = append(usages, pools[pool])
+		}
+		for _, u := range usages {
+			addResourceList(u.allocatable(), node.Status.Allocatable)
+		}
+		nodeUsages[node.Name] = usages
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		usages, ok := nodeUsages[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		requests := PodRequests(pod)
+		for _, u := range usages {
+			addResourceList(u.allocated(), requests)
+		}
+	}
+
+	summary := &v1beta1.ResourceSummary{ResourceUsage: total.toAPI()}
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		summary.NodePools = append(summary.NodePools, v1beta1.NodePoolSummary{
+			Name:          name,
+			ResourceUsage: pools[name].toAPI(),
+		})
+	}
+	return summary
+}
+
+// PodRequests returns the resources requested by the pod, it is the larger one of
+// the sum of the containers requests and the max of the init containers requests,
+// plus the pod overhead. A pod always requests one from the pods resource.
+func PodRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResourceList(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if value, ok := requests[name]; !ok || quantity.Cmp(value) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResourceList(requests, pod.Spec.Overhead)
+	addResourceList(requests, corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)})
+	return requests
+}
+
+type usage struct {
+	allocatableList corev1.ResourceList
+	allocatedList   corev1.ResourceList
+}
+
+func (u *usage) allocatable() corev1.ResourceList {
+	if u.allocatableList == nil {
+		u.allocatableList = corev1.ResourceList{}
+	}
+	return u.allocatableList
+}
+
+func (u *usage) allocated() corev1.ResourceList {
+	if u.allocatedList == nil {
+		u.allocatedList = corev1.ResourceList{}
+	}
+	return u.allocatedList
+}
+
+func (u *usage) toAPI() v1beta1.ResourceUsage {
+	available := corev1.ResourceList{}
+	for name, quantity := range u.allocatableList {
+		value := quantity.DeepCopy()
+		if allocated, ok := u.allocatedList[name]; ok {
+			value.Sub(allocated)
+		}
+		if value.Sign() < 0 {
+			value = *resource.NewQuantity(0, value.Format)
+		}
+		available[name] = value
+	}
+	return v1beta1.ResourceUsage{
+		Allocatable: u.allocatableList,
+		Allocated:   u.allocatedList,
+		Available:   available,
+	}
+}
+
+func addResourceList(list, newList corev1.ResourceList) {
+	for name, quantity := range newList {
+		if value, ok := list[name]; ok {
+			value.Add(quantity)
+			list[name] = value
+		} else {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
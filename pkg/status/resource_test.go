@@ -0,0 +1,175 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newNode(name, pool string, ready bool, cpu, memory string) corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	node := corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: resourceList(cpu, memory, "110"),
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+	node.Status.Capacity = node.Status.Allocatable.DeepCopy()
+	if len(pool) != 0 {
+		node.Labels = map[string]string{v1beta1.NodePoolLabel: pool}
+	}
+	return node
+}
+
+func newPod(nodeName string, phase corev1.PodPhase, cpu, memory string) corev1.Pod {
+	return corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse(cpu),
+						corev1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func resourceList(cpu, memory, pods string) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+		corev1.ResourcePods:   resource.MustParse(pods),
+	}
+}
+
+func TestNodeSummary(t *testing.T) {
+	nodes := []corev1.Node{
+		newNode("node-1", "", true, "4", "8Gi"),
+		newNode("node-2", "", false, "4", "8Gi"),
+	}
+	want := &v1beta1.NodeSummary{
+		TotalNum:    2,
+		ReadyNum:    1,
+		Capacity:    resourceList("8", "16Gi", "220"),
+		Allocatable: resourceList("4", "8Gi", "110"),
+	}
+	if got := NodeSummary(nodes); !apiequality.Semantic.DeepEqual(got, want) {
+		t.Errorf("NodeSummary() = %v, want %v", got, want)
+	}
+}
+
+func TestResourceSummary(t *testing.T) {
+	nodes := []corev1.Node{
+		newNode("node-1", "pool-a", true, "4", "8Gi"),
+		newNode("node-2", "pool-b", true, "8", "16Gi"),
+		newNode("node-3", "pool-b", false, "8", "16Gi"),
+	}
+	pods := []corev1.Pod{
+		newPod("node-1", corev1.PodRunning, "1", "2Gi"),
+		newPod("node-1", corev1.PodRunning, "4", "8Gi"),
+		newPod("node-2", corev1.PodPending, "2", "4Gi"),
+		newPod("node-2", corev1.PodSucceeded, "2", "4Gi"),
+		newPod("node-3", corev1.PodRunning, "2", "4Gi"),
+		newPod("", corev1.PodPending, "2", "4Gi"),
+	}
+
+	tests := []struct {
+		name          string
+		nodePoolLabel string
+		want          *v1beta1.ResourceSummary
+	}{
+		{
+			name:          "without node pools",
+			nodePoolLabel: "",
+			want: &v1beta1.ResourceSummary{
+				ResourceUsage: v1beta1.ResourceUsage{
+					Allocatable: resourceList("12", "24Gi", "220"),
+					Allocated:   resourceList("7", "14Gi", "3"),
+					Available:   resourceList("5", "10Gi", "217"),
+				},
+			},
+		},
+		{
+			name:          "with node pools",
+			nodePoolLabel: v1beta1.NodePoolLabel,
+			want: &v1beta1.ResourceSummary{
+				ResourceUsage: v1beta1.ResourceUsage{
+					Allocatable: resourceList("12", "24Gi", "220"),
+					Allocated:   resourceList("7", "14Gi", "3"),
+					Available:   resourceList("5", "10Gi", "217"),
+				},
+				NodePools: []v1beta1.NodePoolSummary{
+					{
+						Name: "pool-a",
+						ResourceUsage: v1beta1.ResourceUsage{
+							Allocatable: resourceList("4", "8Gi", "110"),
+							Allocated:   resourceList("5", "10Gi", "2"),
+							Available:   resourceList("0", "0", "108"),
+						},
+					},
+					{
+						Name: "pool-b",
+						ResourceUsage: v1beta1.ResourceUsage{
+							Allocatable: resourceList("8", "16Gi", "110"),
+							Allocated:   resourceList("2", "4Gi", "1"),
+							Available:   resourceList("6", "12Gi", "109"),
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResourceSummary(nodes, pods, tt.nodePoolLabel)
+			if !apiequality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("ResourceSummary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	pod := newPod("node-1", corev1.PodRunning, "1", "1Gi")
+	pod.Spec.InitContainers = []corev1.Container{{
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+	}}
+	pod.Spec.Overhead = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+
+	want := resourceList("2100m", "1Gi", "1")
+	if got := PodRequests(&pod); !apiequality.Semantic.DeepEqual(got, want) {
+		t.Errorf("PodRequests() = %v, want %v", got, want)
+	}
+}
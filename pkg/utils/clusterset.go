@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sort"
+)
+
+// ResolveClusterSet returns the clusters that are members of the set, sorted by name.
+func ResolveClusterSet(set *v1beta1.ClusterSet, clusters []v1beta1.Cluster) ([]v1beta1.Cluster, error) {
+	selector := labels.Nothing()
+	if set.Spec.ClusterSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(set.Spec.ClusterSelector); err != nil {
+			return nil, err
+		}
+	}
+	names := make(map[string]bool, len(set.Spec.ClusterNames))
+	for _, name := range set.Spec.ClusterNames {
+		names[name] = true
+	}
+
+	var members []v1beta1.Cluster
+	for _, clu := range clusters {
+		if names[clu.Name] || selector.Matches(labels.Set(clu.Labels)) {
+			members = append(members, clu)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+// IsClusterReady returns whether the Ready condition of the cluster is true.
+func IsClusterReady(clu *v1beta1.Cluster) bool {
+	return meta.IsStatusConditionTrue(clu.Status.Conditions, v1beta1.ClusterConditionReady)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"github.com/sumengzs/multi-cluster/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestResolveClusterSet(t *testing.T) {
+	clusters := []v1beta1.Cluster{
+		{ObjectMeta: metav1.ObjectMeta{Name: "prod-eu-2", Labels: map[string]string{"env": "prod", "region": "eu"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "prod-eu-1", Labels: map[string]string{"env": "prod", "region": "eu"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "prod-us-1", Labels: map[string]string{"env": "prod", "region": "us"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "dev-eu-1", Labels: map[string]string{"env": "dev", "region": "eu"}}},
+	}
+	tests := []struct {
+		name string
+		spec v1beta1.ClusterSetSpec
+		want []string
+	}{
+		{
+			name: "nil selector selects nothing",
+			spec: v1beta1.ClusterSetSpec{},
+			want: nil,
+		},
+		{
+			name: "empty selector selects all",
+			spec: v1beta1.ClusterSetSpec{ClusterSelector: &metav1.LabelSelector{}},
+			want: []string{"dev-eu-1", "prod-eu-1", "prod-eu-2", "prod-us-1"},
+		},
+		{
+			name: "selector and names",
+			spec: v1beta1.ClusterSetSpec{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod", "region": "eu"}},
+				ClusterNames:    []string{"dev-eu-1", "not-exist"},
+			},
+			want: []string{"dev-eu-1", "prod-eu-1", "prod-eu-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members, err := ResolveClusterSet(&v1beta1.ClusterSet{Spec: tt.spec}, clusters)
+			if err != nil {
+				t.Fatalf("ResolveClusterSet() error = %v", err)
+			}
+			var got []string
+			for _, member := range members {
+				got = append(got, member.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveClusterSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,9 +20,11 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"github.com/sumengzs/multi-cluster/api/v1beta1"
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
@@ -37,30 +39,32 @@ const PrivateKey = "privateKey"
 // BuildConfig return rest config for cluster.
 func BuildConfig(clusterName string, connect v1beta1.ConnectConfig, secretGetter SecretGetter) (*rest.Config, error) {
 	if len(connect.Endpoint) == 0 {
-		return nil, fmt.Errorf("cluster %s api endpoint cannot be empty", clusterName)
+		return nil, &ConfigError{Reason: v1beta1.ReasonInvalidConfig,
+			Err: fmt.Errorf("cluster %s api endpoint cannot be empty", clusterName)}
 	}
 
 	config, err := clientcmd.BuildConfigFromFlags(connect.Endpoint, "")
 	if err != nil {
-		return nil, err
+		return nil, &ConfigError{Reason: v1beta1.ReasonInvalidConfig, Err: err}
 	}
 
 	// Handle configuration.
 	switch {
 	case connect.Secret != nil:
 		if err = buildConfigWithSecret(connect.Secret, secretGetter, connect.InsecureSkipTLSVerification, config); err != nil {
-			return nil, fmt.Errorf("cluster %s build config with secret failed: %s", clusterName, err)
+			return nil, wrapConfigError(err, "cluster %s build config with secret failed", clusterName)
 		}
 	case connect.Config != nil:
 		if config, err = buildConfigWithConfig(connect.Config, secretGetter); err != nil {
-			return nil, fmt.Errorf("cluster %s build config with config failed: %s", clusterName, err)
+			return nil, wrapConfigError(err, "cluster %s build config with config failed", clusterName)
 		}
 	case connect.Token != nil:
 		if err = buildConfigWithToken(connect.Token, connect.InsecureSkipTLSVerification, config); err != nil {
-			return nil, fmt.Errorf("cluster %s build config with token failed: %s", clusterName, err)
+			return nil, wrapConfigError(err, "cluster %s build config with token failed", clusterName)
 		}
 	default:
-		return nil, fmt.Errorf("cluster %s secret, config, token cannot be empty as the same time", clusterName)
+		return nil, &ConfigError{Reason: v1beta1.ReasonInvalidConfig,
+			Err: fmt.Errorf("cluster %s secret, config, token cannot be empty as the same time", clusterName)}
 	}
 
 	// Handle proxy configuration.
@@ -68,7 +72,7 @@ func BuildConfig(clusterName string, connect v1beta1.ConnectConfig, secretGetter
 		proxy, err := url.Parse(connect.ProxyURL)
 		if err != nil {
 			klog.Errorf("parse proxy error. %v", err)
-			return nil, err
+			return nil, &ConfigError{Reason: v1beta1.ReasonInvalidConfig, Err: err}
 		}
 		config.Proxy = http.ProxyURL(proxy)
 	}
@@ -86,12 +90,15 @@ func buildConfigWithConfig(ref *v1beta1.ConfigRef, secretGetter SecretGetter) (*
 			Name:      ref.Secret.Name,
 		})
 		if err != nil {
-			return nil, err
+			return nil, secretError(err)
 		}
 		privateKey, err := SecretToRSACerts(secret)
+		if err != nil {
+			return nil, &ConfigError{Reason: v1beta1.ReasonDecryptFailed, Err: err}
+		}
 		kubeConfig, err = RSADecryptByPrivateKey(kubeConfig, privateKey)
 		if err != nil {
-			return nil, err
+			return nil, &ConfigError{Reason: v1beta1.ReasonDecryptFailed, Err: err}
 		}
 	}
 	clientConfig, err := clientcmd.NewClientConfigFromBytes(kubeConfig)
@@ -111,7 +118,7 @@ func buildConfigWithSecret(ref *v1beta1.SecretRef, secretGetter SecretGetter,
 		Name:      ref.Name,
 	})
 	if err != nil {
-		return err
+		return secretError(err)
 	}
 	config.BearerToken = string(secret.Data[v1beta1.SecretTokenKey])
 	if insecureSkipTLSVerification {
@@ -138,6 +145,40 @@ func buildConfigWithToken(ref *v1beta1.TokenRef, insecureSkipTLSVerification boo
 	return nil
 }
 
+// ConfigError is the error of building the rest config of a cluster,
+// the reason is the machine-readable reason of the failure.
+type ConfigError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// wrapConfigError wraps the error with the message, keeping the reason of the error,
+// the reason of the errors without one is InvalidConfig.
+func wrapConfigError(err error, format string, args ...interface{}) error {
+	reason := v1beta1.ReasonInvalidConfig
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		reason = configErr.Reason
+	}
+	return &ConfigError{Reason: reason, Err: fmt.Errorf(format+": %s", append(args, err)...)}
+}
+
+// secretError returns the error of getting a secret referenced by the connection config.
+func secretError(err error) error {
+	if apierrors.IsNotFound(err) {
+		return &ConfigError{Reason: v1beta1.ReasonSecretNotFound, Err: err}
+	}
+	return &ConfigError{Reason: v1beta1.ReasonSecretUnavailable, Err: err}
+}
+
 type ClusterGetter func(string) (*v1beta1.Cluster, error)
 type SecretGetter func(types.NamespacedName) (*v1.Secret, error)
 
@@ -156,6 +197,9 @@ func SecretToRSACerts(secret *v1.Secret) (*rsa.PrivateKey, error) {
 	}
 	buf := secret.Data[PrivateKey]
 	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("secret has no PEM encoded private key")
+	}
 	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
 
@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// TolerationsTolerateTaint checks if taint is tolerated by any of the tolerations.
+func TolerationsTolerateTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindUntoleratedTaint returns the first taint with one of the effects that is not tolerated by the tolerations.
+// All the effects are checked if no effect is given.
+func FindUntoleratedTaint(taints []corev1.Taint, tolerations []corev1.Toleration, effects ...corev1.TaintEffect) (corev1.Taint, bool) {
+	for _, taint := range taints {
+		if len(effects) != 0 && !containsEffect(effects, taint.Effect) {
+			continue
+		}
+		if !TolerationsTolerateTaint(tolerations, &taint) {
+			return taint, true
+		}
+	}
+	return corev1.Taint{}, false
+}
+
+// FindEvictingTaint returns the first NoExecute taint that is not tolerated by the tolerations, or is only
+// tolerated for the tolerationSeconds which have elapsed at now since the taint was added.
+func FindEvictingTaint(taints []corev1.Taint, tolerations []corev1.Toleration, now time.Time) (corev1.Taint, bool) {
+	for _, taint := range taints {
+		if taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		tolerated, forever := false, false
+		var seconds int64
+		for i := range tolerations {
+			if !tolerations[i].ToleratesTaint(&taint) {
+				continue
+			}
+			if tolerations[i].TolerationSeconds == nil {
+				forever = true
+				break
+			}
+			if !tolerated || *tolerations[i].TolerationSeconds > seconds {
+				seconds = *tolerations[i].TolerationSeconds
+			}
+			tolerated = true
+		}
+		switch {
+		case forever:
+			continue
+		case !tolerated:
+			return taint, true
+		case seconds <= 0:
+			return taint, true
+		case taint.TimeAdded != nil && !now.Before(taint.TimeAdded.Add(time.Duration(seconds)*time.Second)):
+			return taint, true
+		}
+	}
+	return corev1.Taint{}, false
+}
+
+// AddOrRemoveTaints returns the taints with toAdd added and toRemove removed, and whether the taints are changed.
+// Taints are matched by key and effect, TimeAdded of the added taints is set to now if it is empty.
+func AddOrRemoveTaints(taints []corev1.Taint, toAdd, toRemove []corev1.Taint) ([]corev1.Taint, bool) {
+	var changed bool
+	result := make([]corev1.Taint, 0, len(taints)+len(toAdd))
+	for _, taint := range taints {
+		if containsTaint(toRemove, &taint) {
+			changed = true
+			continue
+		}
+		result = append(result, taint)
+	}
+	for _, taint := range toAdd {
+		if containsTaint(result, &taint) {
+			continue
+		}
+		if taint.TimeAdded == nil {
+			now := metav1.Now()
+			taint.TimeAdded = &now
+		}
+		result = append(result, taint)
+		changed = true
+	}
+	return result, changed
+}
+
+func containsTaint(taints []corev1.Taint, taint *corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEffect(effects []corev1.TaintEffect, effect corev1.TaintEffect) bool {
+	for _, e := range effects {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
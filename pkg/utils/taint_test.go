@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The Multi Cluster Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+var (
+	maintenanceTaint = corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}
+	drainingTaint    = corev1.Taint{Key: "draining", Value: "true", Effect: corev1.TaintEffectNoExecute}
+)
+
+func TestFindUntoleratedTaint(t *testing.T) {
+	tests := []struct {
+		name        string
+		taints      []corev1.Taint
+		tolerations []corev1.Toleration
+		effects     []corev1.TaintEffect
+		want        bool
+	}{
+		{
+			name:   "no taints",
+			taints: nil,
+			want:   false,
+		},
+		{
+			name:   "taint not tolerated",
+			taints: []corev1.Taint{maintenanceTaint},
+			want:   true,
+		},
+		{
+			name:   "taint tolerated by exists operator",
+			taints: []corev1.Taint{maintenanceTaint},
+			tolerations: []corev1.Toleration{
+				{Key: "maintenance", Operator: corev1.TolerationOpExists},
+			},
+			want: false,
+		},
+		{
+			name:   "taint value not tolerated",
+			taints: []corev1.Taint{drainingTaint},
+			tolerations: []corev1.Toleration{
+				{Key: "draining", Operator: corev1.TolerationOpEqual, Value: "false", Effect: corev1.TaintEffectNoExecute},
+			},
+			want: true,
+		},
+		{
+			name:    "taint effect ignored",
+			taints:  []corev1.Taint{drainingTaint},
+			effects: []corev1.TaintEffect{corev1.TaintEffectNoSchedule},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := FindUntoleratedTaint(tt.taints, tt.tolerations, tt.effects...); got != tt.want {
+				t.Errorf("FindUntoleratedTaint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindEvictingTaint(t *testing.T) {
+	now := time.Now()
+	added := metav1.NewTime(now.Add(-time.Minute))
+	unreachable := corev1.Taint{Key: "unreachable", Effect: corev1.TaintEffectNoExecute, TimeAdded: &added}
+	seconds := func(s int64) *int64 { return &s }
+	tests := []struct {
+		name        string
+		taints      []corev1.Taint
+		tolerations []corev1.Toleration
+		want        bool
+	}{
+		{
+			name:   "no schedule taint never evicts",
+			taints: []corev1.Taint{maintenanceTaint},
+			want:   false,
+		},
+		{
+			name:   "no execute taint not tolerated",
+			taints: []corev1.Taint{unreachable},
+			want:   true,
+		},
+		{
+			name:   "no execute taint tolerated forever",
+			taints: []corev1.Taint{unreachable},
+			tolerations: []corev1.Toleration{
+				{Key: "unreachable", Operator: corev1.TolerationOpExists},
+			},
+			want: false,
+		},
+		{
+			name:   "no execute taint tolerated for a while",
+			taints: []corev1.Taint{unreachable},
+			tolerations: []corev1.Toleration{
+				{Key: "unreachable", Operator: corev1.TolerationOpExists, TolerationSeconds: seconds(300)},
+			},
+			want: false,
+		},
+		{
+			name:   "no execute taint tolerated seconds elapsed",
+			taints: []corev1.Taint{unreachable},
+			tolerations: []corev1.Toleration{
+				{Key: "unreachable", Operator: corev1.TolerationOpExists, TolerationSeconds: seconds(30)},
+			},
+			want: true,
+		},
+		{
+			name:   "longest tolerated seconds",
+			taints: []corev1.Taint{unreachable},
+			tolerations: []corev1.Toleration{
+				{Key: "unreachable", Operator: corev1.TolerationOpExists, TolerationSeconds: seconds(30)},
+				{Operator: corev1.TolerationOpExists, TolerationSeconds: seconds(300)},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := FindEvictingTaint(tt.taints, tt.tolerations, now); got != tt.want {
+				t.Errorf("FindEvictingTaint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddOrRemoveTaints(t *testing.T) {
+	taints, changed := AddOrRemoveTaints([]corev1.Taint{maintenanceTaint}, []corev1.Taint{drainingTaint}, nil)
+	if !changed || len(taints) != 2 || taints[1].TimeAdded == nil {
+		t.Fatalf("AddOrRemoveTaints() = %v, %v, want draining taint added", taints, changed)
+	}
+
+	taints, changed = AddOrRemoveTaints(taints, []corev1.Taint{drainingTaint}, nil)
+	if changed || len(taints) != 2 {
+		t.Fatalf("AddOrRemoveTaints() = %v, %v, want nothing changed", taints, changed)
+	}
+
+	taints, changed = AddOrRemoveTaints(taints, nil, []corev1.Taint{maintenanceTaint})
+	if !changed || len(taints) != 1 || taints[0].Key != drainingTaint.Key {
+		t.Fatalf("AddOrRemoveTaints() = %v, %v, want maintenance taint removed", taints, changed)
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"net/http"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -96,7 +98,29 @@ func (h *ClusterCreateUpdateHandler) validateCluster(obj *v1beta1.Cluster) field
 }
 
 func (h *ClusterCreateUpdateHandler) validateClusterSpec(spec *v1beta1.ClusterSpec, path *field.Path) field.ErrorList {
-	return h.validateSpecConnectConfig(spec.Connect, path.Child("ConnectConfig"))
+	errList := h.validateSpecConnectConfig(spec.Connect, path.Child("ConnectConfig"))
+	return append(errList, h.validateSpecTaints(spec.Taints, path.Child("Taints"))...)
+}
+
+func (h *ClusterCreateUpdateHandler) validateSpecTaints(taints []corev1.Taint, path *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	seen := make(map[string]bool, len(taints))
+	for i, taint := range taints {
+		idxPath := path.Index(i)
+		for _, msg := range validation.IsQualifiedName(taint.Key) {
+			errList = append(errList, field.Invalid(idxPath.Child("Key"), taint.Key, msg))
+		}
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			errList = append(errList, field.NotSupported(idxPath.Child("Effect"), taint.Effect,
+				[]string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectNoExecute)}))
+		}
+		key := taint.Key + ":" + string(taint.Effect)
+		if seen[key] {
+			errList = append(errList, field.Duplicate(idxPath, key))
+		}
+		seen[key] = true
+	}
+	return errList
 }
 
 func (h *ClusterCreateUpdateHandler) validateSpecConnectConfig(config v1beta1.ConnectConfig, path *field.Path) field.ErrorList {